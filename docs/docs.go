@@ -15,6 +15,51 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/v1/admin/capacity": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Reports free sandbox slots per node pool, from node allocatable resources minus the requests of\nthe pods already scheduled. Slots are counted for the default profile unless another is named.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get cluster capacity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Profile to count slots for",
+                        "name": "profile",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.ClusterCapacity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/v1/admin/cleanup": {
             "post": {
                 "security": [
@@ -77,6 +122,188 @@ const docTemplate = `{
                 }
             }
         },
+        "/v1/admin/pool": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Reports the warm pool's target size, ready and warming pods, and hits and misses since startup",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get warm pool status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.PoolStatus"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sets the number of pre-provisioned sandboxes kept ready. Pods are created or removed in the background.\nThe size applies until the service restarts, when SANDBOX_POOL_SIZE applies again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Resize the warm pool",
+                "parameters": [
+                    {
+                        "description": "New pool size",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ResizePoolRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.PoolStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/operations/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Reports per-step progress, errors and the final result of an asynchronous create or delete",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "operations"
+                ],
+                "summary": "Get an asynchronous operation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Operation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Operation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cancels a running operation; a cancelled create rolls back the resources it made",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "operations"
+                ],
+                "summary": "Cancel an asynchronous operation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Operation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.Operation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/v1/sandbox/{userId}": {
             "post": {
                 "security": [
@@ -84,7 +311,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Creates a new containerized sandbox for a specific user with Traefik IngressRoutes",
+                "description": "Creates a new containerized sandbox for a specific user with Traefik IngressRoutes.\nRepeated calls are idempotent: missing or drifted resources are repaired and 200 is returned for an existing sandbox.\nWith async=true the request returns 202 and an operation ID to poll at /v1/operations/{id}.\nWith wait=ready the request returns only once the pod passes its readiness probe, or fails with the sandbox diagnostics.\nWhen the cluster or the tenant is full, the request is queued and returns 202 with a SandboxQueuedResponse;\nthe sandbox starts once there is room and its queue position is shown by the status endpoint.",
                 "consumes": [
                     "application/json"
                 ],
@@ -104,7 +331,28 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Request body (empty, kept for API compatibility)",
+                        "type": "boolean",
+                        "description": "Run asynchronously and return an operation ID",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "ready"
+                        ],
+                        "type": "string",
+                        "description": "Set to ready to wait for the readiness probe",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "How long to wait for readiness, e.g. 120s (default 2m, max 10m)",
+                        "name": "timeout",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Optional sandbox settings",
                         "name": "request",
                         "in": "body",
                         "schema": {
@@ -113,22 +361,58 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxResponse"
+                        }
+                    },
                     "201": {
                         "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/api.SandboxResponse"
                         }
                     },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.OperationAcceptedResponse"
+                        }
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
+                            "$ref": "#/definitions/api.SandboxCreateErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.InsufficientCapacityResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxNotReadyResponse"
                         }
                     }
                 }
@@ -139,7 +423,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Deletes a containerized sandbox for a specific user including Traefik IngressRoutes",
+                "description": "Deletes a containerized sandbox for a specific user including Traefik IngressRoutes.\nWith async=true the request returns 202 and an operation ID to poll at /v1/operations/{id}.\nThe user's data is kept unless purge=true, which also deletes the PVC and snapshots.",
                 "consumes": [
                     "application/json"
                 ],
@@ -157,6 +441,18 @@ const docTemplate = `{
                         "name": "userId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run asynchronously and return an operation ID",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also permanently delete the user's data",
+                        "name": "purge",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -166,6 +462,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.Response"
                         }
                     },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.OperationAcceptedResponse"
+                        }
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
@@ -181,24 +483,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/sandbox/{userId}/status": {
-            "get": {
+        "/v1/sandbox/{userId}/data": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Retrieves the status of a sandbox for a specific user with Traefik IngressRoutes",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Permanently deletes the user's PVCs and snapshots and waits until they are gone.\nThe sandbox must be deleted first; DELETE /v1/sandbox/{userId}?purge=true does both.\nReturns 409 while the user has named sandboxes, since they may mount the user's volume.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "sandbox"
                 ],
-                "summary": "Get the status of a user sandbox with Traefik routing",
+                "summary": "Purge a user's data",
                 "parameters": [
                     {
                         "type": "string",
@@ -212,7 +511,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.SandboxStatusResponse"
+                            "$ref": "#/definitions/api.PurgeResponse"
                         }
                     },
                     "400": {
@@ -221,8 +520,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -236,73 +535,2075 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/sandboxes": {
+        "/v1/sandbox/{userId}/events": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Retrieves a list of all sandboxes with their status",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Server-Sent Events stream of a sandbox's derived status. The current status is sent first, then every change (e.g. Pending, ContainerCreating, Running) and a deleted event.",
                 "produces": [
-                    "application/json"
+                    "text/event-stream"
                 ],
                 "tags": [
                     "sandbox"
                 ],
-                "summary": "List all sandboxes with Traefik routing",
+                "summary": "Stream sandbox status changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.SandboxListResponse"
+                            "$ref": "#/definitions/k8s.SandboxEvent"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
-                    }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/exec": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upgrades to a WebSocket and proxies a TTY exec session into the sandbox container of the user's newest pod.\nSend {\"type\":\"stdin\",\"data\":\"...\"} or binary frames for input and {\"type\":\"resize\",\"cols\":120,\"rows\":40} to resize.\nOutput arrives as binary frames, followed by {\"type\":\"exit\",\"exitCode\":0}.\nIntended for non-browser clients; requests with an Origin header from another site are rejected.",
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Interactive shell in a sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Command and arguments to run instead of a login shell",
+                        "name": "command",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Runs a command in the sandbox container of the user's newest pod via the pods/exec subresource\nand returns stdout, stderr and the exit code. A non-zero exit code is not an error.\nEach output stream is limited to 1 MiB. If the timeout elapses, 504 is returned with the output so far.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Run a command in a sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Command to run",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ExecRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExecResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExecResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/extend": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Adds the given minutes to the sandbox's expiry, counting from now if it has already expired.\nThe expiry is capped at the configured maximum TTL from now.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Extend a sandbox's lifetime",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Minutes to extend by",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ExtendRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxExpiryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/files": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Streams a file from the sandbox's data directory (/config). Paths are relative to /config and may not contain \"..\".\nA paused sandbox is served through a short-lived helper pod that mounts its volume.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Download a file from a sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File path relative to /config",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Writes the raw request body to a file in the sandbox's data directory (/config), creating parent\ndirectories and replacing an existing file. Paths are relative to /config and may not contain \"..\".\nA paused sandbox is served through a short-lived helper pod that mounts its volume.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Upload a file to a sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File path relative to /config",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.FileInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/files/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lists a directory in the sandbox's data directory (/config). Paths are relative to /config and may not contain \"..\".",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "List files in a sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Directory path relative to /config (default: /config itself)",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/keepalive": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Resets the sandbox's expiry to its TTL from now. An expiry that is already later is kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Keep a sandbox alive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxExpiryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/logs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the logs of the sandbox container or the volume-permissions init container of the user's newest pod as plain text.\nUse previous=true to read the logs of a crashed container, and follow=true to keep streaming new lines.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Get sandbox container logs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "sandbox",
+                        "description": "Container name (sandbox or volume-permissions)",
+                        "name": "container",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of lines from the end of the logs to return",
+                        "name": "tailLines",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only return logs newer than this many seconds",
+                        "name": "sinceSeconds",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return logs of the previous terminated container instance",
+                        "name": "previous",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream new log lines as they are written",
+                        "name": "follow",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Log output",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/pause": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Scales the sandbox deployment to zero replicas while keeping its PVC, Service and IngressRoutes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Pause a user sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/resume": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Scales a paused sandbox deployment back to one replica",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Resume a paused user sandbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.InsufficientCapacityResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/snapshots": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lists the user's VolumeSnapshots, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "snapshots"
+                ],
+                "summary": "List a sandbox's snapshots",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SnapshotListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Creates a VolumeSnapshot of the PVC holding the user's data. The snapshot is taken in the background\nand can be restored once readyToUse is true. The name defaults to {userId}-{timestamp}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "snapshots"
+                ],
+                "summary": "Snapshot a sandbox's data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Snapshot name",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.SnapshotRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.SnapshotInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/snapshots/{name}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Scales the sandbox to zero, recreates its PVC from the snapshot and scales it back up.\nEverything written since the snapshot was taken is lost. If the PVC must grow to hold the snapshot\nand that exceeds the tenant's or user's storage quota, nothing is changed and 403 is returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "snapshots"
+                ],
+                "summary": "Restore a sandbox from a snapshot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Snapshot name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retrieves the status of a sandbox for a specific user with Traefik IngressRoutes.\nA sandbox waiting for capacity has status Queued with its queue position and estimated start.\nA create dropped from the queue has status Dropped with the reason, for a day or until it is retried.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Get the status of a user sandbox with Traefik routing",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandbox/{userId}/storage": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Reports the requested and provisioned size of the user's PVC and the progress of any resize",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Get sandbox storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.StorageInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Expands the user's PVC in place. Volumes can only grow, and the storage class must allow expansion.\nThe response and GET /storage report progress; FileSystemResizePending means the file system grows\nwhen the sandbox is next started, so restartRequired is set until it is paused and resumed.\nGrowing the volume past the tenant's or user's storage quota fails with 403.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Resize sandbox storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New size",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ResizeStorageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.StorageInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandboxes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retrieves a list of all sandboxes with their status, optionally only those of one user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "List all sandboxes with Traefik routing",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only list the default and named sandboxes of this user",
+                        "name": "user",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/sandboxes/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events stream of every sandbox's derived status. The current status of each sandbox is sent first, then every change.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "sandbox"
+                ],
+                "summary": "Stream status changes of all sandboxes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/k8s.SandboxEvent"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/users/{userId}/sandboxes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lists the user's default sandbox and named sandboxes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List a user's sandboxes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Creates an additional sandbox for a user, named by the request or generated. The sandbox is identified by\nthe returned sandboxId ({userId}--{name}), which is used in place of the user ID in the /v1/sandbox/{userId}\nendpoints and in its hostnames. With storage=shared the sandbox mounts the user's default sandbox volume\ninstead of its own; the storage mode is fixed when the sandbox is first created.\nSupports the same async, wait and timeout parameters and queueing as POST /v1/sandbox/{userId}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create a named sandbox for a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run asynchronously and return an operation ID",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "ready"
+                        ],
+                        "type": "string",
+                        "description": "Set to ready to wait for the readiness probe",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "How long to wait for readiness, e.g. 120s (default 2m, max 10m)",
+                        "name": "timeout",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Optional sandbox name and settings",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.NamedSandboxRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.OperationAcceptedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxCreateErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.InsufficientCapacityResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/api.SandboxNotReadyResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deletes the user's default sandbox and named sandboxes. Data volumes are kept; use DELETE /v1/sandbox/{userId}/data to purge them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete all of a user's sandboxes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.UserSandboxesDeletedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.CleanupResponse": {
+            "description": "Cleanup operation response",
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "description": "Duration used for cleanup",
+                    "type": "string",
+                    "example": "configurable timeout"
+                },
+                "message": {
+                    "description": "Response message",
+                    "type": "string",
+                    "example": "Cleanup triggered successfully"
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "description": "Standard API error response",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string",
+                    "example": "User ID is required"
+                }
+            }
+        },
+        "api.ExecRequest": {
+            "description": "Command to run in the sandbox container",
+            "type": "object",
+            "required": [
+                "command"
+            ],
+            "properties": {
+                "command": {
+                    "description": "Command and its arguments; no shell is involved unless the command starts one",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "sh",
+                        "-c",
+                        "rm -f /config/browser/user-data/Singleton*"
+                    ]
+                },
+                "stdin": {
+                    "description": "Data written to the command's stdin",
+                    "type": "string",
+                    "example": ""
+                },
+                "timeoutSeconds": {
+                    "description": "Seconds to wait for the command before giving up (default 30, maximum 300)",
+                    "type": "integer",
+                    "example": 30
+                }
+            }
+        },
+        "api.ExecResponse": {
+            "description": "Output and exit code of a command run in the sandbox container",
+            "type": "object",
+            "properties": {
+                "exitCode": {
+                    "description": "Exit code of the command, or -1 if it timed out",
+                    "type": "integer",
+                    "example": 0
+                },
+                "stderr": {
+                    "description": "Standard error",
+                    "type": "string",
+                    "example": ""
+                },
+                "stdout": {
+                    "description": "Standard output",
+                    "type": "string",
+                    "example": ""
+                },
+                "timedOut": {
+                    "description": "Whether the command was stopped because the timeout elapsed",
+                    "type": "boolean",
+                    "example": false
+                },
+                "truncated": {
+                    "description": "Whether stdout or stderr was cut off at the output limit",
+                    "type": "boolean",
+                    "example": false
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.ExtendRequest": {
+            "description": "Request to push a sandbox's expiry back",
+            "type": "object",
+            "required": [
+                "minutes"
+            ],
+            "properties": {
+                "minutes": {
+                    "description": "Minutes to add to the sandbox's expiry",
+                    "type": "integer",
+                    "example": 30
+                }
+            }
+        },
+        "api.FileListResponse": {
+            "description": "Directory listing of a sandbox's data directory",
+            "type": "object",
+            "properties": {
+                "files": {
+                    "description": "Directory entries",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.FileInfo"
+                    }
+                },
+                "path": {
+                    "description": "Directory path relative to /config",
+                    "type": "string",
+                    "example": "/downloads"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.InsufficientCapacityResponse": {
+            "description": "Sandbox rejected because the cluster has no room for it",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string",
+                    "example": "InsufficientCapacity: no schedulable node has 1 CPU and 2Gi memory free for the sandbox"
+                },
+                "reason": {
+                    "description": "Always InsufficientCapacity",
+                    "type": "string",
+                    "example": "InsufficientCapacity"
+                }
+            }
+        },
+        "api.NamedSandboxRequest": {
+            "description": "Request to create a named sandbox. All fields are optional.",
+            "type": "object",
+            "properties": {
+                "env": {
+                    "description": "Environment variables for the sandbox container; names must be on the configured allow-list",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "image": {
+                    "description": "Full image reference to run instead of the default; must be from an allowed registry",
+                    "type": "string",
+                    "example": "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:qa-build-142"
+                },
+                "imageTag": {
+                    "description": "Agent image tag to run instead of the default; must be on the configured allow-list",
+                    "type": "string",
+                    "example": "qa-build-142"
+                },
+                "name": {
+                    "description": "Sandbox name, generated if omitted; repeating a create with the same name reconciles that sandbox",
+                    "type": "string",
+                    "example": "research"
+                },
+                "profile": {
+                    "description": "Sandbox size profile (defaults to the configured default profile)",
+                    "type": "string",
+                    "example": "large"
+                },
+                "secrets": {
+                    "description": "Secret values stored in the sandbox's own Kubernetes Secret and exposed as environment variables",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "storage": {
+                    "description": "isolated (default) gives the sandbox its own data volume; shared mounts the user's default sandbox volume",
+                    "type": "string",
+                    "enum": [
+                        "isolated",
+                        "shared"
+                    ],
+                    "example": "isolated"
+                },
+                "tier": {
+                    "description": "Priority tier (defaults to free, or the tier configured for the tenant); higher tiers may pause idle sandboxes\nof lower tiers when the cluster is full. Only the operator API key may set it.",
+                    "type": "string",
+                    "enum": [
+                        "free",
+                        "internal",
+                        "paying"
+                    ],
+                    "example": "paying"
+                },
+                "ttlMinutes": {
+                    "description": "Minutes until the sandbox is automatically deleted (defaults to the configured sandbox timeout)",
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.Operation": {
+            "description": "Asynchronous sandbox operation with per-step progress",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "Time the operation was accepted",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:00Z"
+                },
+                "error": {
+                    "description": "Error message if the operation failed",
+                    "type": "string",
+                    "example": ""
+                },
+                "id": {
+                    "description": "Operation ID",
+                    "type": "string",
+                    "example": "op-3f2a9c1d7b6e4a10"
+                },
+                "result": {
+                    "description": "Final result, the same body the synchronous endpoint would return"
+                },
+                "status": {
+                    "description": "Overall state (pending, running, succeeded, failed or cancelled)",
+                    "type": "string",
+                    "example": "running"
+                },
+                "steps": {
+                    "description": "Per-step progress",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.OperationStep"
+                    }
+                },
+                "tenant": {
+                    "description": "Tenant of the sandbox, empty for the default namespace",
+                    "type": "string",
+                    "example": "acme"
+                },
+                "type": {
+                    "description": "Operation type (create or delete)",
+                    "type": "string",
+                    "example": "create"
+                },
+                "updatedAt": {
+                    "description": "Time the operation last changed",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:01Z"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.OperationAcceptedResponse": {
+            "description": "Asynchronous operation accepted",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Response message",
+                    "type": "string",
+                    "example": "Operation accepted"
+                },
+                "operationId": {
+                    "description": "Operation ID",
+                    "type": "string",
+                    "example": "op-3f2a9c1d7b6e4a10"
+                },
+                "status": {
+                    "description": "Current operation state",
+                    "type": "string",
+                    "example": "running"
+                },
+                "statusUrl": {
+                    "description": "URL to poll for progress",
+                    "type": "string",
+                    "example": "/v1/operations/op-3f2a9c1d7b6e4a10"
+                }
+            }
+        },
+        "api.OperationStep": {
+            "description": "Progress of one step of an asynchronous operation",
+            "type": "object",
+            "properties": {
+                "completedAt": {
+                    "description": "Time the step finished",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:01Z"
+                },
+                "name": {
+                    "description": "Step name",
+                    "type": "string",
+                    "example": "deployment"
+                },
+                "startedAt": {
+                    "description": "Time the step started",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:00Z"
+                },
+                "status": {
+                    "description": "Step state (pending, running, succeeded, failed or cancelled)",
+                    "type": "string",
+                    "example": "succeeded"
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.CleanupResponse": {
-            "description": "Cleanup operation response",
+        },
+        "api.PurgeResponse": {
+            "description": "Confirmation of the user data that was permanently deleted",
             "type": "object",
             "properties": {
-                "duration": {
-                    "description": "Duration used for cleanup",
+                "deleted": {
+                    "description": "PVCs and snapshots that were deleted and confirmed gone",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.ResourceAction"
+                    }
+                },
+                "message": {
+                    "description": "Response message",
                     "type": "string",
-                    "example": "configurable timeout"
+                    "example": "Sandbox created successfully"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.QuotaExceededResponse": {
+            "description": "Quota that a create or resume would exceed",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string",
+                    "example": "user quota exceeded: cpu limit is 4, 4 in use and 2 requested"
+                },
+                "limit": {
+                    "description": "Exceeded limit (sandboxes, cpu, memory or storage)",
+                    "type": "string",
+                    "example": "cpu"
+                },
+                "max": {
+                    "description": "Quota value for the limit",
+                    "type": "string",
+                    "example": "4"
+                },
+                "requested": {
+                    "description": "Amount the sandbox needs",
+                    "type": "string",
+                    "example": "2"
+                },
+                "scope": {
+                    "description": "Quota that was hit (tenant or user)",
+                    "type": "string",
+                    "example": "user"
+                },
+                "used": {
+                    "description": "Amount already in use",
+                    "type": "string",
+                    "example": "4"
+                }
+            }
+        },
+        "api.ResizePoolRequest": {
+            "description": "Warm pool resize request",
+            "type": "object",
+            "required": [
+                "size"
+            ],
+            "properties": {
+                "size": {
+                    "description": "Number of warm sandboxes to keep",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "api.ResizeStorageRequest": {
+            "description": "Sandbox storage resize request",
+            "type": "object",
+            "required": [
+                "size"
+            ],
+            "properties": {
+                "size": {
+                    "description": "New size of the user's data volume, as a Kubernetes quantity",
+                    "type": "string",
+                    "example": "5Gi"
+                }
+            }
+        },
+        "api.Response": {
+            "description": "Standard API success response",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Response message",
+                    "type": "string",
+                    "example": "Sandbox created successfully"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.SandboxCreateErrorResponse": {
+            "description": "Sandbox creation failure with the failed step and rollback actions",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string",
+                    "example": "failed to create sandbox for user user123 at step service: services is forbidden"
+                },
+                "failedStep": {
+                    "description": "Step that failed (pvc, secret, deployment, service, vnc-route or api-route)",
+                    "type": "string",
+                    "example": "service"
+                },
+                "rollback": {
+                    "description": "Cleanup actions run to remove resources created by this request",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.RollbackAction"
+                    }
+                }
+            }
+        },
+        "api.SandboxExpiryResponse": {
+            "description": "Sandbox expiry after an extend or keepalive",
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "description": "Time after which the sandbox is automatically deleted",
+                    "type": "string",
+                    "example": "2023-04-20T12:30:00Z"
+                },
+                "message": {
+                    "description": "Response message",
+                    "type": "string",
+                    "example": "Sandbox created successfully"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.SandboxListResponse": {
+            "description": "List of all sandboxes",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count of sandboxes",
+                    "type": "integer",
+                    "example": 3
+                },
+                "sandboxes": {
+                    "description": "List of sandboxes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.SandboxInfo"
+                    }
+                }
+            }
+        },
+        "api.SandboxNotReadyResponse": {
+            "description": "Sandbox readiness failure with the last observed status",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string",
+                    "example": "sandbox for user user123 did not become ready (ImagePullBackOff): sandbox is in state ImagePullBackOff"
+                },
+                "reason": {
+                    "description": "Why the sandbox is not ready (Timeout, Cancelled or a container waiting reason)",
+                    "type": "string",
+                    "example": "ImagePullBackOff"
+                },
+                "sandbox": {
+                    "description": "Last observed sandbox status with pod and container diagnostics",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/k8s.SandboxInfo"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.SandboxRequest": {
+            "description": "Request to create a new sandbox. All fields are optional.",
+            "type": "object",
+            "properties": {
+                "env": {
+                    "description": "Environment variables for the sandbox container; names must be on the configured allow-list",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "image": {
+                    "description": "Full image reference to run instead of the default; must be from an allowed registry",
+                    "type": "string",
+                    "example": "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:qa-build-142"
+                },
+                "imageTag": {
+                    "description": "Agent image tag to run instead of the default; must be on the configured allow-list",
+                    "type": "string",
+                    "example": "qa-build-142"
+                },
+                "profile": {
+                    "description": "Sandbox size profile (defaults to the configured default profile)",
+                    "type": "string",
+                    "example": "large"
+                },
+                "secrets": {
+                    "description": "Secret values stored in the sandbox's own Kubernetes Secret and exposed as environment variables",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "tier": {
+                    "description": "Priority tier (defaults to free, or the tier configured for the tenant); higher tiers may pause idle sandboxes\nof lower tiers when the cluster is full. Only the operator API key may set it.",
+                    "type": "string",
+                    "enum": [
+                        "free",
+                        "internal",
+                        "paying"
+                    ],
+                    "example": "paying"
+                },
+                "ttlMinutes": {
+                    "description": "Minutes until the sandbox is automatically deleted (defaults to the configured sandbox timeout)",
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.SandboxResponse": {
+            "description": "Sandbox creation response with URLs",
+            "type": "object",
+            "properties": {
+                "apiUrl": {
+                    "description": "API URL for the sandbox",
+                    "type": "string",
+                    "example": "https://user123-api.tryiris.dev"
                 },
                 "message": {
                     "description": "Response message",
                     "type": "string",
-                    "example": "Cleanup triggered successfully"
+                    "example": "Sandbox created successfully"
+                },
+                "name": {
+                    "description": "Name of a named sandbox",
+                    "type": "string",
+                    "example": "research"
+                },
+                "resources": {
+                    "description": "What was done with each of the sandbox's resources",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.ResourceAction"
+                    }
+                },
+                "sandbox": {
+                    "description": "Sandbox status once ready, when the request waited for readiness",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/k8s.SandboxInfo"
+                        }
+                    ]
+                },
+                "sandboxId": {
+                    "description": "ID of a named sandbox, used in place of the user ID in /v1/sandbox/{userId} endpoints",
+                    "type": "string",
+                    "example": "user123--research"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                },
+                "vncUrl": {
+                    "description": "VNC URL for the sandbox",
+                    "type": "string",
+                    "example": "https://user123-vnc.tryiris.dev"
+                },
+                "warmStart": {
+                    "description": "Whether the sandbox started from a pre-provisioned warm pool pod",
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "api.SandboxStatusResponse": {
+            "description": "Response for sandbox status check",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "Created timestamp",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:00Z"
+                },
+                "dropped": {
+                    "description": "Why the create was dropped from the queue, when the status is Dropped",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/k8s.QueueDrop"
+                        }
+                    ]
+                },
+                "exists": {
+                    "description": "Whether the sandbox exists",
+                    "type": "boolean",
+                    "example": true
+                },
+                "expiresAt": {
+                    "description": "Time after which the sandbox is automatically deleted",
+                    "type": "string",
+                    "example": "2023-04-20T12:30:00Z"
+                },
+                "image": {
+                    "description": "Image the sandbox runs",
+                    "type": "string",
+                    "example": "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:latest"
+                },
+                "imagePinned": {
+                    "description": "Whether the image was chosen explicitly rather than taken from the default",
+                    "type": "boolean",
+                    "example": false
+                },
+                "message": {
+                    "description": "Details of the reason, e.g. the scheduler's message",
+                    "type": "string",
+                    "example": "0/3 nodes are available: 3 Insufficient cpu."
+                },
+                "profile": {
+                    "description": "Size profile the sandbox was created with",
+                    "type": "string",
+                    "example": "standard"
+                },
+                "queue": {
+                    "description": "Place in the queue while the sandbox waits for capacity",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/k8s.QueuePosition"
+                        }
+                    ]
+                },
+                "reason": {
+                    "description": "Why the sandbox is not running, e.g. Unschedulable, Preempted, CrashLoopBackOff or QueueTimeout",
+                    "type": "string",
+                    "example": "Unschedulable"
+                },
+                "status": {
+                    "description": "Sandbox status",
+                    "type": "string",
+                    "example": "Running"
+                },
+                "tier": {
+                    "description": "Priority tier of the sandbox",
+                    "type": "string",
+                    "example": "free"
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "api.SnapshotListResponse": {
+            "description": "Snapshots of a sandbox's data, newest first",
+            "type": "object",
+            "properties": {
+                "snapshots": {
+                    "description": "Snapshots of the user's data",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.SnapshotInfo"
+                    }
+                },
+                "userId": {
+                    "description": "User ID",
+                    "type": "string",
+                    "example": "user123"
                 }
             }
         },
-        "api.ErrorResponse": {
-            "description": "Standard API error response",
+        "api.SnapshotRequest": {
+            "description": "Sandbox snapshot request",
             "type": "object",
             "properties": {
-                "error": {
-                    "description": "Error message",
+                "name": {
+                    "description": "Snapshot name, defaulting to {userId}-{timestamp}",
                     "type": "string",
-                    "example": "User ID is required"
+                    "example": "before-upgrade"
                 }
             }
         },
-        "api.Response": {
-            "description": "Standard API success response",
+        "api.UserSandboxesDeletedResponse": {
+            "description": "IDs of the deleted sandboxes",
             "type": "object",
             "properties": {
+                "deleted": {
+                    "description": "IDs of the sandboxes that were deleted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "user123",
+                        "user123--research"
+                    ]
+                },
                 "message": {
                     "description": "Response message",
                     "type": "string",
@@ -315,110 +2616,286 @@ const docTemplate = `{
                 }
             }
         },
-        "api.SandboxListResponse": {
-            "description": "List of all sandboxes",
+        "k8s.ClusterCapacity": {
             "type": "object",
             "properties": {
-                "count": {
-                    "description": "Count of sandboxes",
+                "cpuRequest": {
+                    "description": "CPU request of a sandbox of the profile",
+                    "type": "string",
+                    "example": "1"
+                },
+                "freeSlots": {
+                    "description": "Number of sandboxes of the profile that still fit in the cluster",
                     "type": "integer",
-                    "example": 3
+                    "example": 4
                 },
-                "sandboxes": {
-                    "description": "List of sandboxes",
+                "memoryRequest": {
+                    "description": "Memory request of a sandbox of the profile",
+                    "type": "string",
+                    "example": "2Gi"
+                },
+                "nodePools": {
+                    "description": "Free room per node pool",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/k8s.SandboxInfo"
+                        "$ref": "#/definitions/k8s.NodePoolCapacity"
                     }
+                },
+                "profile": {
+                    "description": "Profile the free slots are counted for",
+                    "type": "string",
+                    "example": "standard"
                 }
             }
         },
-        "api.SandboxRequest": {
-            "description": "Request to create a new sandbox.",
-            "type": "object"
-        },
-        "api.SandboxResponse": {
-            "description": "Sandbox creation response with URLs",
+        "k8s.ContainerStatus": {
             "type": "object",
             "properties": {
-                "apiUrl": {
-                    "description": "API URL for the sandbox",
+                "image": {
                     "type": "string",
-                    "example": "https://user123-api.tryiris.dev"
+                    "example": "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:latest"
                 },
                 "message": {
-                    "description": "Response message",
                     "type": "string",
-                    "example": "Sandbox created successfully"
+                    "example": ""
                 },
-                "userId": {
-                    "description": "User ID",
+                "name": {
                     "type": "string",
-                    "example": "user123"
+                    "example": "sandbox"
                 },
-                "vncUrl": {
-                    "description": "VNC URL for the sandbox",
+                "ready": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "reason": {
                     "type": "string",
-                    "example": "https://user123-vnc.tryiris.dev"
+                    "example": ""
+                },
+                "restartCount": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "state": {
+                    "type": "string",
+                    "example": "running"
                 }
             }
         },
-        "api.SandboxStatusResponse": {
-            "description": "Response for sandbox status check",
+        "k8s.FileInfo": {
             "type": "object",
             "properties": {
-                "createdAt": {
-                    "description": "Created timestamp",
+                "modifiedAt": {
+                    "description": "Last modification time",
                     "type": "string",
-                    "example": "2023-04-20T12:00:00Z"
+                    "example": "2023-04-20T12:30:00Z"
                 },
-                "exists": {
-                    "description": "Whether the sandbox exists",
-                    "type": "boolean",
-                    "example": true
+                "name": {
+                    "description": "File name",
+                    "type": "string",
+                    "example": "report.pdf"
                 },
-                "status": {
-                    "description": "Sandbox status",
+                "path": {
+                    "description": "Path relative to the data directory",
                     "type": "string",
-                    "example": "Running"
+                    "example": "/downloads/report.pdf"
                 },
-                "userId": {
-                    "description": "User ID",
+                "size": {
+                    "description": "Size in bytes",
+                    "type": "integer",
+                    "example": 52133
+                },
+                "type": {
+                    "description": "Type (file, directory, symlink or other)",
                     "type": "string",
-                    "example": "user123"
+                    "example": "file"
                 }
             }
         },
-        "k8s.ContainerStatus": {
+        "k8s.NodePoolCapacity": {
             "type": "object",
             "properties": {
-                "image": {
+                "allocatableCpu": {
+                    "description": "Allocatable CPU of the schedulable nodes",
                     "type": "string",
-                    "example": "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:latest"
+                    "example": "11580m"
                 },
-                "message": {
+                "allocatableMemory": {
+                    "description": "Allocatable memory of the schedulable nodes",
                     "type": "string",
-                    "example": ""
+                    "example": "44Gi"
+                },
+                "freeSlots": {
+                    "description": "Number of sandboxes of the profile that still fit, counted node by node",
+                    "type": "integer",
+                    "example": 4
                 },
                 "name": {
+                    "description": "Node pool name, or \"default\" for nodes without a node pool label",
                     "type": "string",
-                    "example": "sandbox"
+                    "example": "default-pool"
+                },
+                "nodes": {
+                    "description": "Number of nodes in the pool",
+                    "type": "integer",
+                    "example": 3
+                },
+                "requestedCpu": {
+                    "description": "CPU requested by the pods on the schedulable nodes",
+                    "type": "string",
+                    "example": "6200m"
+                },
+                "requestedMemory": {
+                    "description": "Memory requested by the pods on the schedulable nodes",
+                    "type": "string",
+                    "example": "13Gi"
+                },
+                "schedulableNodes": {
+                    "description": "Nodes that accept new sandboxes (ready, not cordoned and without NoSchedule taints)",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "k8s.PoolStatus": {
+            "type": "object",
+            "properties": {
+                "hits": {
+                    "description": "Creates served from the pool since startup",
+                    "type": "integer",
+                    "example": 42
+                },
+                "misses": {
+                    "description": "Eligible creates that found no ready pool pod since startup",
+                    "type": "integer",
+                    "example": 5
                 },
                 "ready": {
+                    "description": "Pool pods that are ready to be claimed",
+                    "type": "integer",
+                    "example": 2
+                },
+                "targetSize": {
+                    "description": "Number of warm sandboxes the pool keeps",
+                    "type": "integer",
+                    "example": 3
+                },
+                "warming": {
+                    "description": "Pool pods that are still starting",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "k8s.QueueDrop": {
+            "type": "object",
+            "properties": {
+                "droppedAt": {
+                    "description": "When the request was dropped",
+                    "type": "string",
+                    "example": "2023-04-20T13:00:00Z"
+                },
+                "message": {
+                    "description": "Details, e.g. the error of the failed create",
+                    "type": "string",
+                    "example": "no capacity after waiting 1h0m0s in the queue"
+                },
+                "queuedAt": {
+                    "description": "When the request was queued",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:00Z"
+                },
+                "reason": {
+                    "description": "Why the request was dropped: QueueTimeout or CreateFailed",
+                    "type": "string",
+                    "example": "QueueTimeout"
+                }
+            }
+        },
+        "k8s.QueuePosition": {
+            "type": "object",
+            "properties": {
+                "estimatedStartAt": {
+                    "description": "Estimated start, based on when the running sandboxes expire; omitted when unknown",
+                    "type": "string",
+                    "example": "2023-04-20T12:20:00Z"
+                },
+                "length": {
+                    "description": "Number of requests in the queue",
+                    "type": "integer",
+                    "example": 5
+                },
+                "position": {
+                    "description": "Position in the queue, starting at 1",
+                    "type": "integer",
+                    "example": 3
+                },
+                "queuedAt": {
+                    "description": "When the request was queued",
+                    "type": "string",
+                    "example": "2023-04-20T12:00:00Z"
+                }
+            }
+        },
+        "k8s.ResourceAction": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "created"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "user123-deployment"
+                },
+                "resource": {
+                    "type": "string",
+                    "example": "deployment"
+                }
+            }
+        },
+        "k8s.RollbackAction": {
+            "type": "object",
+            "properties": {
+                "deleted": {
                     "type": "boolean",
                     "example": true
                 },
-                "reason": {
+                "error": {
                     "type": "string",
                     "example": ""
                 },
-                "restartCount": {
-                    "type": "integer",
-                    "example": 0
+                "name": {
+                    "type": "string",
+                    "example": "user123-deployment"
                 },
-                "state": {
+                "resource": {
                     "type": "string",
-                    "example": "running"
+                    "example": "deployment"
+                },
+                "resumed": {
+                    "description": "Resumed is set for a sandbox that was paused to make room and has been resumed",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "k8s.SandboxEvent": {
+            "type": "object",
+            "properties": {
+                "sandbox": {
+                    "$ref": "#/definitions/k8s.SandboxInfo"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "2023-04-20T12:00:00Z"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "status"
+                },
+                "userId": {
+                    "type": "string",
+                    "example": "user123"
                 }
             }
         },
@@ -435,16 +2912,40 @@ const docTemplate = `{
                     "type": "string",
                     "example": "2023-04-20T12:00:00Z"
                 },
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2023-04-20T12:30:00Z"
+                },
+                "image": {
+                    "type": "string",
+                    "example": "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:latest"
+                },
+                "imagePinned": {
+                    "type": "boolean",
+                    "example": false
+                },
                 "initContainerStatuses": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/k8s.ContainerStatus"
                     }
                 },
+                "lastActivityAt": {
+                    "type": "string",
+                    "example": "2023-04-20T12:10:00Z"
+                },
                 "message": {
                     "type": "string",
                     "example": ""
                 },
+                "name": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "owner": {
+                    "type": "string",
+                    "example": "user123"
+                },
                 "podConditions": {
                     "type": "array",
                     "items": {
@@ -465,6 +2966,10 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Running"
                 },
+                "profile": {
+                    "type": "string",
+                    "example": "standard"
+                },
                 "reason": {
                     "type": "string",
                     "example": ""
@@ -473,11 +2978,95 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Running"
                 },
+                "tier": {
+                    "type": "string",
+                    "example": "free"
+                },
+                "userId": {
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "k8s.SnapshotInfo": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "When the snapshot was requested",
+                    "type": "string",
+                    "example": "2023-04-20T12:30:00Z"
+                },
+                "error": {
+                    "description": "Error reported by the snapshot controller",
+                    "type": "string",
+                    "example": ""
+                },
+                "name": {
+                    "description": "Snapshot name",
+                    "type": "string",
+                    "example": "user123-20230420123000"
+                },
+                "readyToUse": {
+                    "description": "Whether the snapshot can be restored",
+                    "type": "boolean",
+                    "example": true
+                },
+                "restoreSize": {
+                    "description": "Minimum size of a volume restored from the snapshot",
+                    "type": "string",
+                    "example": "1Gi"
+                },
+                "sourcePvc": {
+                    "description": "PVC the snapshot was taken from",
+                    "type": "string",
+                    "example": "user123-pvc"
+                },
                 "userId": {
+                    "description": "User the snapshot belongs to",
                     "type": "string",
                     "example": "user123"
                 }
             }
+        },
+        "k8s.StorageInfo": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "description": "Size of the provisioned volume",
+                    "type": "string",
+                    "example": "1Gi"
+                },
+                "claimName": {
+                    "description": "PVC holding the user's data",
+                    "type": "string",
+                    "example": "user123-pvc"
+                },
+                "message": {
+                    "description": "Message from the PVC's resize condition",
+                    "type": "string",
+                    "example": ""
+                },
+                "requested": {
+                    "description": "Requested size",
+                    "type": "string",
+                    "example": "5Gi"
+                },
+                "restartRequired": {
+                    "description": "Whether the sandbox must be restarted (paused and resumed) to finish growing the file system",
+                    "type": "boolean",
+                    "example": false
+                },
+                "status": {
+                    "description": "Resize state (Resized, Resizing or FileSystemResizePending)",
+                    "type": "string",
+                    "example": "FileSystemResizePending"
+                },
+                "storageClass": {
+                    "description": "Storage class of the PVC",
+                    "type": "string",
+                    "example": "standard-rwo"
+                }
+            }
         }
     }
 }`
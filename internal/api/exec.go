@@ -0,0 +1,337 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
+
+// defaultShellCommand starts bash when the image has it and falls back to sh
+var defaultShellCommand = []string{"/bin/sh", "-c", "command -v bash >/dev/null && exec bash -l || exec sh"}
+
+// Limits for non-interactive commands
+const (
+	defaultExecTimeout = 30 * time.Second
+	maxExecTimeout     = 5 * time.Minute
+	maxExecOutputBytes = 1 << 20
+)
+
+// Exec message types exchanged over the WebSocket
+const (
+	execMessageStdin  = "stdin"
+	execMessageResize = "resize"
+	execMessageExit   = "exit"
+	execMessageError  = "error"
+)
+
+// ExecMessage is a control message on the exec WebSocket.
+// Clients send stdin and resize messages as text frames (raw stdin may also be sent as binary frames);
+// the server sends terminal output as binary frames and a final exit message as a text frame.
+type ExecMessage struct {
+	// Message type (stdin, resize, exit or error)
+	Type string `json:"type" example:"resize"`
+	// Input for stdin messages, or the error for error messages
+	Data string `json:"data,omitempty" example:"ls -la\n"`
+	// Terminal width for resize messages
+	Cols uint16 `json:"cols,omitempty" example:"120"`
+	// Terminal height for resize messages
+	Rows uint16 `json:"rows,omitempty" example:"40"`
+	// Exit code for exit messages
+	ExitCode *int `json:"exitCode,omitempty" example:"0"`
+}
+
+// execUpgrader upgrades exec requests to WebSockets. The terminal is meant for non-browser
+// clients, which send no Origin header; requests from browsers are only accepted from the
+// API's own origin, so another site cannot open a terminal with credentials the browser holds.
+var execUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// ExecSandboxShell opens an interactive terminal in a user's sandbox over a WebSocket
+// @Summary      Interactive shell in a sandbox
+// @Description  Upgrades to a WebSocket and proxies a TTY exec session into the sandbox container of the user's newest pod.
+// @Description  Send {"type":"stdin","data":"..."} or binary frames for input and {"type":"resize","cols":120,"rows":40} to resize.
+// @Description  Output arrives as binary frames, followed by {"type":"exit","exitCode":0}.
+// @Description  Intended for non-browser clients; requests with an Origin header from another site are rejected.
+// @Tags         sandbox
+// @Param        userId path string true "User ID"
+// @Param        command query []string false "Command and arguments to run instead of a login shell" collectionFormat(multi)
+// @Success      101
+// @Failure      400 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/exec [get]
+func (h *SandboxHandler) ExecSandboxShell(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	command := c.QueryArray("command")
+	if len(command) == 0 {
+		command = defaultShellCommand
+	}
+
+	conn, err := execUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		log.Printf("Failed to upgrade exec connection for user %s: %v", userID, err)
+		return
+	}
+	defer conn.Close()
+
+	session := &execSession{conn: conn}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	// Closing stdin when the command ends unblocks readInput if it is writing input
+	stdin, stdinWriter := io.Pipe()
+	defer stdin.Close()
+	resize := make(chan k8s.TerminalSize, 4)
+	go session.readInput(ctx, cancel, stdinWriter, resize)
+
+	log.Printf("Starting exec session for user %s: %s", userID, strings.Join(command, " "))
+	err = h.client(c).ExecInSandbox(ctx, userID, k8s.ExecOptions{
+		Command: command,
+		Stdin:   stdin,
+		Stdout:  session,
+		TTY:     true,
+		Resize:  resize,
+	})
+
+	// Report how the session ended before closing the socket
+	if exitCode, ok := k8s.ExecExitCode(err); ok {
+		session.writeMessage(ExecMessage{Type: execMessageExit, ExitCode: &exitCode})
+	} else {
+		session.writeMessage(ExecMessage{Type: execMessageError, Data: err.Error()})
+	}
+	session.close()
+	log.Printf("Exec session ended for user %s", userID)
+}
+
+// execSession serialises writes to the exec WebSocket
+type execSession struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// Write sends terminal output to the client as a binary frame
+func (s *execSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeMessage sends a control message to the client as a text frame
+func (s *execSession) writeMessage(msg ExecMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.conn.WriteJSON(msg); err != nil {
+		log.Printf("Failed to write exec message: %v", err)
+	}
+}
+
+// close sends a normal closure frame
+func (s *execSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// readInput forwards client frames to the command's stdin and terminal size queue.
+// When the client goes away the exec session is cancelled, and when the session ends
+// pending input and resizes are abandoned.
+func (s *execSession) readInput(ctx context.Context, cancel context.CancelFunc, stdin *io.PipeWriter, resize chan<- k8s.TerminalSize) {
+	defer cancel()
+	defer close(resize)
+
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			stdin.CloseWithError(err)
+			return
+		}
+
+		if messageType == websocket.BinaryMessage {
+			if _, err := stdin.Write(data); err != nil {
+				return
+			}
+			continue
+		}
+
+		var msg ExecMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.writeMessage(ExecMessage{Type: execMessageError, Data: "invalid message: " + err.Error()})
+			continue
+		}
+
+		switch msg.Type {
+		case execMessageStdin:
+			if _, err := stdin.Write([]byte(msg.Data)); err != nil {
+				return
+			}
+		case execMessageResize:
+			if msg.Cols > 0 && msg.Rows > 0 {
+				select {
+				case resize <- k8s.TerminalSize{Width: msg.Cols, Height: msg.Rows}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		default:
+			s.writeMessage(ExecMessage{Type: execMessageError, Data: "unknown message type: " + msg.Type})
+		}
+	}
+}
+
+// RunSandboxCommand runs a command in a user's sandbox and returns its output
+// @Summary      Run a command in a sandbox
+// @Description  Runs a command in the sandbox container of the user's newest pod via the pods/exec subresource
+// @Description  and returns stdout, stderr and the exit code. A non-zero exit code is not an error.
+// @Description  Each output stream is limited to 1 MiB. If the timeout elapses, 504 is returned with the output so far.
+// @Tags         sandbox
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        request body ExecRequest true "Command to run"
+// @Success      200 {object} ExecResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Failure      504 {object} ExecResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/exec [post]
+func (h *SandboxHandler) RunSandboxCommand(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	var request ExecRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	if len(request.Command) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "command must not be empty",
+		})
+		return
+	}
+
+	timeout := defaultExecTimeout
+	if request.TimeoutSeconds < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "timeoutSeconds must not be negative",
+		})
+		return
+	}
+	if request.TimeoutSeconds > 0 {
+		timeout = time.Duration(request.TimeoutSeconds) * time.Second
+	}
+	if timeout > maxExecTimeout {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("timeoutSeconds must not exceed %d", int(maxExecTimeout.Seconds())),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxExecOutputBytes}
+	stderr := &limitedBuffer{limit: maxExecOutputBytes}
+	opts := k8s.ExecOptions{
+		Command: request.Command,
+		Stdout:  stdout,
+		Stderr:  stderr,
+	}
+	if request.Stdin != "" {
+		opts.Stdin = strings.NewReader(request.Stdin)
+	}
+
+	log.Printf("Running command for user %s: %s", userID, strings.Join(request.Command, " "))
+	err := h.client(c).ExecInSandbox(ctx, userID, opts)
+
+	response := ExecResponse{
+		UserID:    userID,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+
+	if exitCode, ok := k8s.ExecExitCode(err); ok {
+		response.ExitCode = exitCode
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		response.ExitCode = -1
+		response.TimedOut = true
+		c.JSON(http.StatusGatewayTimeout, response)
+		return
+	}
+
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("No running sandbox found for user ID: %s", userID),
+		})
+	case strings.Contains(err.Error(), "not running"):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+}
+
+// limitedBuffer collects command output up to a limit and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write keeps what fits under the limit but always reports the full length,
+// so the command is not interrupted by a short write
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); len(p) > remaining {
+		b.truncated = true
+		if remaining > 0 {
+			b.Buffer.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
+
+// fileChunkSize is the size of the chunks a download is copied in
+const fileChunkSize = 32 * 1024
+
+// DownloadSandboxFile streams a file out of a user's sandbox
+// @Summary      Download a file from a sandbox
+// @Description  Streams a file from the sandbox's data directory (/config). Paths are relative to /config and may not contain "..".
+// @Description  A paused sandbox is served through a short-lived helper pod that mounts its volume.
+// @Tags         files
+// @Produce      octet-stream
+// @Param        userId path string true "User ID"
+// @Param        path query string true "File path relative to /config"
+// @Success      200 {file} file
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/files [get]
+func (h *SandboxHandler) DownloadSandboxFile(c *gin.Context) {
+	userID := c.Param("userId")
+	filePath := c.Query("path")
+	if userID == "" || filePath == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID and path are required",
+		})
+		return
+	}
+
+	info, content, err := h.client(c).OpenSandboxFile(c.Request.Context(), userID, filePath)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name))
+	c.Header("Last-Modified", info.ModifiedAt)
+	c.Status(http.StatusOK)
+
+	buf := make([]byte, fileChunkSize)
+	c.Stream(func(w io.Writer) bool {
+		n, err := content.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return false
+			}
+		}
+		if err != nil && err != io.EOF {
+			// Headers are already sent, so the failure can only be logged
+			log.Printf("Error downloading %s for user %s: %v", filePath, userID, err)
+		}
+		return err == nil
+	})
+}
+
+// UploadSandboxFile stores the request body as a file in a user's sandbox
+// @Summary      Upload a file to a sandbox
+// @Description  Writes the raw request body to a file in the sandbox's data directory (/config), creating parent
+// @Description  directories and replacing an existing file. Paths are relative to /config and may not contain "..".
+// @Description  A paused sandbox is served through a short-lived helper pod that mounts its volume.
+// @Tags         files
+// @Accept       octet-stream
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        path query string true "File path relative to /config"
+// @Success      200 {object} k8s.FileInfo
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/files [put]
+func (h *SandboxHandler) UploadSandboxFile(c *gin.Context) {
+	userID := c.Param("userId")
+	filePath := c.Query("path")
+	if userID == "" || filePath == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID and path are required",
+		})
+		return
+	}
+
+	info, err := h.client(c).WriteSandboxFile(c.Request.Context(), userID, filePath, c.Request.Body)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// ListSandboxFiles lists a directory in a user's sandbox
+// @Summary      List files in a sandbox
+// @Description  Lists a directory in the sandbox's data directory (/config). Paths are relative to /config and may not contain "..".
+// @Tags         files
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        path query string false "Directory path relative to /config (default: /config itself)"
+// @Success      200 {object} FileListResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/files/list [get]
+func (h *SandboxHandler) ListSandboxFiles(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	dirPath := c.DefaultQuery("path", "/")
+	files, err := h.client(c).ListSandboxFiles(c.Request.Context(), userID, dirPath)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, FileListResponse{
+		UserID: userID,
+		Path:   dirPath,
+		Files:  files,
+	})
+}
+
+// respondFileError maps file errors to HTTP status codes
+func respondFileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, k8s.ErrInvalidPath):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	case strings.Contains(err.Error(), "not running"):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: err.Error(),
+		})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,24 +15,44 @@ import (
 	"github.com/shanurcsenitap/irisk8s/internal/k8s"
 )
 
+// eventKeepaliveInterval is how often an idle event stream sends a keepalive comment
+const eventKeepaliveInterval = 15 * time.Second
+
+// Bounds on how long a create waits for the sandbox to pass its readiness probe
+const (
+	defaultReadyTimeout = 2 * time.Minute
+	maxReadyTimeout     = 10 * time.Minute
+)
+
 // SandboxHandler manages sandbox operations with Traefik integration
 type SandboxHandler struct {
-	k8sClient *k8s.ClientWithTraefik
+	k8sClient  *k8s.ClientWithTraefik
+	operations *OperationStore
 }
 
 // NewSandboxHandler creates a new sandbox handler with Traefik integration
 func NewSandboxHandler(k8sClient *k8s.ClientWithTraefik) *SandboxHandler {
 	return &SandboxHandler{
-		k8sClient: k8sClient,
+		k8sClient:  k8sClient,
+		operations: NewOperationStore(),
+	}
+}
+
+// client returns the Kubernetes client for the tenant a request is scoped to
+func (h *SandboxHandler) client(c *gin.Context) *k8s.ClientWithTraefik {
+	if tenant := c.GetString(tenantContextKey); tenant != "" {
+		return h.k8sClient.ForTenant(tenant)
 	}
+	return h.k8sClient
 }
 
 // ListSandboxes lists all sandboxes with Traefik integration
 // @Summary      List all sandboxes with Traefik routing
-// @Description  Retrieves a list of all sandboxes with their status
+// @Description  Retrieves a list of all sandboxes with their status, optionally only those of one user
 // @Tags         sandbox
 // @Accept       json
 // @Produce      json
+// @Param        user query string false "Only list the default and named sandboxes of this user"
 // @Success      200 {object} SandboxListResponse
 // @Failure      500 {object} ErrorResponse
 // @Security     ApiKeyAuth
@@ -36,7 +60,13 @@ func NewSandboxHandler(k8sClient *k8s.ClientWithTraefik) *SandboxHandler {
 func (h *SandboxHandler) ListSandboxes(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	sandboxes, err := h.k8sClient.ListSandboxes(ctx)
+	var sandboxes []k8s.SandboxInfo
+	var err error
+	if owner := c.Query("user"); owner != "" {
+		sandboxes, err = h.client(c).ListUserSandboxes(ctx, owner)
+	} else {
+		sandboxes, err = h.client(c).ListSandboxes(ctx)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: err.Error(),
@@ -53,15 +83,29 @@ func (h *SandboxHandler) ListSandboxes(c *gin.Context) {
 
 // CreateSandbox creates a new sandbox for a user with Traefik integration
 // @Summary      Create a user sandbox with Traefik routing
-// @Description  Creates a new containerized sandbox for a specific user with Traefik IngressRoutes
+// @Description  Creates a new containerized sandbox for a specific user with Traefik IngressRoutes.
+// @Description  Repeated calls are idempotent: missing or drifted resources are repaired and 200 is returned for an existing sandbox.
+// @Description  With async=true the request returns 202 and an operation ID to poll at /v1/operations/{id}.
+// @Description  With wait=ready the request returns only once the pod passes its readiness probe, or fails with the sandbox diagnostics.
+// @Description  When the cluster or the tenant is full, the request is queued and returns 202 with a SandboxQueuedResponse;
+// @Description  the sandbox starts once there is room and its queue position is shown by the status endpoint.
 // @Tags         sandbox
 // @Accept       json
 // @Produce      json
 // @Param        userId path string true "User ID"
-// @Param        request body SandboxRequest false "Request body (empty, kept for API compatibility)"
+// @Param        async query bool false "Run asynchronously and return an operation ID"
+// @Param        wait query string false "Set to ready to wait for the readiness probe" Enums(ready)
+// @Param        timeout query string false "How long to wait for readiness, e.g. 120s (default 2m, max 10m)"
+// @Param        request body SandboxRequest false "Optional sandbox settings"
+// @Success      200 {object} SandboxResponse
 // @Success      201 {object} SandboxResponse
+// @Success      202 {object} OperationAcceptedResponse
 // @Failure      400 {object} ErrorResponse
-// @Failure      500 {object} ErrorResponse
+// @Failure      403 {object} QuotaExceededResponse
+// @Failure      429 {object} QuotaExceededResponse
+// @Failure      500 {object} SandboxCreateErrorResponse
+// @Failure      503 {object} InsufficientCapacityResponse
+// @Failure      504 {object} SandboxNotReadyResponse
 // @Security     ApiKeyAuth
 // @Router       /v1/sandbox/{userId} [post]
 func (h *SandboxHandler) CreateSandbox(c *gin.Context) {
@@ -73,7 +117,7 @@ func (h *SandboxHandler) CreateSandbox(c *gin.Context) {
 		return
 	}
 
-	// Parse request body (now empty as we no longer accept environment variables)
+	// Parse the optional request body
 	var request SandboxRequest
 	if err := c.ShouldBindJSON(&request); err != nil && err.Error() != "EOF" {
 		// Only return error if it's not an empty body
@@ -83,45 +127,237 @@ func (h *SandboxHandler) CreateSandbox(c *gin.Context) {
 		return
 	}
 
-	// Create the sandbox
-	err := h.k8sClient.CreateSandbox(userID)
+	// Optionally wait for the sandbox to pass its readiness probe before responding
+	waitReady, readyTimeout, err := parseReadyWait(c)
 	if err != nil {
-		// Check if error is related to service name validation
-		if strings.Contains(err.Error(), "invalid user ID for Kubernetes service") {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	h.startCreate(c, userID, request.options(), waitReady, readyTimeout)
+}
+
+// startCreate runs a create synchronously, or as an operation when async=true is set
+func (h *SandboxHandler) startCreate(c *gin.Context, userID string, opts k8s.SandboxOptions, waitReady bool, readyTimeout time.Duration) {
+	// Tenants cannot raise their own priority; their tier is configured per tenant
+	if opts.Tier != "" && c.GetString(keyTenantContextKey) != "" {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "tier can only be set with the operator API key",
+		})
+		return
+	}
+
+	client := h.client(c)
+	if c.Query("async") == "true" {
+		// Reject names and options Kubernetes or our limits cannot accept before accepting the operation
+		if valid, reason := k8s.IsValidKubernetesName(userID); !valid {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "invalid user ID for Kubernetes service: " + reason,
+			})
+			return
+		}
+		if err := client.ValidateSandboxOptions(opts); err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse{
 				Error: err.Error(),
 			})
 			return
 		}
-		// All other errors
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: err.Error(),
+
+		steps := []string{"pvc", "deployment", "service", "routes", "ready"}
+		op := h.operations.Start(client.Tenant(), "create", userID, steps, func(ctx context.Context, progress func(string)) (interface{}, error) {
+			ctx = k8s.WithProgress(ctx, progress)
+			status, body, err := h.createSandbox(ctx, client, userID, opts)
+			if err != nil || status == http.StatusAccepted {
+				return body, err
+			}
+			_, body, err = h.waitForReady(ctx, client, userID, readyTimeout, body)
+			return body, err
 		})
+		c.JSON(http.StatusAccepted, newOperationAcceptedResponse(op))
 		return
 	}
 
+	ctx := c.Request.Context()
+	status, body, err := h.createSandbox(ctx, client, userID, opts)
+	if err == nil && waitReady && status != http.StatusAccepted {
+		if readyStatus, readyBody, err := h.waitForReady(ctx, client, userID, readyTimeout, body); err != nil {
+			status, body = readyStatus, readyBody
+		} else {
+			body = readyBody
+		}
+	}
+	c.JSON(status, body)
+}
+
+// parseReadyWait reads the wait and timeout query parameters of a create request
+func parseReadyWait(c *gin.Context) (bool, time.Duration, error) {
+	wait := c.Query("wait")
+	if wait != "" && wait != "ready" {
+		return false, 0, fmt.Errorf("wait must be \"ready\"")
+	}
+
+	timeout := defaultReadyTimeout
+	if timeoutStr := c.Query("timeout"); timeoutStr != "" {
+		parsed, err := time.ParseDuration(timeoutStr)
+		if err != nil || parsed <= 0 || parsed > maxReadyTimeout {
+			return false, 0, fmt.Errorf("timeout must be a positive duration of at most %v, e.g. 120s", maxReadyTimeout)
+		}
+		timeout = parsed
+	}
+
+	return wait == "ready", timeout, nil
+}
+
+// waitForReady waits for a created sandbox to pass its readiness probe. On success the
+// sandbox status is attached to the create response; on failure the diagnostics are returned.
+func (h *SandboxHandler) waitForReady(ctx context.Context, client *k8s.ClientWithTraefik, userID string, timeout time.Duration, created interface{}) (int, interface{}, error) {
+	info, err := client.WaitForSandboxReady(ctx, userID, timeout)
+	if err != nil {
+		status := http.StatusInternalServerError
+		reason := ""
+		var notReady *k8s.SandboxNotReadyError
+		if errors.As(err, &notReady) {
+			reason = notReady.Reason
+			if notReady.Timeout() {
+				status = http.StatusGatewayTimeout
+			}
+		}
+		return status, SandboxNotReadyResponse{
+			Error:   err.Error(),
+			Reason:  reason,
+			Sandbox: info,
+		}, err
+	}
+
+	if response, ok := created.(SandboxResponse); ok {
+		response.Sandbox = info
+		created = response
+	}
+	return http.StatusOK, created, nil
+}
+
+// createSandbox creates or reconciles a sandbox and returns the HTTP status and body to report
+func (h *SandboxHandler) createSandbox(ctx context.Context, client *k8s.ClientWithTraefik, userID string, opts k8s.SandboxOptions) (int, interface{}, error) {
+	// Create the sandbox, or reconcile it if it already exists
+	result, err := client.CreateSandbox(ctx, userID, opts)
+	if err != nil {
+		// Check if error is related to service name or option validation
+		if strings.Contains(err.Error(), "invalid user ID for Kubernetes service") ||
+			errors.Is(err, k8s.ErrInvalidSandboxOptions) {
+			return http.StatusBadRequest, ErrorResponse{
+				Error: err.Error(),
+			}, err
+		}
+		if errors.Is(err, k8s.ErrSandboxOwnedByOtherUser) {
+			return http.StatusConflict, ErrorResponse{
+				Error: err.Error(),
+			}, err
+		}
+		// Wait in the queue for capacity when the cluster or tenant is full
+		if k8s.WaitsForCapacity(err) {
+			position, queueErr := client.QueueSandbox(ctx, userID, opts, err)
+			if queueErr == nil {
+				return http.StatusAccepted, queuedResponse(userID, opts, err, position), nil
+			}
+			if !errors.Is(queueErr, k8s.ErrQueueUnavailable) {
+				log.Printf("Error queueing sandbox %s: %v", userID, queueErr)
+			}
+		}
+		if status, body, ok := quotaErrorResponse(err); ok {
+			return status, body, err
+		}
+		if errors.Is(err, k8s.ErrInsufficientCapacity) {
+			return http.StatusServiceUnavailable, InsufficientCapacityResponse{
+				Error:  err.Error(),
+				Reason: k8s.ReasonInsufficientCapacity,
+			}, err
+		}
+		// Report the failed step and what was cleaned up
+		var createErr *k8s.SandboxCreateError
+		if errors.As(err, &createErr) {
+			return http.StatusInternalServerError, SandboxCreateErrorResponse{
+				Error:      createErr.Error(),
+				FailedStep: createErr.Step,
+				Rollback:   createErr.Rollback,
+			}, err
+		}
+		// All other errors
+		return http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		}, err
+	}
+
 	// Return response with VNC and API URLs
-	vncURL := "https://" + userID + "-vnc.tryiris.dev"
-	apiURL := "https://" + userID + "-api.tryiris.dev"
+	vncURL := client.SandboxURL(userID, "vnc")
+	apiURL := client.SandboxURL(userID, "api")
+
+	// A repeated create of an existing sandbox is not an error
+	status := http.StatusCreated
+	message := "Sandbox created successfully"
+	if !result.Created() {
+		status = http.StatusOK
+		message = "Sandbox already exists"
+		if result.Changed() {
+			message = "Sandbox repaired successfully"
+		}
+	}
 
-	c.JSON(http.StatusCreated, SandboxResponse{
+	response := SandboxResponse{
 		Response: Response{
-			Message: "Sandbox created successfully",
+			Message: message,
 			UserID:  userID,
 		},
-		VncURL: vncURL,
-		ApiURL: apiURL,
-	})
+		VncURL:    vncURL,
+		ApiURL:    apiURL,
+		Resources: result.Resources,
+		WarmStart: result.WarmStart(),
+	}
+	if opts.Owner != "" {
+		response.UserID = opts.Owner
+		response.SandboxID = userID
+		response.Name = opts.Name
+	}
+	return status, response, nil
+}
+
+// queuedResponse builds the 202 response for a create that waits in the queue
+func queuedResponse(userID string, opts k8s.SandboxOptions, cause error, position *k8s.QueuePosition) SandboxQueuedResponse {
+	reason := k8s.ReasonInsufficientCapacity
+	if errors.Is(cause, k8s.ErrQuotaExceeded) {
+		reason = "QuotaExceeded"
+	}
+
+	response := SandboxQueuedResponse{
+		Response: Response{
+			Message: "Sandbox queued until there is capacity for it",
+			UserID:  userID,
+		},
+		Status: "Queued",
+		Reason: reason,
+		Queue:  *position,
+	}
+	if opts.Owner != "" {
+		response.UserID = opts.Owner
+		response.SandboxID = userID
+	}
+	return response
 }
 
 // DeleteSandbox deletes a user's sandbox with Traefik integration
 // @Summary      Delete a user sandbox with Traefik routing
-// @Description  Deletes a containerized sandbox for a specific user including Traefik IngressRoutes
+// @Description  Deletes a containerized sandbox for a specific user including Traefik IngressRoutes.
+// @Description  With async=true the request returns 202 and an operation ID to poll at /v1/operations/{id}.
+// @Description  The user's data is kept unless purge=true, which also deletes the PVC and snapshots.
 // @Tags         sandbox
 // @Accept       json
 // @Produce      json
 // @Param        userId path string true "User ID"
+// @Param        async query bool false "Run asynchronously and return an operation ID"
+// @Param        purge query bool false "Also permanently delete the user's data"
 // @Success      200 {object} Response
+// @Success      202 {object} OperationAcceptedResponse
 // @Failure      400 {object} ErrorResponse
 // @Failure      500 {object} ErrorResponse
 // @Security     ApiKeyAuth
@@ -135,8 +371,125 @@ func (h *SandboxHandler) DeleteSandbox(c *gin.Context) {
 		return
 	}
 
-	err := h.k8sClient.DeleteSandbox(userID)
+	purge := c.Query("purge") == "true"
+	client := h.client(c)
+
+	if c.Query("async") == "true" {
+		steps := []string{"routes", "service", "deployment"}
+		if purge {
+			steps = append(steps, "data")
+		}
+		op := h.operations.Start(client.Tenant(), "delete", userID, steps, func(ctx context.Context, progress func(string)) (interface{}, error) {
+			_, body, err := h.deleteSandbox(k8s.WithProgress(ctx, progress), client, userID, purge)
+			return body, err
+		})
+		c.JSON(http.StatusAccepted, newOperationAcceptedResponse(op))
+		return
+	}
+
+	status, body, _ := h.deleteSandbox(c.Request.Context(), client, userID, purge)
+	c.JSON(status, body)
+}
+
+// deleteSandbox deletes a sandbox, and its data when purge is set, and returns the HTTP status and body to report
+func (h *SandboxHandler) deleteSandbox(ctx context.Context, client *k8s.ClientWithTraefik, userID string, purge bool) (int, interface{}, error) {
+	err := client.DeleteSandbox(ctx, userID)
 	if err != nil {
+		return http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		}, err
+	}
+
+	if purge {
+		return h.purgeSandboxData(ctx, client, userID)
+	}
+
+	return http.StatusOK, Response{
+		Message: "Sandbox deleted successfully",
+		UserID:  userID,
+	}, nil
+}
+
+// PurgeSandboxData permanently deletes a user's data
+// @Summary      Purge a user's data
+// @Description  Permanently deletes the user's PVCs and snapshots and waits until they are gone.
+// @Description  The sandbox must be deleted first; DELETE /v1/sandbox/{userId}?purge=true does both.
+// @Description  Returns 409 while the user has named sandboxes, since they may mount the user's volume.
+// @Tags         sandbox
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} PurgeResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/data [delete]
+func (h *SandboxHandler) PurgeSandboxData(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	status, body, _ := h.purgeSandboxData(c.Request.Context(), h.client(c), userID)
+	c.JSON(status, body)
+}
+
+// purgeSandboxData deletes a user's data and returns the HTTP status and body to report
+func (h *SandboxHandler) purgeSandboxData(ctx context.Context, client *k8s.ClientWithTraefik, userID string) (int, interface{}, error) {
+	result, err := client.PurgeSandboxData(ctx, userID)
+	if err != nil {
+		if errors.Is(err, k8s.ErrSandboxExists) {
+			return http.StatusConflict, ErrorResponse{
+				Error: err.Error(),
+			}, err
+		}
+		return http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		}, err
+	}
+
+	return http.StatusOK, PurgeResponse{
+		Response: Response{
+			Message: "User data purged successfully",
+			UserID:  userID,
+		},
+		Deleted: result.Deleted,
+	}, nil
+}
+
+// PauseSandbox scales a user's sandbox down to zero without deleting it
+// @Summary      Pause a user sandbox
+// @Description  Scales the sandbox deployment to zero replicas while keeping its PVC, Service and IngressRoutes
+// @Tags         sandbox
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} Response
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/pause [post]
+func (h *SandboxHandler) PauseSandbox(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.client(c).PauseSandbox(ctx, userID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: fmt.Sprintf("No sandbox found for user ID: %s", userID),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: err.Error(),
 		})
@@ -144,15 +497,162 @@ func (h *SandboxHandler) DeleteSandbox(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, Response{
-		Message: "Sandbox deleted successfully",
+		Message: "Sandbox paused successfully",
+		UserID:  userID,
+	})
+}
+
+// ResumeSandbox scales a paused sandbox back up
+// @Summary      Resume a paused user sandbox
+// @Description  Scales a paused sandbox deployment back to one replica
+// @Tags         sandbox
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} Response
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} QuotaExceededResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      429 {object} QuotaExceededResponse
+// @Failure      500 {object} ErrorResponse
+// @Failure      503 {object} InsufficientCapacityResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/resume [post]
+func (h *SandboxHandler) ResumeSandbox(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.client(c).ResumeSandbox(ctx, userID); err != nil {
+		if status, body, ok := quotaErrorResponse(err); ok {
+			c.JSON(status, body)
+			return
+		}
+		if errors.Is(err, k8s.ErrInsufficientCapacity) {
+			c.JSON(http.StatusServiceUnavailable, InsufficientCapacityResponse{
+				Error:  err.Error(),
+				Reason: k8s.ReasonInsufficientCapacity,
+			})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: fmt.Sprintf("No sandbox found for user ID: %s", userID),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Message: "Sandbox resumed successfully",
 		UserID:  userID,
 	})
 }
 
+// ExtendSandbox pushes back the time at which a sandbox is automatically deleted
+// @Summary      Extend a sandbox's lifetime
+// @Description  Adds the given minutes to the sandbox's expiry, counting from now if it has already expired.
+// @Description  The expiry is capped at the configured maximum TTL from now.
+// @Tags         sandbox
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        request body ExtendRequest true "Minutes to extend by"
+// @Success      200 {object} SandboxExpiryResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/extend [post]
+func (h *SandboxHandler) ExtendSandbox(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	var request ExtendRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Minutes <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "minutes must be a positive integer",
+		})
+		return
+	}
+
+	expiresAt, err := h.client(c).ExtendSandbox(c.Request.Context(), userID, time.Duration(request.Minutes)*time.Minute)
+	h.respondExpiry(c, userID, "Sandbox extended successfully", expiresAt, err)
+}
+
+// KeepaliveSandbox restarts a sandbox's TTL from now
+// @Summary      Keep a sandbox alive
+// @Description  Resets the sandbox's expiry to its TTL from now. An expiry that is already later is kept.
+// @Tags         sandbox
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} SandboxExpiryResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/keepalive [post]
+func (h *SandboxHandler) KeepaliveSandbox(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	expiresAt, err := h.client(c).KeepaliveSandbox(c.Request.Context(), userID)
+	h.respondExpiry(c, userID, "Sandbox kept alive", expiresAt, err)
+}
+
+// respondExpiry writes the response for an extend or keepalive request
+func (h *SandboxHandler) respondExpiry(c *gin.Context, userID, message string, expiresAt time.Time, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, k8s.ErrInvalidSandboxOptions):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: err.Error(),
+			})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: fmt.Sprintf("No sandbox found for user ID: %s", userID),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, SandboxExpiryResponse{
+		Response: Response{
+			Message: message,
+			UserID:  userID,
+		},
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+}
 
 // GetSandboxStatus gets the status of a sandbox by user ID with Traefik integration
 // @Summary      Get the status of a user sandbox with Traefik routing
-// @Description  Retrieves the status of a sandbox for a specific user with Traefik IngressRoutes
+// @Description  Retrieves the status of a sandbox for a specific user with Traefik IngressRoutes.
+// @Description  A sandbox waiting for capacity has status Queued with its queue position and estimated start.
+// @Description  A create dropped from the queue has status Dropped with the reason, for a day or until it is retried.
 // @Tags         sandbox
 // @Accept       json
 // @Produce      json
@@ -173,10 +673,36 @@ func (h *SandboxHandler) GetSandboxStatus(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	sandbox, err := h.k8sClient.GetSandboxStatus(ctx, userID)
+	client := h.client(c)
+	sandbox, err := client.GetSandboxStatus(ctx, userID)
 	if err != nil {
 		// Check if the error is "not found"
 		if strings.Contains(err.Error(), "not found") {
+			// A queued sandbox has no resources yet
+			if position, queueErr := client.GetQueuePosition(ctx, userID); queueErr == nil {
+				c.JSON(http.StatusOK, SandboxStatusResponseWithURLs{
+					SandboxStatusResponse: SandboxStatusResponse{
+						UserID: userID,
+						Status: "Queued",
+						Queue:  position,
+					},
+					VncURL: client.SandboxURL(userID, "vnc"),
+					ApiURL: client.SandboxURL(userID, "api"),
+				})
+				return
+			}
+			// A create dropped from the queue reports why until it is retried
+			if drop, queueErr := client.GetQueueDrop(ctx, userID); queueErr == nil {
+				c.JSON(http.StatusOK, SandboxStatusResponse{
+					UserID:  userID,
+					Status:  "Dropped",
+					Reason:  drop.Reason,
+					Message: drop.Message,
+					Dropped: drop,
+				})
+				return
+			}
+
 			c.JSON(http.StatusNotFound, ErrorResponse{
 				Error: fmt.Sprintf("No sandbox found for user ID: %s", userID),
 			})
@@ -189,21 +715,101 @@ func (h *SandboxHandler) GetSandboxStatus(c *gin.Context) {
 	}
 
 	// For Traefik integration, include the URLs
-	vncURL := "https://" + userID + "-vnc.tryiris.dev"
-	apiURL := "https://" + userID + "-api.tryiris.dev"
+	vncURL := client.SandboxURL(userID, "vnc")
+	apiURL := client.SandboxURL(userID, "api")
 
 	c.JSON(http.StatusOK, SandboxStatusResponseWithURLs{
 		SandboxStatusResponse: SandboxStatusResponse{
-			UserID:    sandbox.UserID,
-			Status:    sandbox.Status,
-			CreatedAt: sandbox.CreatedAt,
-			Exists:    true,
+			UserID:      sandbox.UserID,
+			Status:      sandbox.Status,
+			CreatedAt:   sandbox.CreatedAt,
+			Exists:      true,
+			ExpiresAt:   sandbox.ExpiresAt,
+			Profile:     sandbox.Profile,
+			Image:       sandbox.Image,
+			ImagePinned: sandbox.ImagePinned,
+			Tier:        sandbox.Tier,
+			Reason:      sandbox.Reason,
+			Message:     sandbox.Message,
 		},
 		VncURL: vncURL,
 		ApiURL: apiURL,
 	})
 }
 
+// StreamSandboxEvents streams status changes of a user's sandbox as Server-Sent Events
+// @Summary      Stream sandbox status changes
+// @Description  Server-Sent Events stream of a sandbox's derived status. The current status is sent first, then every change (e.g. Pending, ContainerCreating, Running) and a deleted event.
+// @Tags         sandbox
+// @Produce      text/event-stream
+// @Param        userId path string true "User ID"
+// @Success      200 {object} k8s.SandboxEvent
+// @Failure      400 {object} ErrorResponse
+// @Failure      503 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/events [get]
+func (h *SandboxHandler) StreamSandboxEvents(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	h.streamEvents(c, userID)
+}
+
+// StreamAllSandboxEvents streams status changes of all sandboxes as Server-Sent Events
+// @Summary      Stream status changes of all sandboxes
+// @Description  Server-Sent Events stream of every sandbox's derived status. The current status of each sandbox is sent first, then every change.
+// @Tags         sandbox
+// @Produce      text/event-stream
+// @Success      200 {object} k8s.SandboxEvent
+// @Failure      503 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandboxes/events [get]
+func (h *SandboxHandler) StreamAllSandboxEvents(c *gin.Context) {
+	h.streamEvents(c, "")
+}
+
+// streamEvents writes sandbox events to the client until it disconnects
+func (h *SandboxHandler) streamEvents(c *gin.Context, userID string) {
+	events, unsubscribe, err := h.client(c).SubscribeSandboxEvents(userID)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+
+	ctx := c.Request.Context()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepalive.C:
+			// An SSE comment keeps proxies from closing an idle stream
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
 // TriggerCleanup triggers the cleanup of sandboxes older than the specified duration with Traefik integration
 // @Summary      Trigger cleanup of old sandboxes with Traefik routing
 // @Description  Deletes all sandboxes that have been running for more than the specified minutes
@@ -250,7 +856,7 @@ func (h *SandboxHandler) TriggerCleanup(c *gin.Context) {
 
 	// Trigger cleanup
 	ctx := c.Request.Context()
-	err = h.k8sClient.CleanupExpiredSandboxesByDuration(ctx, duration, authToken)
+	err = h.client(c).CleanupExpiredSandboxesByDuration(ctx, duration, authToken)
 	if err != nil {
 		// Check if the error is unauthorized
 		if strings.Contains(err.Error(), "unauthorized") {
@@ -270,4 +876,190 @@ func (h *SandboxHandler) TriggerCleanup(c *gin.Context) {
 		Message:  "Cleanup triggered successfully",
 		Duration: fmt.Sprintf("%d minutes", minutes),
 	})
-}
\ No newline at end of file
+}
+
+// GetPoolStatus reports the size and hit rate of the warm pool
+// @Summary      Get warm pool status
+// @Description  Reports the warm pool's target size, ready and warming pods, and hits and misses since startup
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} k8s.PoolStatus
+// @Failure      500 {object} ErrorResponse
+// @Failure      503 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/admin/pool [get]
+func (h *SandboxHandler) GetPoolStatus(c *gin.Context) {
+	status, err := h.k8sClient.GetPoolStatus(c.Request.Context())
+	if err != nil {
+		respondPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ResizePool changes the number of warm sandboxes
+// @Summary      Resize the warm pool
+// @Description  Sets the number of pre-provisioned sandboxes kept ready. Pods are created or removed in the background.
+// @Description  The size applies until the service restarts, when SANDBOX_POOL_SIZE applies again.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request body ResizePoolRequest true "New pool size"
+// @Success      200 {object} k8s.PoolStatus
+// @Failure      400 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Failure      503 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/admin/pool [put]
+func (h *SandboxHandler) ResizePool(c *gin.Context) {
+	var request ResizePoolRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	status, err := h.k8sClient.ResizePool(c.Request.Context(), *request.Size)
+	if err != nil {
+		respondPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// respondPoolError maps warm pool errors to HTTP status codes
+func respondPoolError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, k8s.ErrInvalidSandboxOptions):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, k8s.ErrPoolNotRunning):
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+}
+
+// GetClusterCapacity reports how many more sandboxes fit on the cluster's nodes
+// @Summary      Get cluster capacity
+// @Description  Reports free sandbox slots per node pool, from node allocatable resources minus the requests of
+// @Description  the pods already scheduled. Slots are counted for the default profile unless another is named.
+// @Tags         admin
+// @Produce      json
+// @Param        profile query string false "Profile to count slots for"
+// @Success      200 {object} k8s.ClusterCapacity
+// @Failure      400 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/admin/capacity [get]
+func (h *SandboxHandler) GetClusterCapacity(c *gin.Context) {
+	capacity, err := h.k8sClient.GetClusterCapacity(c.Request.Context(), c.Query("profile"))
+	if err != nil {
+		if errors.Is(err, k8s.ErrInvalidSandboxOptions) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, capacity)
+}
+
+// GetOperation reports the progress of an asynchronous operation
+// @Summary      Get an asynchronous operation
+// @Description  Reports per-step progress, errors and the final result of an asynchronous create or delete
+// @Tags         operations
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Operation ID"
+// @Success      200 {object} Operation
+// @Failure      404 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/operations/{id} [get]
+func (h *SandboxHandler) GetOperation(c *gin.Context) {
+	id := c.Param("id")
+	op, ok := h.operations.Get(id)
+	if !ok || !operationVisible(c, op) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("No operation found with ID: %s", id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, op)
+}
+
+// CancelOperation cancels a running asynchronous operation
+// @Summary      Cancel an asynchronous operation
+// @Description  Cancels a running operation; a cancelled create rolls back the resources it made
+// @Tags         operations
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Operation ID"
+// @Success      202 {object} Operation
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/operations/{id} [delete]
+func (h *SandboxHandler) CancelOperation(c *gin.Context) {
+	id := c.Param("id")
+	if op, ok := h.operations.Get(id); ok && !operationVisible(c, op) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("No operation found with ID: %s", id),
+		})
+		return
+	}
+	op, ok, err := h.operations.Cancel(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("No operation found with ID: %s", id),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, op)
+}
+
+// operationVisible reports whether a request may see an operation. Tenant API keys only see their tenant's operations.
+func operationVisible(c *gin.Context, op Operation) bool {
+	keyTenant := c.GetString(keyTenantContextKey)
+	return keyTenant == "" || op.Tenant == keyTenant
+}
+
+// quotaErrorResponse returns the HTTP status and body for a quota error. Too many running sandboxes
+// is reported as 429, since it clears as sandboxes stop; exceeding a resource total is 403.
+func quotaErrorResponse(err error) (int, QuotaExceededResponse, bool) {
+	var quotaErr *k8s.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return 0, QuotaExceededResponse{}, false
+	}
+
+	status := http.StatusForbidden
+	if quotaErr.Limit == k8s.QuotaLimitSandboxes {
+		status = http.StatusTooManyRequests
+	}
+	return status, QuotaExceededResponse{
+		Error:     quotaErr.Error(),
+		Scope:     quotaErr.Scope,
+		Limit:     quotaErr.Limit,
+		Max:       quotaErr.Max,
+		Used:      quotaErr.Used,
+		Requested: quotaErr.Requested,
+	}, true
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
+
+// logChunkSize is the largest piece of log output written to the client at once
+const logChunkSize = 32 * 1024
+
+// GetSandboxLogs returns or streams container logs from a user's sandbox
+// @Summary      Get sandbox container logs
+// @Description  Returns the logs of the sandbox container or the volume-permissions init container of the user's newest pod as plain text.
+// @Description  Use previous=true to read the logs of a crashed container, and follow=true to keep streaming new lines.
+// @Tags         sandbox
+// @Produce      plain
+// @Param        userId path string true "User ID"
+// @Param        container query string false "Container name (sandbox or volume-permissions)" default(sandbox)
+// @Param        tailLines query int false "Number of lines from the end of the logs to return"
+// @Param        sinceSeconds query int false "Only return logs newer than this many seconds"
+// @Param        previous query bool false "Return logs of the previous terminated container instance"
+// @Param        follow query bool false "Stream new log lines as they are written"
+// @Success      200 {string} string "Log output"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/logs [get]
+func (h *SandboxHandler) GetSandboxLogs(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	opts, err := parseLogOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	stream, podName, err := h.client(c).StreamSandboxLogs(c.Request.Context(), userID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, k8s.ErrLogsUnavailable):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: err.Error(),
+			})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: fmt.Sprintf("No sandbox pod found for user ID: %s", userID),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("X-Pod-Name", podName)
+	c.Header("X-Container-Name", opts.Container)
+	if opts.Follow {
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+	}
+	c.Status(http.StatusOK)
+
+	// Each chunk is flushed as it is read, so followed logs arrive as they are written
+	buf := make([]byte, logChunkSize)
+	c.Stream(func(w io.Writer) bool {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return false
+			}
+		}
+		return err == nil
+	})
+}
+
+// parseLogOptions reads the log query parameters
+func parseLogOptions(c *gin.Context) (k8s.LogOptions, error) {
+	opts := k8s.LogOptions{
+		Container: c.DefaultQuery("container", "sandbox"),
+	}
+	if !k8s.IsSandboxLogContainer(opts.Container) {
+		return opts, fmt.Errorf("container must be one of: %s", strings.Join(k8s.SandboxLogContainers, ", "))
+	}
+
+	if value := c.Query("tailLines"); value != "" {
+		tailLines, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || tailLines < 0 {
+			return opts, fmt.Errorf("tailLines must be a non-negative integer")
+		}
+		opts.TailLines = &tailLines
+	}
+
+	if value := c.Query("sinceSeconds"); value != "" {
+		sinceSeconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || sinceSeconds <= 0 {
+			return opts, fmt.Errorf("sinceSeconds must be a positive integer")
+		}
+		opts.SinceSeconds = &sinceSeconds
+	}
+
+	opts.Previous = c.Query("previous") == "true"
+	opts.Follow = c.Query("follow") == "true"
+	if opts.Previous && opts.Follow {
+		return opts, fmt.Errorf("previous and follow cannot be combined")
+	}
+
+	return opts, nil
+}
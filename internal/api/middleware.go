@@ -5,9 +5,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/shanurcsenitap/irisk8s/internal/config"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
 )
 
-// AuthMiddleware creates a middleware for API key authentication
+// Context keys set by the middleware
+const (
+	// keyTenantContextKey holds the tenant an API key is limited to
+	keyTenantContextKey = "keyTenant"
+	// tenantContextKey holds the tenant whose sandboxes a request manages
+	tenantContextKey = "tenant"
+)
+
+// AuthMiddleware creates a middleware for API key authentication.
+// Tenant API keys are limited to their tenant's sandboxes.
 func AuthMiddleware(cfg *config.Configuration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		apiKey := c.GetHeader("X-API-KEY")
@@ -18,7 +28,52 @@ func AuthMiddleware(cfg *config.Configuration) gin.HandlerFunc {
 		}
 
 		if apiKey != cfg.APIKey {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Invalid API key"})
+			tenant, ok := cfg.TenantAPIKeys[apiKey]
+			if !ok {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Invalid API key"})
+				c.Abort()
+				return
+			}
+			if err := k8s.ValidateTenantID(tenant); err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "API key is configured for an " + err.Error()})
+				c.Abort()
+				return
+			}
+			c.Set(keyTenantContextKey, tenant)
+			c.Set(tenantContextKey, tenant)
+		}
+
+		c.Next()
+	}
+}
+
+// TenantMiddleware scopes requests under /v1/tenants/{tenant} to that tenant.
+// Tenant API keys may only use their own tenant's routes.
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := c.Param("tenant")
+		if err := k8s.ValidateTenantID(tenant); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			c.Abort()
+			return
+		}
+
+		if keyTenant := c.GetString(keyTenantContextKey); keyTenant != "" && keyTenant != tenant {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "API key does not belong to tenant " + tenant})
+			c.Abort()
+			return
+		}
+
+		c.Set(tenantContextKey, tenant)
+		c.Next()
+	}
+}
+
+// OperatorOnly rejects tenant API keys on endpoints that affect every tenant
+func OperatorOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(keyTenantContextKey) != "" {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "This endpoint is not available to tenant API keys"})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+)
+
+func TestTenantScoping(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Configuration{
+		APIKey:        "operator-key",
+		TenantAPIKeys: map[string]string{"acme-key": "acme", "bad-key": "Not_Valid"},
+	}
+	router := gin.New()
+	v1 := router.Group("/v1")
+	v1.Use(AuthMiddleware(cfg))
+	reportTenant := func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(tenantContextKey))
+	}
+	v1.GET("/sandboxes", reportTenant)
+	tenant := v1.Group("/tenants/:tenant")
+	tenant.Use(TenantMiddleware())
+	tenant.GET("/sandboxes", reportTenant)
+	admin := v1.Group("/admin")
+	admin.Use(OperatorOnly())
+	admin.GET("/pool", reportTenant)
+
+	testCases := []struct {
+		name           string
+		path           string
+		apiKey         string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{"Missing key", "/v1/sandboxes", "", http.StatusUnauthorized, ""},
+		{"Unknown key", "/v1/sandboxes", "other-key", http.StatusUnauthorized, ""},
+		{"Operator default namespace", "/v1/sandboxes", "operator-key", http.StatusOK, ""},
+		{"Operator tenant route", "/v1/tenants/globex/sandboxes", "operator-key", http.StatusOK, "globex"},
+		{"Operator invalid tenant", "/v1/tenants/Globex/sandboxes", "operator-key", http.StatusBadRequest, ""},
+		{"Tenant key default route", "/v1/sandboxes", "acme-key", http.StatusOK, "acme"},
+		{"Tenant key own tenant", "/v1/tenants/acme/sandboxes", "acme-key", http.StatusOK, "acme"},
+		{"Tenant key other tenant", "/v1/tenants/globex/sandboxes", "acme-key", http.StatusForbidden, ""},
+		{"Tenant key misconfigured", "/v1/sandboxes", "bad-key", http.StatusInternalServerError, ""},
+		{"Operator admin", "/v1/admin/pool", "operator-key", http.StatusOK, ""},
+		{"Tenant key admin", "/v1/admin/pool", "acme-key", http.StatusForbidden, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-KEY", tc.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusOK && w.Body.String() != tc.expectedTenant {
+				t.Errorf("Expected tenant %q, got %q", tc.expectedTenant, w.Body.String())
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package api
 
-import "github.com/shanurcsenitap/irisk8s/internal/k8s"
+import (
+	"time"
+
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
 
 // Response is the standard success response
 // @Description Standard API success response
@@ -12,10 +16,68 @@ type Response struct {
 }
 
 // SandboxRequest represents a request to create a new sandbox.
-// @Description Request to create a new sandbox.
+// @Description Request to create a new sandbox. All fields are optional.
 type SandboxRequest struct {
-	// This struct is now empty as we've removed environment variable passing.
-	// Kept for API compatibility.
+	// Minutes until the sandbox is automatically deleted (defaults to the configured sandbox timeout)
+	TTLMinutes int `json:"ttlMinutes,omitempty" example:"120"`
+	// Sandbox size profile (defaults to the configured default profile)
+	Profile string `json:"profile,omitempty" example:"large"`
+	// Agent image tag to run instead of the default; must be on the configured allow-list
+	ImageTag string `json:"imageTag,omitempty" example:"qa-build-142"`
+	// Full image reference to run instead of the default; must be from an allowed registry
+	Image string `json:"image,omitempty" example:"us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:qa-build-142"`
+	// Environment variables for the sandbox container; names must be on the configured allow-list
+	Env map[string]string `json:"env,omitempty"`
+	// Secret values stored in the sandbox's own Kubernetes Secret and exposed as environment variables
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// Priority tier (defaults to free, or the tier configured for the tenant); higher tiers may pause idle sandboxes
+	// of lower tiers when the cluster is full. Only the operator API key may set it.
+	Tier string `json:"tier,omitempty" example:"paying" enums:"free,internal,paying"`
+}
+
+// options converts the request into sandbox options
+func (r SandboxRequest) options() k8s.SandboxOptions {
+	return k8s.SandboxOptions{
+		TTL:      time.Duration(r.TTLMinutes) * time.Minute,
+		Profile:  r.Profile,
+		ImageTag: r.ImageTag,
+		Image:    r.Image,
+		Env:      r.Env,
+		Secrets:  r.Secrets,
+		Tier:     r.Tier,
+	}
+}
+
+// Storage modes for named sandboxes
+const (
+	storageIsolated = "isolated"
+	storageShared   = "shared"
+)
+
+// NamedSandboxRequest represents a request to create one of a user's named sandboxes.
+// @Description Request to create a named sandbox. All fields are optional.
+type NamedSandboxRequest struct {
+	SandboxRequest
+	// Sandbox name, generated if omitted; repeating a create with the same name reconciles that sandbox
+	Name string `json:"name,omitempty" example:"research"`
+	// isolated (default) gives the sandbox its own data volume; shared mounts the user's default sandbox volume
+	Storage string `json:"storage,omitempty" example:"isolated" enums:"isolated,shared"`
+}
+
+// ExtendRequest is a request to extend a sandbox's lifetime
+// @Description Request to push a sandbox's expiry back
+type ExtendRequest struct {
+	// Minutes to add to the sandbox's expiry
+	Minutes int `json:"minutes" binding:"required" example:"30"`
+}
+
+// SandboxExpiryResponse is the response for a change to a sandbox's expiry
+// @Description Sandbox expiry after an extend or keepalive
+type SandboxExpiryResponse struct {
+	// Embed the standard response
+	Response
+	// Time after which the sandbox is automatically deleted
+	ExpiresAt string `json:"expiresAt" example:"2023-04-20T12:30:00Z"`
 }
 
 // SandboxResponse is the response for sandbox creation with Traefik integration
@@ -27,6 +89,67 @@ type SandboxResponse struct {
 	VncURL string `json:"vncUrl" example:"https://user123-vnc.tryiris.dev"`
 	// API URL for the sandbox
 	ApiURL string `json:"apiUrl" example:"https://user123-api.tryiris.dev"`
+	// ID of a named sandbox, used in place of the user ID in /v1/sandbox/{userId} endpoints
+	SandboxID string `json:"sandboxId,omitempty" example:"user123--research"`
+	// Name of a named sandbox
+	Name string `json:"name,omitempty" example:"research"`
+	// What was done with each of the sandbox's resources
+	Resources []k8s.ResourceAction `json:"resources,omitempty"`
+	// Whether the sandbox started from a pre-provisioned warm pool pod
+	WarmStart bool `json:"warmStart,omitempty" example:"true"`
+	// Sandbox status once ready, when the request waited for readiness
+	Sandbox *k8s.SandboxInfo `json:"sandbox,omitempty"`
+}
+
+// SandboxNotReadyResponse is the error response when a sandbox fails to become ready
+// @Description Sandbox readiness failure with the last observed status
+type SandboxNotReadyResponse struct {
+	// Error message
+	Error string `json:"error" example:"sandbox for user user123 did not become ready (ImagePullBackOff): sandbox is in state ImagePullBackOff"`
+	// Why the sandbox is not ready (Timeout, Cancelled or a container waiting reason)
+	Reason string `json:"reason" example:"ImagePullBackOff"`
+	// Last observed sandbox status with pod and container diagnostics
+	Sandbox *k8s.SandboxInfo `json:"sandbox,omitempty"`
+}
+
+// QuotaExceededResponse is the error response when a sandbox would exceed a quota
+// @Description Quota that a create or resume would exceed
+type QuotaExceededResponse struct {
+	// Error message
+	Error string `json:"error" example:"user quota exceeded: cpu limit is 4, 4 in use and 2 requested"`
+	// Quota that was hit (tenant or user)
+	Scope string `json:"scope" example:"user"`
+	// Exceeded limit (sandboxes, cpu, memory or storage)
+	Limit string `json:"limit" example:"cpu"`
+	// Quota value for the limit
+	Max string `json:"max" example:"4"`
+	// Amount already in use
+	Used string `json:"used" example:"4"`
+	// Amount the sandbox needs
+	Requested string `json:"requested" example:"2"`
+}
+
+// InsufficientCapacityResponse is the error response when no node has room for a sandbox
+// @Description Sandbox rejected because the cluster has no room for it
+type InsufficientCapacityResponse struct {
+	// Error message
+	Error string `json:"error" example:"InsufficientCapacity: no schedulable node has 1 CPU and 2Gi memory free for the sandbox"`
+	// Always InsufficientCapacity
+	Reason string `json:"reason" example:"InsufficientCapacity"`
+}
+
+// SandboxQueuedResponse is the response for a create that waits in the queue for capacity
+// @Description Sandbox queued until the cluster or tenant has room for it
+type SandboxQueuedResponse struct {
+	Response
+	// Sandbox ID, for named sandboxes
+	SandboxID string `json:"sandboxId,omitempty" example:"user123-research"`
+	// Always Queued
+	Status string `json:"status" example:"Queued"`
+	// Why the sandbox could not start yet (InsufficientCapacity or QuotaExceeded)
+	Reason string `json:"reason" example:"InsufficientCapacity"`
+	// Place in the queue
+	Queue k8s.QueuePosition `json:"queue"`
 }
 
 // SandboxListResponse is the response for listing all sandboxes
@@ -49,6 +172,24 @@ type SandboxStatusResponse struct {
 	CreatedAt string `json:"createdAt" example:"2023-04-20T12:00:00Z"`
 	// Whether the sandbox exists
 	Exists bool `json:"exists" example:"true"`
+	// Time after which the sandbox is automatically deleted
+	ExpiresAt string `json:"expiresAt,omitempty" example:"2023-04-20T12:30:00Z"`
+	// Size profile the sandbox was created with
+	Profile string `json:"profile,omitempty" example:"standard"`
+	// Image the sandbox runs
+	Image string `json:"image,omitempty" example:"us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:latest"`
+	// Whether the image was chosen explicitly rather than taken from the default
+	ImagePinned bool `json:"imagePinned,omitempty" example:"false"`
+	// Priority tier of the sandbox
+	Tier string `json:"tier,omitempty" example:"free"`
+	// Why the sandbox is not running, e.g. Unschedulable, Preempted, CrashLoopBackOff or QueueTimeout
+	Reason string `json:"reason,omitempty" example:"Unschedulable"`
+	// Details of the reason, e.g. the scheduler's message
+	Message string `json:"message,omitempty" example:"0/3 nodes are available: 3 Insufficient cpu."`
+	// Place in the queue while the sandbox waits for capacity
+	Queue *k8s.QueuePosition `json:"queue,omitempty"`
+	// Why the create was dropped from the queue, when the status is Dropped
+	Dropped *k8s.QueueDrop `json:"dropped,omitempty"`
 }
 
 // SandboxStatusResponseWithURLs is the response for checking a sandbox's status with Traefik integration
@@ -69,6 +210,127 @@ type ErrorResponse struct {
 	Error string `json:"error" example:"User ID is required"`
 }
 
+// SandboxCreateErrorResponse is the error response for a sandbox creation that failed part way
+// @Description Sandbox creation failure with the failed step and rollback actions
+type SandboxCreateErrorResponse struct {
+	// Error message
+	Error string `json:"error" example:"failed to create sandbox for user user123 at step service: services is forbidden"`
+	// Step that failed (pvc, secret, deployment, service, vnc-route or api-route)
+	FailedStep string `json:"failedStep" example:"service"`
+	// Cleanup actions run to remove resources created by this request
+	Rollback []k8s.RollbackAction `json:"rollback"`
+}
+
+// OperationAcceptedResponse is the response for a request accepted for asynchronous processing
+// @Description Asynchronous operation accepted
+type OperationAcceptedResponse struct {
+	// Response message
+	Message string `json:"message" example:"Operation accepted"`
+	// Operation ID
+	OperationID string `json:"operationId" example:"op-3f2a9c1d7b6e4a10"`
+	// Current operation state
+	Status string `json:"status" example:"running"`
+	// URL to poll for progress
+	StatusURL string `json:"statusUrl" example:"/v1/operations/op-3f2a9c1d7b6e4a10"`
+}
+
+// newOperationAcceptedResponse builds the 202 response for an operation
+func newOperationAcceptedResponse(op Operation) OperationAcceptedResponse {
+	return OperationAcceptedResponse{
+		Message:     "Operation accepted",
+		OperationID: op.ID,
+		Status:      op.Status,
+		StatusURL:   "/v1/operations/" + op.ID,
+	}
+}
+
+// ExecRequest is a request to run a command in a sandbox
+// @Description Command to run in the sandbox container
+type ExecRequest struct {
+	// Command and its arguments; no shell is involved unless the command starts one
+	Command []string `json:"command" binding:"required" example:"sh,-c,rm -f /config/browser/user-data/Singleton*"`
+	// Seconds to wait for the command before giving up (default 30, maximum 300)
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty" example:"30"`
+	// Data written to the command's stdin
+	Stdin string `json:"stdin,omitempty" example:""`
+}
+
+// ExecResponse is the result of a command run in a sandbox
+// @Description Output and exit code of a command run in the sandbox container
+type ExecResponse struct {
+	// User ID
+	UserID string `json:"userId" example:"user123"`
+	// Standard output
+	Stdout string `json:"stdout" example:""`
+	// Standard error
+	Stderr string `json:"stderr" example:""`
+	// Exit code of the command, or -1 if it timed out
+	ExitCode int `json:"exitCode" example:"0"`
+	// Whether the command was stopped because the timeout elapsed
+	TimedOut bool `json:"timedOut,omitempty" example:"false"`
+	// Whether stdout or stderr was cut off at the output limit
+	Truncated bool `json:"truncated,omitempty" example:"false"`
+}
+
+// FileListResponse lists a directory in a sandbox
+// @Description Directory listing of a sandbox's data directory
+type FileListResponse struct {
+	// User ID
+	UserID string `json:"userId" example:"user123"`
+	// Directory path relative to /config
+	Path string `json:"path" example:"/downloads"`
+	// Directory entries
+	Files []k8s.FileInfo `json:"files"`
+}
+
+// ResizeStorageRequest is the request body for resizing a sandbox's data volume
+// @Description Sandbox storage resize request
+type ResizeStorageRequest struct {
+	// New size of the user's data volume, as a Kubernetes quantity
+	Size string `json:"size" binding:"required" example:"5Gi"`
+}
+
+// UserSandboxesDeletedResponse is the response for deleting all of a user's sandboxes
+// @Description IDs of the deleted sandboxes
+type UserSandboxesDeletedResponse struct {
+	// Embed the standard response
+	Response
+	// IDs of the sandboxes that were deleted
+	Deleted []string `json:"deleted" example:"user123,user123--research"`
+}
+
+// PurgeResponse is the response for a user data purge
+// @Description Confirmation of the user data that was permanently deleted
+type PurgeResponse struct {
+	// Embed the standard response
+	Response
+	// PVCs and snapshots that were deleted and confirmed gone
+	Deleted []k8s.ResourceAction `json:"deleted"`
+}
+
+// SnapshotRequest is the request body for snapshotting a sandbox
+// @Description Sandbox snapshot request
+type SnapshotRequest struct {
+	// Snapshot name, defaulting to {userId}-{timestamp}
+	Name string `json:"name,omitempty" example:"before-upgrade"`
+}
+
+// SnapshotListResponse lists a sandbox's snapshots
+// @Description Snapshots of a sandbox's data, newest first
+type SnapshotListResponse struct {
+	// User ID
+	UserID string `json:"userId" example:"user123"`
+	// Snapshots of the user's data
+	Snapshots []k8s.SnapshotInfo `json:"snapshots"`
+}
+
+// ResizePoolRequest is the request body for resizing the warm pool
+// @Description Warm pool resize request
+type ResizePoolRequest struct {
+	// Number of warm sandboxes to keep
+	Size *int `json:"size" binding:"required" example:"3"`
+}
+
 // CleanupResponse is the response for cleanup operation
 // @Description Cleanup operation response
 type CleanupResponse struct {
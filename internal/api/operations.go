@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Operation and step states
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+)
+
+// operationRetention is how long finished operations remain queryable
+const operationRetention = 1 * time.Hour
+
+// OperationStep is the progress of a single step of an asynchronous operation
+// @Description Progress of one step of an asynchronous operation
+type OperationStep struct {
+	// Step name
+	Name string `json:"name" example:"deployment"`
+	// Step state (pending, running, succeeded, failed or cancelled)
+	Status string `json:"status" example:"succeeded"`
+	// Time the step started
+	StartedAt string `json:"startedAt,omitempty" example:"2023-04-20T12:00:00Z"`
+	// Time the step finished
+	CompletedAt string `json:"completedAt,omitempty" example:"2023-04-20T12:00:01Z"`
+}
+
+// Operation is an asynchronous sandbox create or delete
+// @Description Asynchronous sandbox operation with per-step progress
+type Operation struct {
+	// Operation ID
+	ID string `json:"id" example:"op-3f2a9c1d7b6e4a10"`
+	// Operation type (create or delete)
+	Type string `json:"type" example:"create"`
+	// User ID
+	UserID string `json:"userId" example:"user123"`
+	// Tenant of the sandbox, empty for the default namespace
+	Tenant string `json:"tenant,omitempty" example:"acme"`
+	// Overall state (pending, running, succeeded, failed or cancelled)
+	Status string `json:"status" example:"running"`
+	// Per-step progress
+	Steps []OperationStep `json:"steps"`
+	// Error message if the operation failed
+	Error string `json:"error,omitempty" example:""`
+	// Final result, the same body the synchronous endpoint would return
+	Result interface{} `json:"result,omitempty"`
+	// Time the operation was accepted
+	CreatedAt string `json:"createdAt" example:"2023-04-20T12:00:00Z"`
+	// Time the operation last changed
+	UpdatedAt string `json:"updatedAt" example:"2023-04-20T12:00:01Z"`
+
+	cancel     context.CancelFunc
+	finishedAt time.Time
+}
+
+// errOperationFinished is returned when cancelling an operation that has already finished
+var errOperationFinished = errors.New("operation has already finished")
+
+// OperationStore keeps asynchronous operations in memory
+type OperationStore struct {
+	mu         sync.Mutex
+	operations map[string]*Operation
+}
+
+// NewOperationStore creates an empty operation store
+func NewOperationStore() *OperationStore {
+	return &OperationStore{
+		operations: make(map[string]*Operation),
+	}
+}
+
+// Start registers a new operation and runs fn in the background.
+// fn returns the result body and an error; steps are advanced as fn reports progress.
+func (s *OperationStore) Start(tenant, opType, userID string, steps []string,
+	fn func(ctx context.Context, progress func(step string)) (interface{}, error)) Operation {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	now := time.Now()
+	op := &Operation{
+		ID:        newOperationID(),
+		Type:      opType,
+		UserID:    userID,
+		Tenant:    tenant,
+		Status:    OperationRunning,
+		CreatedAt: now.Format(time.RFC3339),
+		UpdatedAt: now.Format(time.RFC3339),
+		cancel:    cancel,
+	}
+	for _, step := range steps {
+		op.Steps = append(op.Steps, OperationStep{Name: step, Status: OperationPending})
+	}
+	if len(op.Steps) > 0 {
+		op.Steps[0].Status = OperationRunning
+		op.Steps[0].StartedAt = op.CreatedAt
+	}
+
+	s.mu.Lock()
+	s.pruneLocked(now)
+	s.operations[op.ID] = op
+	snapshot := op.snapshot()
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := fn(ctx, func(step string) {
+			s.completeStep(op.ID, step)
+		})
+		s.finish(op.ID, result, err, ctx.Err() != nil)
+	}()
+
+	return snapshot
+}
+
+// Get returns a copy of the operation with the given ID
+func (s *OperationStore) Get(id string) (Operation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return op.snapshot(), true
+}
+
+// Cancel cancels a running operation. Work already done is rolled back by the operation itself.
+func (s *OperationStore) Cancel(id string) (Operation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return Operation{}, false, nil
+	}
+	if op.Status != OperationRunning {
+		return op.snapshot(), true, errOperationFinished
+	}
+	op.cancel()
+	return op.snapshot(), true, nil
+}
+
+// completeStep marks a step as succeeded and starts the next pending step
+func (s *OperationStore) completeStep(id, step string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for i := range op.Steps {
+		if op.Steps[i].Name != step {
+			continue
+		}
+		op.Steps[i].Status = OperationSucceeded
+		op.Steps[i].CompletedAt = now
+		if i+1 < len(op.Steps) && op.Steps[i+1].Status == OperationPending {
+			op.Steps[i+1].Status = OperationRunning
+			op.Steps[i+1].StartedAt = now
+		}
+		break
+	}
+	op.UpdatedAt = now
+}
+
+// finish records the outcome of an operation. The running step takes the final state.
+func (s *OperationStore) finish(id string, result interface{}, err error, cancelled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	status := OperationSucceeded
+	if err != nil {
+		status = OperationFailed
+		if cancelled {
+			status = OperationCancelled
+		}
+		op.Error = err.Error()
+	}
+
+	for i := range op.Steps {
+		if op.Steps[i].Status == OperationRunning {
+			op.Steps[i].Status = status
+			op.Steps[i].CompletedAt = now.Format(time.RFC3339)
+		}
+	}
+	op.Status = status
+	op.Result = result
+	op.UpdatedAt = now.Format(time.RFC3339)
+	op.finishedAt = now
+}
+
+// pruneLocked removes operations that finished longer ago than the retention period
+func (s *OperationStore) pruneLocked(now time.Time) {
+	for id, op := range s.operations {
+		if !op.finishedAt.IsZero() && now.Sub(op.finishedAt) > operationRetention {
+			delete(s.operations, id)
+		}
+	}
+}
+
+// snapshot returns a copy of the operation that is safe to use outside the store lock
+func (op *Operation) snapshot() Operation {
+	out := *op
+	out.Steps = append([]OperationStep(nil), op.Steps...)
+	return out
+}
+
+// newOperationID returns a random operation ID
+func newOperationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "op-" + time.Now().Format("20060102150405.000000000")
+	}
+	return "op-" + hex.EncodeToString(b)
+}
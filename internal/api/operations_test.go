@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForOperation polls the store until the operation leaves the running state
+func waitForOperation(t *testing.T, store *OperationStore, id string) Operation {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		op, ok := store.Get(id)
+		if !ok {
+			t.Fatalf("operation %s not found", id)
+		}
+		if op.Status != OperationRunning {
+			return op
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("operation %s did not finish", id)
+	return Operation{}
+}
+
+func TestOperationStoreSteps(t *testing.T) {
+	testCases := []struct {
+		name           string
+		completed      []string
+		err            error
+		expectedStatus string
+		expectedSteps  []string
+	}{
+		{"All steps succeed", []string{"pvc", "deployment", "service"}, nil, OperationSucceeded,
+			[]string{OperationSucceeded, OperationSucceeded, OperationSucceeded}},
+		{"Failure marks running step", []string{"pvc"}, errors.New("boom"), OperationFailed,
+			[]string{OperationSucceeded, OperationFailed, OperationPending}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewOperationStore()
+			op := store.Start("", "create", "user123", []string{"pvc", "deployment", "service"},
+				func(ctx context.Context, progress func(string)) (interface{}, error) {
+					for _, step := range tc.completed {
+						progress(step)
+					}
+					return nil, tc.err
+				})
+
+			finished := waitForOperation(t, store, op.ID)
+			if finished.Status != tc.expectedStatus {
+				t.Errorf("Expected status %q, got %q", tc.expectedStatus, finished.Status)
+			}
+			for i, step := range finished.Steps {
+				if step.Status != tc.expectedSteps[i] {
+					t.Errorf("Expected step %s to be %q, got %q", step.Name, tc.expectedSteps[i], step.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestOperationStoreCancel(t *testing.T) {
+	store := NewOperationStore()
+	op := store.Start("", "create", "user123", []string{"pvc"},
+		func(ctx context.Context, progress func(string)) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	if _, ok, err := store.Cancel(op.ID); !ok || err != nil {
+		t.Fatalf("Expected cancel to succeed, got ok=%v err=%v", ok, err)
+	}
+
+	finished := waitForOperation(t, store, op.ID)
+	if finished.Status != OperationCancelled {
+		t.Errorf("Expected status %q, got %q", OperationCancelled, finished.Status)
+	}
+	if _, _, err := store.Cancel(op.ID); err == nil {
+		t.Errorf("Expected cancelling a finished operation to fail")
+	}
+}
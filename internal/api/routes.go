@@ -23,21 +23,70 @@ func RegisterRoutes(router *gin.Engine, k8sClient *k8s.ClientWithTraefik, appCon
 	v1 := router.Group("/v1")
 	v1.Use(AuthMiddleware(appConfig))
 	{
-		// Sandbox endpoints
-		sandbox := v1.Group("/sandbox")
+		registerSandboxRoutes(v1, sandboxHandler)
+
+		// The same endpoints scoped to a tenant's namespace
+		tenant := v1.Group("/tenants/:tenant")
+		tenant.Use(TenantMiddleware())
+		registerSandboxRoutes(tenant, sandboxHandler)
+
+		// Asynchronous operation endpoints
+		operations := v1.Group("/operations")
 		{
-			sandbox.POST("/:userId", sandboxHandler.CreateSandbox)
-			sandbox.DELETE("/:userId", sandboxHandler.DeleteSandbox)
-			sandbox.GET("/:userId/status", sandboxHandler.GetSandboxStatus)
+			operations.GET("/:id", sandboxHandler.GetOperation)
+			operations.DELETE("/:id", sandboxHandler.CancelOperation)
 		}
 
-		// List sandboxes endpoint
-		v1.GET("/sandboxes", sandboxHandler.ListSandboxes)
-
 		// Admin endpoints
 		admin := v1.Group("/admin")
+		admin.Use(OperatorOnly())
 		{
-			admin.POST("/cleanup", sandboxHandler.TriggerCleanup)
+			admin.GET("/pool", sandboxHandler.GetPoolStatus)
+			admin.PUT("/pool", sandboxHandler.ResizePool)
+			admin.GET("/capacity", sandboxHandler.GetClusterCapacity)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// registerSandboxRoutes registers the endpoints that manage the sandboxes of one namespace
+func registerSandboxRoutes(group *gin.RouterGroup, sandboxHandler *SandboxHandler) {
+	// Sandbox endpoints
+	sandbox := group.Group("/sandbox")
+	{
+		sandbox.POST("/:userId", sandboxHandler.CreateSandbox)
+		sandbox.DELETE("/:userId", sandboxHandler.DeleteSandbox)
+		sandbox.DELETE("/:userId/data", sandboxHandler.PurgeSandboxData)
+		sandbox.GET("/:userId/status", sandboxHandler.GetSandboxStatus)
+		sandbox.POST("/:userId/pause", sandboxHandler.PauseSandbox)
+		sandbox.POST("/:userId/resume", sandboxHandler.ResumeSandbox)
+		sandbox.POST("/:userId/extend", sandboxHandler.ExtendSandbox)
+		sandbox.POST("/:userId/keepalive", sandboxHandler.KeepaliveSandbox)
+		sandbox.GET("/:userId/events", sandboxHandler.StreamSandboxEvents)
+		sandbox.GET("/:userId/exec", sandboxHandler.ExecSandboxShell)
+		sandbox.POST("/:userId/exec", sandboxHandler.RunSandboxCommand)
+		sandbox.GET("/:userId/logs", sandboxHandler.GetSandboxLogs)
+		sandbox.GET("/:userId/files", sandboxHandler.DownloadSandboxFile)
+		sandbox.PUT("/:userId/files", sandboxHandler.UploadSandboxFile)
+		sandbox.GET("/:userId/files/list", sandboxHandler.ListSandboxFiles)
+		sandbox.GET("/:userId/storage", sandboxHandler.GetSandboxStorage)
+		sandbox.PATCH("/:userId/storage", sandboxHandler.ResizeSandboxStorage)
+		sandbox.POST("/:userId/snapshots", sandboxHandler.CreateSnapshot)
+		sandbox.GET("/:userId/snapshots", sandboxHandler.ListSnapshots)
+		sandbox.POST("/:userId/snapshots/:name/restore", sandboxHandler.RestoreSnapshot)
+	}
+
+	// Named sandbox endpoints, grouped by user
+	users := group.Group("/users")
+	{
+		users.POST("/:userId/sandboxes", sandboxHandler.CreateUserSandbox)
+		users.GET("/:userId/sandboxes", sandboxHandler.ListUserSandboxes)
+		users.DELETE("/:userId/sandboxes", sandboxHandler.DeleteUserSandboxes)
+	}
+
+	// List sandboxes endpoint
+	group.GET("/sandboxes", sandboxHandler.ListSandboxes)
+	group.GET("/sandboxes/events", sandboxHandler.StreamAllSandboxEvents)
+
+	// Cleanup of the sandboxes in the group's namespace
+	group.POST("/admin/cleanup", sandboxHandler.TriggerCleanup)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
+
+// CreateSnapshot takes a snapshot of a user's sandbox data
+// @Summary      Snapshot a sandbox's data
+// @Description  Creates a VolumeSnapshot of the PVC holding the user's data. The snapshot is taken in the background
+// @Description  and can be restored once readyToUse is true. The name defaults to {userId}-{timestamp}.
+// @Tags         snapshots
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        request body SnapshotRequest false "Snapshot name"
+// @Success      201 {object} k8s.SnapshotInfo
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/snapshots [post]
+func (h *SandboxHandler) CreateSnapshot(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	// The body is optional
+	var request SnapshotRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "Invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	snapshot, err := h.client(c).CreateSandboxSnapshot(c.Request.Context(), userID, request.Name)
+	if err != nil {
+		respondSnapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// ListSnapshots lists the snapshots of a user's sandbox data
+// @Summary      List a sandbox's snapshots
+// @Description  Lists the user's VolumeSnapshots, newest first
+// @Tags         snapshots
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} SnapshotListResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/snapshots [get]
+func (h *SandboxHandler) ListSnapshots(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	snapshots, err := h.client(c).ListSandboxSnapshots(c.Request.Context(), userID)
+	if err != nil {
+		respondSnapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, SnapshotListResponse{
+		UserID:    userID,
+		Snapshots: snapshots,
+	})
+}
+
+// RestoreSnapshot replaces a user's sandbox data with a snapshot
+// @Summary      Restore a sandbox from a snapshot
+// @Description  Scales the sandbox to zero, recreates its PVC from the snapshot and scales it back up.
+// @Description  Everything written since the snapshot was taken is lost. If the PVC must grow to hold the snapshot
+// @Description  and that exceeds the tenant's or user's storage quota, nothing is changed and 403 is returned.
+// @Tags         snapshots
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        name path string true "Snapshot name"
+// @Success      200 {object} Response
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} QuotaExceededResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      429 {object} QuotaExceededResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/snapshots/{name}/restore [post]
+func (h *SandboxHandler) RestoreSnapshot(c *gin.Context) {
+	userID := c.Param("userId")
+	name := c.Param("name")
+	if userID == "" || name == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID and snapshot name are required",
+		})
+		return
+	}
+
+	if err := h.client(c).RestoreSandboxSnapshot(c.Request.Context(), userID, name); err != nil {
+		respondSnapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Message: "Snapshot restored successfully",
+		UserID:  userID,
+	})
+}
+
+// respondSnapshotError maps snapshot errors to HTTP status codes
+func respondSnapshotError(c *gin.Context, err error) {
+	if status, body, ok := quotaErrorResponse(err); ok {
+		c.JSON(status, body)
+		return
+	}
+
+	switch {
+	case errors.Is(err, k8s.ErrInvalidSandboxOptions):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, k8s.ErrSnapshotNotReady):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: err.Error(),
+		})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
+
+// GetSandboxStorage reports the size of a user's data volume
+// @Summary      Get sandbox storage
+// @Description  Reports the requested and provisioned size of the user's PVC and the progress of any resize
+// @Tags         sandbox
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} k8s.StorageInfo
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/storage [get]
+func (h *SandboxHandler) GetSandboxStorage(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	info, err := h.client(c).GetSandboxStorage(c.Request.Context(), userID)
+	if err != nil {
+		respondStorageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// ResizeSandboxStorage expands a user's data volume
+// @Summary      Resize sandbox storage
+// @Description  Expands the user's PVC in place. Volumes can only grow, and the storage class must allow expansion.
+// @Description  The response and GET /storage report progress; FileSystemResizePending means the file system grows
+// @Description  when the sandbox is next started, so restartRequired is set until it is paused and resumed.
+// @Description  Growing the volume past the tenant's or user's storage quota fails with 403.
+// @Tags         sandbox
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        request body ResizeStorageRequest true "New size"
+// @Success      200 {object} k8s.StorageInfo
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} QuotaExceededResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/sandbox/{userId}/storage [patch]
+func (h *SandboxHandler) ResizeSandboxStorage(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	var request ResizeStorageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	info, err := h.client(c).ResizeSandboxStorage(c.Request.Context(), userID, request.Size)
+	if err != nil {
+		respondStorageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// respondStorageError maps storage errors to HTTP status codes
+func respondStorageError(c *gin.Context, err error) {
+	if status, body, ok := quotaErrorResponse(err); ok {
+		c.JSON(status, body)
+		return
+	}
+
+	switch {
+	case errors.Is(err, k8s.ErrInvalidSandboxOptions):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, k8s.ErrStorageNotExpandable):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: err.Error(),
+		})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+}
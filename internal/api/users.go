@@ -0,0 +1,162 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shanurcsenitap/irisk8s/internal/k8s"
+)
+
+// CreateUserSandbox creates one of a user's named sandboxes
+// @Summary      Create a named sandbox for a user
+// @Description  Creates an additional sandbox for a user, named by the request or generated. The sandbox is identified by
+// @Description  the returned sandboxId ({userId}--{name}), which is used in place of the user ID in the /v1/sandbox/{userId}
+// @Description  endpoints and in its hostnames. With storage=shared the sandbox mounts the user's default sandbox volume
+// @Description  instead of its own; the storage mode is fixed when the sandbox is first created.
+// @Description  Supports the same async, wait and timeout parameters and queueing as POST /v1/sandbox/{userId}.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Param        async query bool false "Run asynchronously and return an operation ID"
+// @Param        wait query string false "Set to ready to wait for the readiness probe" Enums(ready)
+// @Param        timeout query string false "How long to wait for readiness, e.g. 120s (default 2m, max 10m)"
+// @Param        request body NamedSandboxRequest false "Optional sandbox name and settings"
+// @Success      200 {object} SandboxResponse
+// @Success      201 {object} SandboxResponse
+// @Success      202 {object} OperationAcceptedResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} QuotaExceededResponse
+// @Failure      429 {object} QuotaExceededResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} SandboxCreateErrorResponse
+// @Failure      503 {object} InsufficientCapacityResponse
+// @Failure      504 {object} SandboxNotReadyResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/users/{userId}/sandboxes [post]
+func (h *SandboxHandler) CreateUserSandbox(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	// The body is optional
+	var request NamedSandboxRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "Invalid request format: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	waitReady, readyTimeout, err := parseReadyWait(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	opts := request.options()
+	opts.Owner = userID
+	opts.Name = request.Name
+	switch request.Storage {
+	case "", storageIsolated:
+	case storageShared:
+		opts.SharedStorage = true
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "storage must be isolated or shared",
+		})
+		return
+	}
+
+	if opts.Name == "" {
+		name, err := k8s.NewSandboxName()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		opts.Name = name
+	}
+
+	h.startCreate(c, k8s.SandboxID(userID, opts.Name), opts, waitReady, readyTimeout)
+}
+
+// ListUserSandboxes lists a user's sandboxes
+// @Summary      List a user's sandboxes
+// @Description  Lists the user's default sandbox and named sandboxes
+// @Tags         users
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} SandboxListResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/users/{userId}/sandboxes [get]
+func (h *SandboxHandler) ListUserSandboxes(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	sandboxes, err := h.client(c).ListUserSandboxes(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SandboxListResponse{
+		Count:     len(sandboxes),
+		Sandboxes: sandboxes,
+	})
+}
+
+// DeleteUserSandboxes deletes all of a user's sandboxes
+// @Summary      Delete all of a user's sandboxes
+// @Description  Deletes the user's default sandbox and named sandboxes. Data volumes are kept; use DELETE /v1/sandbox/{userId}/data to purge them.
+// @Tags         users
+// @Produce      json
+// @Param        userId path string true "User ID"
+// @Success      200 {object} UserSandboxesDeletedResponse
+// @Failure      400 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     ApiKeyAuth
+// @Router       /v1/users/{userId}/sandboxes [delete]
+func (h *SandboxHandler) DeleteUserSandboxes(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "User ID is required",
+		})
+		return
+	}
+
+	deleted, err := h.client(c).DeleteUserSandboxes(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserSandboxesDeletedResponse{
+		Response: Response{
+			Message: "Sandboxes deleted successfully",
+			UserID:  userID,
+		},
+		Deleted: deleted,
+	})
+}
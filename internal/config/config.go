@@ -1,6 +1,9 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -12,24 +15,93 @@ import (
 const (
 	// DefaultSandboxTimeoutMinutes is the default duration in minutes after which a sandbox will be automatically deleted
 	DefaultSandboxTimeoutMinutes = 30
+	// DefaultSandboxMaxTTLMinutes is the default upper bound for a sandbox TTL or extension, measured from now
+	DefaultSandboxMaxTTLMinutes = 24 * 60
+	// DefaultSandboxQueueSize is how many creates may wait for capacity unless configured otherwise
+	DefaultSandboxQueueSize = 100
+	// DefaultSandboxPreemptIdleMinutes is how long a sandbox must be idle before it may be paused for a higher tier
+	DefaultSandboxPreemptIdleMinutes = 15
+	// DefaultSandboxIdleAction is what happens to a sandbox that has been idle for too long
+	DefaultSandboxIdleAction = IdleActionPause
+	// DefaultImageRegistry is the registry path sandbox images may be pulled from unless configured otherwise
+	DefaultImageRegistry = "us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo"
+	// DefaultFileHelperImage is the image of the pods that give file access to paused sandboxes; it needs sh, cat and stat
+	DefaultFileHelperImage = "busybox:1.36.1"
 	// DefaultAPIKey is the default API key for securing endpoints
 	DefaultAPIKey = "default-secret-key"
 	// SecretMountPath is the directory where secrets are mounted
 	SecretMountPath = "/etc/config"
 )
 
+// Idle actions
+const (
+	// IdleActionPause scales an idle sandbox to zero, keeping its data and URLs
+	IdleActionPause = "pause"
+	// IdleActionDelete deletes an idle sandbox
+	IdleActionDelete = "delete"
+)
+
 // Configuration holds all configurable parameters for the application
 type Configuration struct {
 	// SandboxTimeoutDuration is the duration after which a sandbox will be automatically deleted
 	SandboxTimeoutDuration time.Duration
+	// SandboxMaxTTL is the longest a sandbox may be kept alive ahead of the current time
+	SandboxMaxTTL time.Duration
+	// SandboxIdleTimeout is how long a sandbox may go without activity before the idle action runs; zero disables idle cleanup
+	SandboxIdleTimeout time.Duration
+	// SandboxIdleAction is what happens to an idle sandbox (pause or delete)
+	SandboxIdleAction string
+	// SandboxActivityPath is a path on the sandbox's port 3000 API reporting its last activity; empty disables polling
+	SandboxActivityPath string
+	// SandboxProfiles are the sandbox sizes a create request can choose from, keyed by name
+	SandboxProfiles map[string]SandboxProfile
+	// DefaultSandboxProfile is the profile used when a create request does not name one
+	DefaultSandboxProfile string
+	// AllowedImageTags are the agent image tags a create request may choose; entries may use * wildcards
+	AllowedImageTags []string
+	// AllowedImageRegistries are the registry paths a full image reference in a create request must start with
+	AllowedImageRegistries []string
+	// AllowedEnvKeys are the plain environment variable names a create request may set; entries may use * wildcards
+	AllowedEnvKeys []string
+	// SandboxPoolSize is the number of warm, unassigned sandboxes to keep ready; zero disables the pool
+	SandboxPoolSize int
+	// SnapshotClass is the VolumeSnapshotClass used for sandbox snapshots; empty uses the cluster default
+	SnapshotClass string
+	// SharedStorageClass is a StorageClass with ReadWriteMany volumes for users' default data volumes,
+	// which named sandboxes can then share; empty disables shared storage
+	SharedStorageClass string
+	// SandboxDataRetention is how long a user's data is kept after their last sandbox; zero keeps it forever
+	SandboxDataRetention time.Duration
+	// SandboxCapacityCheck rejects sandboxes that no node has room for instead of leaving them Pending
+	SandboxCapacityCheck bool
+	// SandboxQueueSize is how many creates may wait for capacity instead of failing; zero disables the queue
+	SandboxQueueSize int
+	// SandboxQuotas limit the sandboxes of each tenant and user
+	SandboxQuotas SandboxQuotas
+	// SandboxTenantTiers maps tenant IDs to the priority tier of their sandboxes; other tenants get the default tier
+	SandboxTenantTiers map[string]string
+	// SandboxPreemptIdle is how long a sandbox must go without activity before it may be paused for a higher tier
+	SandboxPreemptIdle time.Duration
+	// SandboxCrossTenantPreemption are the tiers whose sandboxes may be paused to make room in another namespace
+	SandboxCrossTenantPreemption []string
+	// FileHelperImage is the pinned image of the pods that give file access to paused sandboxes
+	FileHelperImage string
 	// APIKey is the secret key for authenticating requests
 	APIKey string
+	// TenantAPIKeys maps API keys that are limited to one tenant's sandboxes to the tenant ID
+	TenantAPIKeys map[string]string
 }
 
 // GetConfig returns the application configuration, populated from environment variables or defaults
 func GetConfig() *Configuration {
 	config := &Configuration{
 		SandboxTimeoutDuration: time.Duration(DefaultSandboxTimeoutMinutes) * time.Minute,
+		SandboxMaxTTL:          time.Duration(DefaultSandboxMaxTTLMinutes) * time.Minute,
+		SandboxIdleAction:      DefaultSandboxIdleAction,
+		SandboxCapacityCheck:   true,
+		SandboxQueueSize:       DefaultSandboxQueueSize,
+		SandboxPreemptIdle:     time.Duration(DefaultSandboxPreemptIdleMinutes) * time.Minute,
+		FileHelperImage:        DefaultFileHelperImage,
 	}
 
 	// Override from environment if available
@@ -39,6 +111,77 @@ func GetConfig() *Configuration {
 		}
 	}
 
+	if envMaxTTL := readSecret("SANDBOX_MAX_TTL_MINUTES"); envMaxTTL != "" {
+		if minutes, err := strconv.Atoi(envMaxTTL); err == nil && minutes > 0 {
+			config.SandboxMaxTTL = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	if envIdle := readSecret("SANDBOX_IDLE_TIMEOUT_MINUTES"); envIdle != "" {
+		if minutes, err := strconv.Atoi(envIdle); err == nil && minutes > 0 {
+			config.SandboxIdleTimeout = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	if idleAction := readSecret("SANDBOX_IDLE_ACTION"); idleAction == IdleActionPause || idleAction == IdleActionDelete {
+		config.SandboxIdleAction = idleAction
+	}
+
+	config.SandboxActivityPath = readSecret("SANDBOX_ACTIVITY_PATH")
+
+	loadSandboxProfiles(config)
+	loadSandboxQuotas(config)
+	if envCapacity := readSecret("SANDBOX_CAPACITY_CHECK"); envCapacity != "" {
+		if check, err := strconv.ParseBool(envCapacity); err == nil {
+			config.SandboxCapacityCheck = check
+		}
+	}
+
+	if envQueue := readSecret("SANDBOX_QUEUE_SIZE"); envQueue != "" {
+		if size, err := strconv.Atoi(envQueue); err == nil && size >= 0 {
+			config.SandboxQueueSize = size
+		}
+	}
+
+	if data := readSecret("SANDBOX_TENANT_TIERS"); data != "" {
+		var tiers map[string]string
+		if err := json.Unmarshal([]byte(data), &tiers); err != nil {
+			log.Printf("Ignoring invalid SANDBOX_TENANT_TIERS: %v", err)
+		} else {
+			config.SandboxTenantTiers = tiers
+		}
+	}
+	if envPreemptIdle := readSecret("SANDBOX_PREEMPT_IDLE_MINUTES"); envPreemptIdle != "" {
+		if minutes, err := strconv.Atoi(envPreemptIdle); err == nil && minutes >= 0 {
+			config.SandboxPreemptIdle = time.Duration(minutes) * time.Minute
+		}
+	}
+	config.SandboxCrossTenantPreemption = splitList(readSecret("SANDBOX_CROSS_TENANT_PREEMPTION"))
+
+	config.AllowedImageTags = splitList(readSecret("SANDBOX_ALLOWED_IMAGE_TAGS"))
+	if envPool := readSecret("SANDBOX_POOL_SIZE"); envPool != "" {
+		if size, err := strconv.Atoi(envPool); err == nil && size >= 0 {
+			config.SandboxPoolSize = size
+		}
+	}
+	config.SnapshotClass = readSecret("SANDBOX_SNAPSHOT_CLASS")
+	config.SharedStorageClass = readSecret("SANDBOX_SHARED_STORAGE_CLASS")
+	if image := readSecret("SANDBOX_FILE_HELPER_IMAGE"); image != "" {
+		config.FileHelperImage = image
+	}
+
+	if envRetention := readSecret("SANDBOX_DATA_RETENTION_DAYS"); envRetention != "" {
+		if days, err := strconv.Atoi(envRetention); err == nil && days > 0 {
+			config.SandboxDataRetention = time.Duration(days) * 24 * time.Hour
+		}
+	}
+
+	config.AllowedEnvKeys = splitList(readSecret("SANDBOX_ALLOWED_ENV_KEYS"))
+	config.AllowedImageRegistries = []string{DefaultImageRegistry}
+	if registries := splitList(readSecret("SANDBOX_ALLOWED_IMAGE_REGISTRIES")); len(registries) > 0 {
+		config.AllowedImageRegistries = registries
+	}
+
 	// Get API key from environment or use default
 	if apiKey := readSecret("API_KEY"); apiKey != "" {
 		config.APIKey = apiKey
@@ -46,9 +189,38 @@ func GetConfig() *Configuration {
 		config.APIKey = DefaultAPIKey
 	}
 
+	if data := readSecret("TENANT_API_KEYS"); data != "" {
+		keys, err := parseTenantAPIKeys(data)
+		if err != nil {
+			log.Printf("Ignoring invalid TENANT_API_KEYS: %v", err)
+		} else {
+			config.TenantAPIKeys = keys
+		}
+	}
+
 	return config
 }
 
+// parseTenantAPIKeys parses a JSON object of tenant IDs to API keys and returns the tenant of each key
+func parseTenantAPIKeys(data string) (map[string]string, error) {
+	var tenants map[string]string
+	if err := json.Unmarshal([]byte(data), &tenants); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string, len(tenants))
+	for tenant, key := range tenants {
+		if tenant == "" || key == "" {
+			return nil, fmt.Errorf("tenant IDs and API keys must not be empty")
+		}
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("API key of tenant %q is used by another tenant", tenant)
+		}
+		keys[key] = tenant
+	}
+	return keys, nil
+}
+
 func readSecret(key string) string {
 	// Check environment variable first
 	if value := os.Getenv(key); value != "" {
@@ -63,3 +235,14 @@ func readSecret(key string) string {
 
 	return ""
 }
+
+// splitList splits a comma-separated setting into its non-empty, trimmed entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
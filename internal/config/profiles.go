@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// DefaultSandboxProfile is the profile used when a create request does not name one
+const DefaultSandboxProfile = "standard"
+
+// SandboxProfile is a named sandbox size. Quantities use Kubernetes notation, e.g. "500m" or "4Gi".
+type SandboxProfile struct {
+	CPURequest    string `json:"cpuRequest"`
+	CPULimit      string `json:"cpuLimit"`
+	MemoryRequest string `json:"memoryRequest"`
+	MemoryLimit   string `json:"memoryLimit"`
+	ShmSize       string `json:"shmSize"`
+	StorageSize   string `json:"storageSize"`
+	StorageClass  string `json:"storageClass"`
+}
+
+// defaultSandboxProfiles are the built-in profiles; standard matches the original fixed sandbox size
+func defaultSandboxProfiles() map[string]SandboxProfile {
+	return map[string]SandboxProfile{
+		"small": {
+			CPURequest:    "500m",
+			CPULimit:      "1",
+			MemoryRequest: "1Gi",
+			MemoryLimit:   "2Gi",
+			ShmSize:       "512Mi",
+			StorageSize:   "1Gi",
+			StorageClass:  "standard-rwo",
+		},
+		"standard": {
+			CPURequest:    "1",
+			CPULimit:      "2",
+			MemoryRequest: "2Gi",
+			MemoryLimit:   "4Gi",
+			ShmSize:       "1Gi",
+			StorageSize:   "1Gi",
+			StorageClass:  "standard-rwo",
+		},
+		"large": {
+			CPURequest:    "2",
+			CPULimit:      "4",
+			MemoryRequest: "4Gi",
+			MemoryLimit:   "8Gi",
+			ShmSize:       "2Gi",
+			StorageSize:   "5Gi",
+			StorageClass:  "standard-rwo",
+		},
+	}
+}
+
+// parseSandboxProfiles reads profiles from a JSON object keyed by profile name.
+// Profiles replace the built-in set; fields left out are taken from the built-in standard profile.
+func parseSandboxProfiles(data string) (map[string]SandboxProfile, error) {
+	var overrides map[string]SandboxProfile
+	if err := json.Unmarshal([]byte(data), &overrides); err != nil {
+		return nil, err
+	}
+	if len(overrides) == 0 {
+		return nil, fmt.Errorf("no profiles defined")
+	}
+
+	base := defaultSandboxProfiles()[DefaultSandboxProfile]
+	profiles := make(map[string]SandboxProfile, len(overrides))
+	for name, profile := range overrides {
+		profiles[name] = profile.withDefaults(base)
+	}
+	return profiles, nil
+}
+
+// withDefaults fills empty fields from another profile
+func (p SandboxProfile) withDefaults(base SandboxProfile) SandboxProfile {
+	fill := func(value *string, fallback string) {
+		if *value == "" {
+			*value = fallback
+		}
+	}
+	fill(&p.CPURequest, base.CPURequest)
+	fill(&p.CPULimit, base.CPULimit)
+	fill(&p.MemoryRequest, base.MemoryRequest)
+	fill(&p.MemoryLimit, base.MemoryLimit)
+	fill(&p.ShmSize, base.ShmSize)
+	fill(&p.StorageSize, base.StorageSize)
+	fill(&p.StorageClass, base.StorageClass)
+	return p
+}
+
+// loadSandboxProfiles sets the profiles and default profile from SANDBOX_PROFILES and
+// SANDBOX_DEFAULT_PROFILE, keeping the built-in profiles if the JSON is invalid
+func loadSandboxProfiles(config *Configuration) {
+	config.SandboxProfiles = defaultSandboxProfiles()
+	config.DefaultSandboxProfile = DefaultSandboxProfile
+
+	if data := readSecret("SANDBOX_PROFILES"); data != "" {
+		profiles, err := parseSandboxProfiles(data)
+		if err != nil {
+			log.Printf("Ignoring invalid SANDBOX_PROFILES: %v", err)
+		} else {
+			config.SandboxProfiles = profiles
+		}
+	}
+
+	if name := readSecret("SANDBOX_DEFAULT_PROFILE"); name != "" {
+		config.DefaultSandboxProfile = name
+	}
+	if _, ok := config.SandboxProfiles[config.DefaultSandboxProfile]; !ok {
+		log.Printf("Default sandbox profile %q is not defined", config.DefaultSandboxProfile)
+	}
+}
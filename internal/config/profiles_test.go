@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParseSandboxProfiles(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		expectedError bool
+		profile       string
+		expected      SandboxProfile
+	}{
+		{"Full profile", `{"xl": {"cpuRequest": "4", "cpuLimit": "8", "memoryRequest": "8Gi", "memoryLimit": "16Gi", "shmSize": "4Gi", "storageSize": "20Gi", "storageClass": "premium-rwo"}}`, false, "xl",
+			SandboxProfile{"4", "8", "8Gi", "16Gi", "4Gi", "20Gi", "premium-rwo"}},
+		{"Partial profile uses standard defaults", `{"big-disk": {"storageSize": "10Gi"}}`, false, "big-disk",
+			SandboxProfile{"1", "2", "2Gi", "4Gi", "1Gi", "10Gi", "standard-rwo"}},
+		{"Invalid JSON", `{"xl": `, true, "", SandboxProfile{}},
+		{"No profiles", `{}`, true, "", SandboxProfile{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profiles, err := parseSandboxProfiles(tc.input)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("Expected error %v, got %v", tc.expectedError, err)
+			}
+			if tc.expectedError {
+				return
+			}
+			if got := profiles[tc.profile]; got != tc.expected {
+				t.Errorf("Expected profile %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Quota limits the sandboxes of a tenant or a user. Quantities use Kubernetes notation;
+// zero or empty fields are unlimited.
+type Quota struct {
+	// MaxSandboxes is the number of sandboxes that may run at once
+	MaxSandboxes int `json:"maxSandboxes"`
+	// CPU is the total CPU limit of the running sandboxes
+	CPU string `json:"cpu"`
+	// Memory is the total memory limit of the running sandboxes
+	Memory string `json:"memory"`
+	// Storage is the total size of the data volumes, including those of paused and deleted sandboxes
+	Storage string `json:"storage"`
+}
+
+// IsZero reports whether the quota sets no limits
+func (q Quota) IsZero() bool {
+	return q == Quota{}
+}
+
+// SandboxQuotas are the limits enforced when sandboxes are created or resumed
+type SandboxQuotas struct {
+	// Tenant limits each tenant's namespace
+	Tenant Quota `json:"tenant"`
+	// Tenants replaces the tenant quota for individual tenants
+	Tenants map[string]Quota `json:"tenants"`
+	// User limits each user's sandboxes within a namespace
+	User Quota `json:"user"`
+}
+
+// ForTenant returns the quota of a tenant
+func (q SandboxQuotas) ForTenant(tenant string) Quota {
+	if quota, ok := q.Tenants[tenant]; ok {
+		return quota
+	}
+	return q.Tenant
+}
+
+// loadSandboxQuotas sets the quotas from SANDBOX_QUOTAS, leaving them unlimited if the JSON is invalid
+func loadSandboxQuotas(config *Configuration) {
+	data := readSecret("SANDBOX_QUOTAS")
+	if data == "" {
+		return
+	}
+
+	var quotas SandboxQuotas
+	if err := json.Unmarshal([]byte(data), &quotas); err != nil {
+		log.Printf("Ignoring invalid SANDBOX_QUOTAS: %v", err)
+		return
+	}
+	config.SandboxQuotas = quotas
+}
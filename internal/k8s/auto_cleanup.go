@@ -54,11 +54,11 @@ func (c *Client) cleanupExpiredSandboxes(ctx context.Context) error {
 
 	now := time.Now()
 	for _, deployment := range deployments.Items {
-		// Check if the deployment has been running for more than the configured timeout
-		creationTime := deployment.CreationTimestamp.Time
-		age := now.Sub(creationTime)
+		// Check if the sandbox has outlived its TTL, which defaults to the configured timeout
+		age := now.Sub(deployment.CreationTimestamp.Time)
+		expiresAt := sandboxExpiry(&deployment, c.config.SandboxTimeoutDuration)
 
-		if age >= c.config.SandboxTimeoutDuration {
+		if !now.Before(expiresAt) {
 			// Extract user ID from labels or deployment name
 			userID := deployment.Labels["user"]
 			if userID == "" {
@@ -93,14 +93,22 @@ func (c *ClientWithTraefik) StartAutoCleanupService(ctx context.Context) {
 				if err := c.cleanupExpiredSandboxes(ctx); err != nil {
 					log.Printf("Error cleaning up sandboxes: %v", err)
 				}
+				c.forEachTenant(ctx, "cleaning up sandboxes", func(tenant *ClientWithTraefik) error {
+					return tenant.cleanupExpiredSandboxes(ctx)
+				})
 			}
 		}
 	}()
 	timeoutMinutes := int(c.config.SandboxTimeoutDuration.Minutes())
-	log.Printf("Auto cleanup service started - sandboxes will be deleted after %d minutes", timeoutMinutes)
+	log.Printf("Auto cleanup service started - sandboxes will be deleted when their TTL expires (default %d minutes)", timeoutMinutes)
+	if c.config.SandboxIdleTimeout > 0 {
+		log.Printf("Idle cleanup enabled - action %q after %d minutes without activity",
+			c.config.SandboxIdleAction, int(c.config.SandboxIdleTimeout.Minutes()))
+	}
 }
 
-// cleanupExpiredSandboxes checks for and deletes sandboxes that have been running for too long
+// cleanupExpiredSandboxes deletes sandboxes that have outlived their TTL and applies the
+// idle action to running sandboxes without recent activity
 func (c *ClientWithTraefik) cleanupExpiredSandboxes(ctx context.Context) error {
 	// Get all deployments in the namespace
 	deployments, err := c.clientset.AppsV1().Deployments(c.namespace).List(ctx, metav1.ListOptions{
@@ -112,11 +120,11 @@ func (c *ClientWithTraefik) cleanupExpiredSandboxes(ctx context.Context) error {
 
 	now := time.Now()
 	for _, deployment := range deployments.Items {
-		// Check if the deployment has been running for more than the configured timeout
-		creationTime := deployment.CreationTimestamp.Time
-		age := now.Sub(creationTime)
+		// Check if the sandbox has outlived its TTL, which defaults to the configured timeout
+		age := now.Sub(deployment.CreationTimestamp.Time)
+		expiresAt := sandboxExpiry(&deployment, c.config.SandboxTimeoutDuration)
 
-		if age >= c.config.SandboxTimeoutDuration {
+		if !now.Before(expiresAt) {
 			// Extract user ID from labels or deployment name
 			userID := deployment.Labels["user"]
 			if userID == "" {
@@ -124,10 +132,18 @@ func (c *ClientWithTraefik) cleanupExpiredSandboxes(ctx context.Context) error {
 			}
 
 			log.Printf("Deleting sandbox for user %s (age: %v)", userID, age.Round(time.Second))
-			if err := c.DeleteSandbox(userID); err != nil {
+			if err := c.DeleteSandbox(ctx, userID); err != nil {
 				log.Printf("Error deleting sandbox for user %s: %v", userID, err)
 				// Continue with other sandboxes even if this one fails
 			}
+			continue
+		}
+
+		// Sandboxes within their TTL can still be paused or deleted for inactivity
+		if c.config.SandboxIdleTimeout > 0 {
+			if userID := deployment.Labels["user"]; userID != "" {
+				c.handleIdleSandbox(ctx, &deployment, userID, now)
+			}
 		}
 	}
 
@@ -210,7 +226,7 @@ func (c *ClientWithTraefik) CleanupExpiredSandboxesByDuration(ctx context.Contex
 			}
 
 			log.Printf("Attempting to delete sandbox for user %s (age: %v)", userID, age.Round(time.Second))
-			if err := c.DeleteSandbox(userID); err != nil {
+			if err := c.DeleteSandbox(ctx, userID); err != nil {
 				log.Printf("Error deleting sandbox for user %s: %v", userID, err)
 				// Continue with other sandboxes even if this one fails
 			} else {
@@ -0,0 +1,318 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReasonInsufficientCapacity is the reason given when no node has room for a sandbox
+const ReasonInsufficientCapacity = "InsufficientCapacity"
+
+// nodePoolLabels identify the node pool of a node on the common managed Kubernetes services
+var nodePoolLabels = []string{
+	"cloud.google.com/gke-nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"kubernetes.azure.com/agentpool",
+	"karpenter.sh/nodepool",
+}
+
+// ErrInsufficientCapacity is returned when no node has room for a sandbox's resource requests
+var ErrInsufficientCapacity = errors.New(ReasonInsufficientCapacity)
+
+// NodePoolCapacity is the free room for sandboxes in one node pool
+type NodePoolCapacity struct {
+	// Node pool name, or "default" for nodes without a node pool label
+	Name string `json:"name" example:"default-pool"`
+	// Number of nodes in the pool
+	Nodes int `json:"nodes" example:"3"`
+	// Nodes that accept new sandboxes (ready, not cordoned and without NoSchedule taints)
+	SchedulableNodes int `json:"schedulableNodes" example:"3"`
+	// Allocatable CPU of the schedulable nodes
+	AllocatableCPU string `json:"allocatableCpu" example:"11580m"`
+	// Allocatable memory of the schedulable nodes
+	AllocatableMemory string `json:"allocatableMemory" example:"44Gi"`
+	// CPU requested by the pods on the schedulable nodes
+	RequestedCPU string `json:"requestedCpu" example:"6200m"`
+	// Memory requested by the pods on the schedulable nodes
+	RequestedMemory string `json:"requestedMemory" example:"13Gi"`
+	// Number of sandboxes of the profile that still fit, counted node by node
+	FreeSlots int `json:"freeSlots" example:"4"`
+}
+
+// ClusterCapacity is the free room for sandboxes of one profile across the cluster
+type ClusterCapacity struct {
+	// Profile the free slots are counted for
+	Profile string `json:"profile" example:"standard"`
+	// CPU request of a sandbox of the profile
+	CPURequest string `json:"cpuRequest" example:"1"`
+	// Memory request of a sandbox of the profile
+	MemoryRequest string `json:"memoryRequest" example:"2Gi"`
+	// Number of sandboxes of the profile that still fit in the cluster
+	FreeSlots int `json:"freeSlots" example:"4"`
+	// Free room per node pool
+	NodePools []NodePoolCapacity `json:"nodePools"`
+}
+
+// poolTotals adds up the resources of a node pool's schedulable nodes
+type poolTotals struct {
+	NodePoolCapacity
+	allocatableCPU    resource.Quantity
+	allocatableMemory resource.Quantity
+	requestedCPU      resource.Quantity
+	requestedMemory   resource.Quantity
+}
+
+// nodeCapacity is the allocatable and requested resources of one node
+type nodeCapacity struct {
+	node      *corev1.Node
+	requested corev1.ResourceList
+	pods      int64
+}
+
+// GetClusterCapacity reports how many sandboxes of a profile fit on the cluster's nodes,
+// based on node allocatable resources minus the requests of the pods already on them
+func (c *Client) GetClusterCapacity(ctx context.Context, profile string) (*ClusterCapacity, error) {
+	name, size, err := c.resolveProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := c.nodeCapacities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := &ClusterCapacity{
+		Profile:       name,
+		CPURequest:    size.cpuRequest.String(),
+		MemoryRequest: size.memoryRequest.String(),
+		NodePools:     []NodePoolCapacity{},
+	}
+	pools := make(map[string]*poolTotals)
+	for _, node := range nodes {
+		poolName := nodePoolName(node.node)
+		pool, ok := pools[poolName]
+		if !ok {
+			pool = &poolTotals{NodePoolCapacity: NodePoolCapacity{Name: poolName}}
+			pools[poolName] = pool
+		}
+		pool.Nodes++
+		if !nodeSchedulable(node.node) {
+			continue
+		}
+
+		pool.SchedulableNodes++
+		pool.allocatableCPU.Add(node.node.Status.Allocatable[corev1.ResourceCPU])
+		pool.allocatableMemory.Add(node.node.Status.Allocatable[corev1.ResourceMemory])
+		pool.requestedCPU.Add(node.requested[corev1.ResourceCPU])
+		pool.requestedMemory.Add(node.requested[corev1.ResourceMemory])
+		slots := nodeFreeSlots(node, size.cpuRequest, size.memoryRequest)
+		pool.FreeSlots += slots
+		capacity.FreeSlots += slots
+	}
+
+	for _, pool := range pools {
+		pool.AllocatableCPU = pool.allocatableCPU.String()
+		pool.AllocatableMemory = pool.allocatableMemory.String()
+		pool.RequestedCPU = pool.requestedCPU.String()
+		pool.RequestedMemory = pool.requestedMemory.String()
+		capacity.NodePools = append(capacity.NodePools, pool.NodePoolCapacity)
+	}
+	sort.Slice(capacity.NodePools, func(i, j int) bool {
+		return capacity.NodePools[i].Name < capacity.NodePools[j].Name
+	})
+	return capacity, nil
+}
+
+// checkSandboxCapacity returns ErrInsufficientCapacity if a sandbox that needs a new pod cannot be
+// scheduled on any node, even after pausing sandboxes of lower tiers, or if queued requests of
+// the same or a higher tier are waiting for room. Running sandboxes are not checked. If the
+// nodes cannot be read, the sandbox is let through and the scheduler decides. The sandboxes
+// paused to make room are returned, so that the caller can resume them if the create fails.
+func (c *Client) checkSandboxCapacity(ctx context.Context, sandboxID string, opts SandboxOptions) ([]*preemptionCandidate, error) {
+	if !c.config.SandboxCapacityCheck {
+		return nil, nil
+	}
+
+	tier := opts.Tier
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, fmt.Sprintf("%s-deployment", sandboxID), metav1.GetOptions{})
+	switch {
+	case err == nil && deploymentReplicas(deployment) > 0:
+		return nil, nil
+	case err == nil && tier == "":
+		tier = deploymentTier(deployment)
+	case err != nil && !apierrors.IsNotFound(err):
+		return nil, err
+	}
+
+	// Requests already waiting for room get it first
+	if err := c.checkQueueAhead(ctx, sandboxID, tier); err != nil {
+		return nil, err
+	}
+
+	_, size, err := c.resolveProfile(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	return c.checkCapacity(ctx, size.cpuRequest, size.memoryRequest, tier)
+}
+
+// checkResumeCapacity returns ErrInsufficientCapacity if a paused sandbox cannot be scheduled on any
+// node, even after pausing sandboxes of lower tiers. The sandboxes paused to make room are returned.
+func (c *Client) checkResumeCapacity(ctx context.Context, deployment *appsv1.Deployment) ([]*preemptionCandidate, error) {
+	if !c.config.SandboxCapacityCheck || deploymentReplicas(deployment) > 0 {
+		return nil, nil
+	}
+
+	requests := podSpecRequests(&deployment.Spec.Template.Spec)
+	return c.checkCapacity(ctx, requests[corev1.ResourceCPU], requests[corev1.ResourceMemory], deploymentTier(deployment))
+}
+
+// checkCapacity returns ErrInsufficientCapacity unless some schedulable node has cpu and memory
+// free, or can be given room by pausing sandboxes of a lower tier than the new sandbox's, in
+// which case the paused sandboxes are returned
+func (c *Client) checkCapacity(ctx context.Context, cpu, memory resource.Quantity, tier string) ([]*preemptionCandidate, error) {
+	nodes, err := c.nodeCapacities(ctx)
+	if err != nil {
+		log.Printf("Skipping capacity check, nodes could not be read: %v", err)
+		return nil, nil
+	}
+
+	for _, node := range nodes {
+		if nodeSchedulable(node.node) && nodeFreeSlots(node, cpu, memory) > 0 {
+			return nil, nil
+		}
+	}
+
+	preempted, err := c.preemptForSandbox(ctx, tier, nodes, cpu, memory)
+	if err != nil {
+		log.Printf("Error preempting sandboxes for a %s sandbox: %v", resolveTier(tier).name, err)
+	}
+	if len(preempted) > 0 {
+		return preempted, nil
+	}
+	return nil, fmt.Errorf("%w: no schedulable node has %s CPU and %s memory free for the sandbox",
+		ErrInsufficientCapacity, cpu.String(), memory.String())
+}
+
+// nodeCapacities returns every node with the resources requested by the pods scheduled on it
+func (c *Client) nodeCapacities(ctx context.Context) ([]nodeCapacity, error) {
+	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := c.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	byNode := make(map[string]*nodeCapacity, len(nodes.Items))
+	capacities := make([]nodeCapacity, len(nodes.Items))
+	for i := range nodes.Items {
+		capacities[i] = nodeCapacity{node: &nodes.Items[i], requested: corev1.ResourceList{}}
+		byNode[nodes.Items[i].Name] = &capacities[i]
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		node, ok := byNode[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		node.pods++
+		for name, quantity := range podSpecRequests(&pod.Spec) {
+			total := node.requested[name]
+			total.Add(quantity)
+			node.requested[name] = total
+		}
+	}
+	return capacities, nil
+}
+
+// podSpecRequests returns the resources the scheduler reserves for a pod: the larger of the
+// summed container requests and the largest init container request, plus the pod overhead
+func podSpecRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			total := requests[name]
+			total.Add(quantity)
+			requests[name] = total
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	for name, quantity := range spec.Overhead {
+		total := requests[name]
+		total.Add(quantity)
+		requests[name] = total
+	}
+	return requests
+}
+
+// nodeFreeSlots returns how many pods requesting cpu and memory still fit on a node
+func nodeFreeSlots(node nodeCapacity, cpu, memory resource.Quantity) int {
+	allocatable := node.node.Status.Allocatable
+	freeCPU := allocatable.Cpu().MilliValue() - node.requested.Cpu().MilliValue()
+	freeMemory := allocatable.Memory().Value() - node.requested.Memory().Value()
+	slots := int64(math.MaxInt32)
+	if pods, ok := allocatable[corev1.ResourcePods]; ok {
+		slots = pods.Value() - node.pods
+	}
+
+	if cpu.MilliValue() > 0 {
+		slots = min(slots, freeCPU/cpu.MilliValue())
+	}
+	if memory.Value() > 0 {
+		slots = min(slots, freeMemory/memory.Value())
+	}
+	if slots < 0 {
+		return 0
+	}
+	return int(slots)
+}
+
+// nodeSchedulable reports whether new sandbox pods can be scheduled on a node. Sandbox pods have
+// no tolerations, so nodes with NoSchedule or NoExecute taints are left out.
+func nodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodePoolName returns the node pool a node belongs to
+func nodePoolName(node *corev1.Node) string {
+	for _, label := range nodePoolLabels {
+		if name := node.Labels[label]; name != "" {
+			return name
+		}
+	}
+	return "default"
+}
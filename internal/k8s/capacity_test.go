@@ -0,0 +1,132 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func testNode(cpu, memory, pods string, ready bool) *corev1.Node {
+	status := corev1.ConditionTrue
+	if !ready {
+		status = corev1.ConditionFalse
+	}
+	return &corev1.Node{
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+				corev1.ResourcePods:   resource.MustParse(pods),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
+
+func TestNodeFreeSlots(t *testing.T) {
+	cpu, memory := resource.MustParse("1"), resource.MustParse("2Gi")
+
+	testCases := []struct {
+		name     string
+		node     nodeCapacity
+		expected int
+	}{
+		{"Empty node", nodeCapacity{node: testNode("4", "16Gi", "110", true)}, 4},
+		{"Memory bound", nodeCapacity{node: testNode("8", "5Gi", "110", true)}, 2},
+		{"Partly used", nodeCapacity{
+			node:      testNode("4", "16Gi", "110", true),
+			requested: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			pods:      3,
+		}, 1},
+		{"Pod limit reached", nodeCapacity{node: testNode("4", "16Gi", "3", true), pods: 3}, 0},
+		{"Overcommitted", nodeCapacity{
+			node:      testNode("2", "4Gi", "110", true),
+			requested: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3")},
+		}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.node.requested == nil {
+				tc.node.requested = corev1.ResourceList{}
+			}
+			if got := nodeFreeSlots(tc.node, cpu, memory); got != tc.expected {
+				t.Errorf("Expected %d free slots, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNodeSchedulable(t *testing.T) {
+	cordoned := testNode("4", "16Gi", "110", true)
+	cordoned.Spec.Unschedulable = true
+	tainted := testNode("4", "16Gi", "110", true)
+	tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	preferNot := testNode("4", "16Gi", "110", true)
+	preferNot.Spec.Taints = []corev1.Taint{{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule}}
+
+	testCases := []struct {
+		name     string
+		node     *corev1.Node
+		expected bool
+	}{
+		{"Ready node", testNode("4", "16Gi", "110", true), true},
+		{"Not ready", testNode("4", "16Gi", "110", false), false},
+		{"Cordoned", cordoned, false},
+		{"NoSchedule taint", tainted, false},
+		{"PreferNoSchedule taint", preferNot, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nodeSchedulable(tc.node); got != tc.expected {
+				t.Errorf("Expected schedulable %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPodSpecRequests(t *testing.T) {
+	requests := func(cpu, memory string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Resources: requests("2", "128Mi")}},
+		Containers: []corev1.Container{
+			{Name: "sandbox", Resources: requests("1", "2Gi")},
+			{Name: "sidecar", Resources: requests("250m", "256Mi")},
+		},
+	}
+
+	got := podSpecRequests(spec)
+	if cpu := got[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("Expected the init container's 2 CPU, got %s", cpu.String())
+	}
+	if memory := got[corev1.ResourceMemory]; memory.Cmp(resource.MustParse("2304Mi")) != 0 {
+		t.Errorf("Expected the containers' 2304Mi memory, got %s", memory.String())
+	}
+}
+
+func TestApplyPodStatusUnschedulable(t *testing.T) {
+	pod := corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 Insufficient cpu.",
+			}},
+		},
+	}
+
+	info := &SandboxInfo{Status: "Pending"}
+	applyPodStatus(info, []corev1.Pod{pod})
+	if info.Status != "Unschedulable" || info.Message != "0/3 nodes are available: 3 Insufficient cpu." {
+		t.Errorf("Expected the scheduling failure, got status %q and message %q", info.Status, info.Message)
+	}
+}
@@ -12,10 +12,16 @@ import (
 
 // Client is a Kubernetes client wrapper
 type Client struct {
-	clientset *kubernetes.Clientset
-	namespace string
-	domain    string
-	config    *config.Configuration
+	clientset  *kubernetes.Clientset
+	restConfig *rest.Config
+	namespace  string
+	tenant     string
+	domain     string
+	config     *config.Configuration
+	watcher    *sandboxWatcher
+	pool       *warmPool
+	queue      *sandboxQueue
+	quotaLocks *quotaLocks
 }
 
 // NewClient creates a new Kubernetes client
@@ -52,9 +58,11 @@ func NewClient() (*Client, error) {
 	ResourceExpirationTime = appConfig.SandboxTimeoutDuration
 
 	return &Client{
-		clientset: clientset,
-		namespace: namespace,
-		domain:    domain,
-		config:    appConfig,
+		clientset:  clientset,
+		restConfig: k8sConfig,
+		namespace:  namespace,
+		domain:     domain,
+		config:     appConfig,
+		quotaLocks: &quotaLocks{},
 	}, nil
 }
\ No newline at end of file
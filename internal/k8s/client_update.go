@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -51,82 +52,25 @@ func NewClientWithTraefik() (*ClientWithTraefik, error) {
 	}, nil
 }
 
-// createIngressRoute creates a Traefik IngressRoute CRD for the user's sandbox
-func (c *ClientWithTraefik) createIngressRoute(ctx context.Context, userID string) error {
-	// Create the VNC IngressRoute
-	if err := c.createVncIngressRoute(ctx, userID); err != nil {
-		return err
-	}
-
-	// Create the API IngressRoute
-	if err := c.createApiIngressRoute(ctx, userID); err != nil {
-		return err
-	}
-
-	return nil
+// buildVncIngressRoute returns the desired VNC IngressRoute for the user
+func (c *ClientWithTraefik) buildVncIngressRoute(userID string) *IngressRoute {
+	return c.buildIngressRoute(userID, "vnc", 6901)
 }
 
-// createVncIngressRoute creates the VNC IngressRoute for the user
-func (c *ClientWithTraefik) createVncIngressRoute(ctx context.Context, userID string) error {
-	// Get the IngressRoute GVR
-	gvr := IngressRouteGVR()
-
-	// Define the VNC IngressRoute
-	ingressRoute := &IngressRoute{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "traefik.io/v1alpha1",
-			Kind:       "IngressRoute",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-vnc", userID),
-			Namespace: c.namespace,
-			// External-DNS annotations have been removed
-			Annotations: map[string]string{},
-		},
-		Spec: IngressRouteSpec{
-			EntryPoints: []string{"websecure"},
-			Routes: []Route{
-				{
-					Match: fmt.Sprintf("Host(`%s-vnc.%s`)", userID, c.domain),
-					Kind:  "Rule",
-					Services: []Service{
-						{
-							Name: fmt.Sprintf("%s-service", userID),
-							Port: 6901,
-						},
-					},
-				},
-			},
-			TLS: &TLS{
-				CertResolver: "letsencrypt",
-			},
-		},
-	}
-
-	// Convert to unstructured for the dynamic client
-	unstructuredObj, err := convertToUnstructured(ingressRoute)
-	if err != nil {
-		return err
-	}
-
-	// Create the IngressRoute
-	_, err = c.dynamicClient.Resource(gvr).Namespace(c.namespace).Create(ctx, unstructuredObj, metav1.CreateOptions{})
-	return err
+// buildApiIngressRoute returns the desired API IngressRoute for the user
+func (c *ClientWithTraefik) buildApiIngressRoute(userID string) *IngressRoute {
+	return c.buildIngressRoute(userID, "api", 3000)
 }
 
-// createApiIngressRoute creates the API IngressRoute for the user
-func (c *ClientWithTraefik) createApiIngressRoute(ctx context.Context, userID string) error {
-	// Get the IngressRoute GVR
-	gvr := IngressRouteGVR()
-
-	// Define the API IngressRoute
-	ingressRoute := &IngressRoute{
+// buildIngressRoute returns an IngressRoute that routes {userId}-{suffix}.{domain} to a port of the user's service
+func (c *ClientWithTraefik) buildIngressRoute(userID, suffix string, port int) *IngressRoute {
+	return &IngressRoute{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "traefik.io/v1alpha1",
 			Kind:       "IngressRoute",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-api", userID),
+			Name:      fmt.Sprintf("%s-%s", userID, suffix),
 			Namespace: c.namespace,
 			// External-DNS annotations have been removed
 			Annotations: map[string]string{},
@@ -135,12 +79,12 @@ func (c *ClientWithTraefik) createApiIngressRoute(ctx context.Context, userID st
 			EntryPoints: []string{"websecure"},
 			Routes: []Route{
 				{
-					Match: fmt.Sprintf("Host(`%s-api.%s`)", userID, c.domain),
+					Match: fmt.Sprintf("Host(`%s-%s.%s`)", userID, suffix, c.domain),
 					Kind:  "Rule",
 					Services: []Service{
 						{
 							Name: fmt.Sprintf("%s-service", userID),
-							Port: 3000,
+							Port: port,
 						},
 					},
 				},
@@ -150,7 +94,10 @@ func (c *ClientWithTraefik) createApiIngressRoute(ctx context.Context, userID st
 			},
 		},
 	}
+}
 
+// createIngressRouteObject creates an IngressRoute through the dynamic client
+func (c *ClientWithTraefik) createIngressRouteObject(ctx context.Context, ingressRoute *IngressRoute) error {
 	// Convert to unstructured for the dynamic client
 	unstructuredObj, err := convertToUnstructured(ingressRoute)
 	if err != nil {
@@ -158,7 +105,7 @@ func (c *ClientWithTraefik) createApiIngressRoute(ctx context.Context, userID st
 	}
 
 	// Create the IngressRoute
-	_, err = c.dynamicClient.Resource(gvr).Namespace(c.namespace).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+	_, err = c.dynamicClient.Resource(IngressRouteGVR()).Namespace(c.namespace).Create(ctx, unstructuredObj, metav1.CreateOptions{})
 	return err
 }
 
@@ -182,6 +129,11 @@ func (c *ClientWithTraefik) deleteIngressRoutes(ctx context.Context, userID stri
 	return nil
 }
 
+// deleteIngressRoute deletes a single IngressRoute by name
+func (c *ClientWithTraefik) deleteIngressRoute(ctx context.Context, name string) error {
+	return c.dynamicClient.Resource(IngressRouteGVR()).Namespace(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
 // IsValidKubernetesName validates if a name conforms to Kubernetes service naming rules
 // This follows DNS-1035 label naming convention used by Kubernetes for services
 // Valid: lowercase alphanumeric characters, '-', must start with a letter, end with alphanumeric
@@ -210,60 +162,170 @@ func IsValidKubernetesName(name string) (bool, string) {
 	return true, ""
 }
 
-// CreateSandbox creates a new sandbox for a user with Traefik IngressRoutes
-func (c *ClientWithTraefik) CreateSandbox(userID string) error {
-	// Previous parameters for environment variables have been removed
-	ctx := context.Background()
-
+// CreateSandbox creates a new sandbox for a user with Traefik IngressRoutes.
+// It is idempotent: each resource is compared with what is in the cluster and only
+// missing or drifted resources are created or repaired. If a step fails, resources
+// created by this call are rolled back. Completed steps are reported to the
+// context's ProgressFunc, and cancelling ctx aborts the create and rolls it back.
+func (c *ClientWithTraefik) CreateSandbox(ctx context.Context, userID string, opts SandboxOptions) (*SandboxCreateResult, error) {
 	// Validate service name first
 	valid, errMsg := IsValidKubernetesName(userID)
 	if !valid {
-		return fmt.Errorf("invalid user ID for Kubernetes service: %s", errMsg)
+		return nil, fmt.Errorf("invalid user ID for Kubernetes service: %s", errMsg)
+	}
+	opts = c.withTenantTier(opts)
+	if err := c.ValidateSandboxOptions(opts); err != nil {
+		return nil, err
+	}
+	if err := c.checkSandboxOwner(ctx, userID, opts); err != nil {
+		return nil, err
+	}
+	if err := c.checkSharedStorage(ctx, userID, opts); err != nil {
+		return nil, err
+	}
+	// Another create for the same owner must not pass the quota check until this deployment exists
+	owner, _ := opts.ownership(userID)
+	unlockQuota := c.lockQuota(owner)
+	defer unlockQuota()
+	if err := c.checkSandboxQuota(ctx, userID, opts); err != nil {
+		return nil, err
 	}
 
 	// Create namespace if it doesn't exist
 	if err := c.ensureNamespace(ctx); err != nil {
-		return err
+		return nil, err
+	}
+	if err := c.ensurePriorityClass(ctx, opts.Tier); err != nil {
+		return nil, err
 	}
 
-	// Create PVC for user
-	if err := c.createPVC(ctx, userID); err != nil {
-		return err
+	// Everything created from here on is rolled back if a later step fails
+	tx := newCreateTransaction(userID)
+
+	// New sandboxes with default settings take a running pod and its claim from the warm pool
+	claim, err := c.claimPoolSandbox(ctx, userID, opts)
+	if err != nil {
+		log.Printf("Error claiming warm pool sandbox for user %s: %v", userID, err)
+		claim = nil
 	}
 
-	// Create deployment
-	if err := c.createDeployment(ctx, userID); err != nil {
-		return err
+	var action string
+	if claim != nil {
+		tx.track("pvc", claim.pvcName, ActionClaimed, c.deletePVC)
+		tx.track("pod", claim.podName, ActionClaimed, c.deletePod)
+		tx.track("replicaset", claim.replicaSetName, ActionClaimed, c.deleteReplicaSet)
+	} else {
+		// Fail fast rather than leave a new pod Pending when no node has room for it
+		preempted, err := c.checkSandboxCapacity(ctx, userID, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, victim := range preempted {
+			tx.trackPreemption(victim.deployment.Namespace+"/"+victim.deployment.Labels["user"], func(ctx context.Context) error {
+				return c.resumePreemptedSandbox(ctx, victim)
+			})
+		}
+
+		// Ensure PVC for user (an existing PVC holds user data and is never rolled back).
+		// Shared storage is the owner's default volume, which is created if it does not exist yet.
+		claimOwner, pvcOpts := userID, opts
+		if opts.SharedStorage {
+			_, exists, err := c.deploymentClaimName(ctx, userID)
+			if err != nil {
+				return nil, tx.rollback("pvc", err)
+			}
+			if !exists {
+				claimOwner = opts.Owner
+				pvcOpts.Owner, pvcOpts.Name, pvcOpts.SharedStorage = "", "", false
+			}
+		}
+		var claimName string
+		claimName, action, err = c.ensurePVC(ctx, claimOwner, pvcOpts)
+		if err != nil {
+			return nil, tx.rollback("pvc", err)
+		}
+		tx.track("pvc", claimName, action, c.deletePVC)
 	}
+	reportProgress(ctx, "pvc")
 
-	// Create service
-	if err := c.createService(ctx, userID); err != nil {
-		return err
+	// Ensure the secret before the deployment so the pod never starts without it
+	if len(opts.Secrets) > 0 {
+		action, err = c.ensureSandboxSecret(ctx, userID, opts.Secrets)
+		if err != nil {
+			return nil, tx.rollback("secret", err)
+		}
+		tx.track("secret", sandboxSecretName(userID), action, c.deleteSecret)
 	}
 
-	// Create Traefik IngressRoutes
-	if err := c.createIngressRoute(ctx, userID); err != nil {
-		return err
+	// Ensure deployment
+	action, err = c.ensureDeployment(ctx, userID, opts)
+	if err != nil {
+		return nil, tx.rollback("deployment", err)
 	}
+	tx.track("deployment", fmt.Sprintf("%s-deployment", userID), action, c.deleteDeployment)
+	unlockQuota()
 
-	log.Printf("Sandbox created for user: %s", userID)
-	return nil
+	// The deployment owns the secret, so deleting the sandbox deletes the secret too
+	if len(opts.Secrets) > 0 {
+		if err := c.ownSandboxSecret(ctx, userID); err != nil {
+			return nil, tx.rollback("secret", err)
+		}
+	}
+	reportProgress(ctx, "deployment")
+
+	// Ensure service
+	action, err = c.ensureService(ctx, userID)
+	if err != nil {
+		return nil, tx.rollback("service", err)
+	}
+	tx.track("service", fmt.Sprintf("%s-service", userID), action, c.deleteService)
+	reportProgress(ctx, "service")
+
+	// Ensure Traefik IngressRoutes one at a time so a half-created pair can be undone
+	action, err = c.ensureIngressRoute(ctx, c.buildVncIngressRoute(userID))
+	if err != nil {
+		return nil, tx.rollback("vnc-route", err)
+	}
+	tx.track("ingressroute", fmt.Sprintf("%s-vnc", userID), action, c.deleteIngressRoute)
+
+	action, err = c.ensureIngressRoute(ctx, c.buildApiIngressRoute(userID))
+	if err != nil {
+		return nil, tx.rollback("api-route", err)
+	}
+	tx.track("ingressroute", fmt.Sprintf("%s-api", userID), action, c.deleteIngressRoute)
+	reportProgress(ctx, "routes")
+
+	result := tx.result()
+	if result.Changed() {
+		log.Printf("Sandbox created for user: %s", userID)
+	} else {
+		log.Printf("Sandbox already up to date for user: %s", userID)
+	}
+	if err := c.removeQueuedSandbox(ctx, userID); err != nil {
+		log.Printf("Error removing sandbox %s from the queue: %v", userID, err)
+	}
+	return result, nil
 }
 
-// DeleteSandbox deletes a user's sandbox
-func (c *ClientWithTraefik) DeleteSandbox(userID string) error {
-	ctx := context.Background()
+// DeleteSandbox deletes a user's sandbox, or takes it out of the queue if it is still waiting.
+// Completed steps are reported to the context's ProgressFunc.
+func (c *ClientWithTraefik) DeleteSandbox(ctx context.Context, userID string) error {
+	if err := c.removeQueuedSandbox(ctx, userID); err != nil {
+		log.Printf("Error removing sandbox %s from the queue: %v", userID, err)
+	}
 
 	// Delete Traefik IngressRoutes
 	if err := c.deleteIngressRoutes(ctx, userID); err != nil {
 		log.Printf("Error deleting IngressRoutes: %v", err)
 	}
+	reportProgress(ctx, "routes")
 
 	// Try to delete service
 	if err := c.clientset.CoreV1().Services(c.namespace).Delete(ctx,
 		fmt.Sprintf("%s-service", userID), metav1.DeleteOptions{}); err != nil {
 		log.Printf("Error deleting service: %v", err)
 	}
+	reportProgress(ctx, "service")
 
 	// Try possible deployment name patterns
 	deploymentPatterns := []string{
@@ -286,17 +348,18 @@ func (c *ClientWithTraefik) DeleteSandbox(userID string) error {
 	if !deploymentDeleted {
 		log.Printf("Warning: Could not delete any deployment for userID: %s", userID)
 	}
+	reportProgress(ctx, "deployment")
 
 	// No longer need to delete Node.js environment ConfigMap as it's not created anymore
 
-	// Keep PVC for now (user data persistence)
-	// Uncomment to delete PVC as well
-	/*
-	if err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Delete(ctx,
-		fmt.Sprintf("%s-pvc", userID), metav1.DeleteOptions{}); err != nil {
-		log.Printf("Error deleting PVC: %v", err)
+	// Keep the PVC (user data persistence) and record when it was last used,
+	// so the retention sweeper can purge it later. PurgeSandboxData removes it explicitly.
+	if err := c.markDataLastUsed(ctx, userID, time.Now()); err != nil {
+		log.Printf("Error recording data use for user %s: %v", userID, err)
 	}
-	*/
+
+	// The freed capacity goes to the queue first
+	c.triggerQueue()
 
 	log.Printf("Sandbox deletion process completed for user: %s", userID)
 	return nil
@@ -3,58 +3,118 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // createDeployment creates a deployment for the user's sandbox
 func (c *Client) createDeployment(ctx context.Context, userID string) error {
+	deployment, err := c.buildDeployment(ctx, userID, SandboxOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.clientset.AppsV1().Deployments(c.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	return err
+}
+
+// buildDeployment returns the desired deployment for the user's sandbox
+func (c *Client) buildDeployment(ctx context.Context, userID string, opts SandboxOptions) (*appsv1.Deployment, error) {
+	// Find the claim holding the user's data, which a warm start may have taken from the pool.
+	// A named sandbox with shared storage uses its owner's default volume.
+	claimName, err := c.sandboxClaimName(ctx, userID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up data volume: %w", err)
+	}
+
+	shared, err := c.sharesClaim(ctx, userID, claimName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up data volume: %w", err)
+	}
+
+	// Use the requested image, or the default tag from the configmap
+	image, err := c.resolveImage(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.sandboxDeployment(userID, opts, claimName, shared, image)
+}
+
+// sandboxDeployment returns the deployment for a sandbox that mounts claimName, shared with
+// another sandbox or not, and runs image
+func (c *Client) sandboxDeployment(userID string, opts SandboxOptions, claimName string, shared bool, image string) (*appsv1.Deployment, error) {
 	deploymentName := fmt.Sprintf("%s-deployment", userID)
 
+	// Resources, shared memory and storage come from the sandbox's profile
+	profileName, size, err := c.resolveProfile(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create deployment
 	var replicas int32 = 1
 
-	// Only include the USER_ID environment variable
-	var envVarSlice []corev1.EnvVar
-	envVarSlice = append(envVarSlice, corev1.EnvVar{
-		Name:  "USER_ID",
-		Value: userID,
-	})
+	// USER_ID followed by the requested allow-listed variables
+	envVarSlice := sandboxEnv(userID, opts.Env)
+
+	// Secret values are read from the user's Secret; the hash restarts the pod when they change
+	var envFrom []corev1.EnvFromSource
+	var podAnnotations map[string]string
+	if len(opts.Secrets) > 0 {
+		envFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: sandboxSecretName(userID)},
+			},
+		}}
+		podAnnotations = map[string]string{annotationSecretsHash: secretsHash(opts.Secrets)}
+	}
 
 	// Get volume mounts for the container from storage
-	volumeMounts := c.getUserDataVolumeMounts()
+	volumeMounts := c.sandboxDataVolumeMounts(userID, shared)
 
 	// Get volumes for the pod from storage
 	volumes := []corev1.Volume{
-		c.getUserDataVolume(userID),
+		c.getUserDataVolume(claimName),
 		{
 			Name: "shm-volume",
 			VolumeSource: corev1.VolumeSource{
 				EmptyDir: &corev1.EmptyDirVolumeSource{
 					Medium:    corev1.StorageMediumMemory,
-					SizeLimit: resource.NewQuantity(1024*1024*1024, resource.BinarySI), // 1 GiB
+					SizeLimit: &size.shmSize,
 				},
 			},
 		},
 	}
 
-	// Get image tag from configmap
-	imageTag, err := c.getImageTagFromConfigMap(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get image tag from configmap: %v", err)
+	// The sandbox's lifetime is tracked on the deployment for auto cleanup
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = c.config.SandboxTimeoutDuration
+	}
+
+	annotations := ttlAnnotations(ttl, time.Now())
+	annotations[annotationProfile] = profileName
+	if opts.imageRequested() {
+		annotations[annotationImage] = image
 	}
 
+	// The user label holds the sandbox ID ({userId}--{name} for a named sandbox), not the
+	// user: selectors cannot change, and keyed by the user, a default sandbox would select the
+	// pods of the user's named sandboxes. The owner and sandbox labels group a user's sandboxes.
+	labels := opts.ownerLabels(userID)
+	labels["app"] = "user-sandbox"
+	labels["user"] = userID
+
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: deploymentName,
-			Labels: map[string]string{
-				"app":  "user-sandbox",
-				"user": userID,
-			},
+			Name:        deploymentName,
+			Labels:      labels,
+			Annotations: annotations,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
@@ -70,6 +130,7 @@ func (c *Client) createDeployment(ctx context.Context, userID string) error {
 						"app":  "user-sandbox",
 						"user": userID,
 					},
+					Annotations: podAnnotations,
 				},
 				Spec: corev1.PodSpec{
 					// Add init container to set correct permissions on the volume
@@ -83,7 +144,7 @@ func (c *Client) createDeployment(ctx context.Context, userID string) error {
 								"-c",
 								"chmod -R 777 /config && rm -f /config/browser/user-data/Singleton* && wait",
 							},
-							VolumeMounts: c.getUserDataVolumeMounts(),
+							VolumeMounts: volumeMounts,
 							SecurityContext: &corev1.SecurityContext{
 								RunAsUser: func() *int64 {
 									var uid int64 = 0 // Run as root to set permissions
@@ -95,7 +156,7 @@ func (c *Client) createDeployment(ctx context.Context, userID string) error {
 					Containers: []corev1.Container{
 						{
 							Name:  "sandbox",
-							Image: fmt.Sprintf("us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:%s", imageTag),
+							Image: image,
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							SecurityContext: &corev1.SecurityContext{
 								SeccompProfile: &corev1.SeccompProfile{
@@ -113,15 +174,16 @@ func (c *Client) createDeployment(ctx context.Context, userID string) error {
 								},
 							},
 							Env:          envVarSlice,
+							EnvFrom:      envFrom,
 							VolumeMounts: volumeMounts,
 							Resources: corev1.ResourceRequirements{
 								Limits: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse("2"),
-									corev1.ResourceMemory: resource.MustParse("4Gi"),
+									corev1.ResourceCPU:    size.cpuLimit,
+									corev1.ResourceMemory: size.memoryLimit,
 								},
 								Requests: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse("1"),
-									corev1.ResourceMemory: resource.MustParse("2Gi"),
+									corev1.ResourceCPU:    size.cpuRequest,
+									corev1.ResourceMemory: size.memoryRequest,
 								},
 							},
 							LivenessProbe: &corev1.Probe{
@@ -153,13 +215,14 @@ func (c *Client) createDeployment(ctx context.Context, userID string) error {
 						},
 					},
 					Volumes: volumes,
+					// Higher tiers are scheduled first and may pause lower tiers to make room
+					PriorityClassName: resolveTier(opts.Tier).priorityClass,
 				},
 			},
 		},
 	}
 
-	_, err = c.clientset.AppsV1().Deployments(c.namespace).Create(ctx, deployment, metav1.CreateOptions{})
-	return err
+	return deployment, nil
 }
 
 // getImageTagFromConfigMap retrieves the container image tag from the app-config configmap
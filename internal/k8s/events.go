@@ -0,0 +1,273 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Sandbox event types
+const (
+	SandboxEventStatus  = "status"
+	SandboxEventDeleted = "deleted"
+)
+
+// watcherResync is how often the informers replay their caches as a safety net for missed events
+const watcherResync = 10 * time.Minute
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before events are dropped
+const subscriberBuffer = 64
+
+// ErrWatcherNotReady is returned when subscribing before the sandbox watcher has started and synced
+var ErrWatcherNotReady = errors.New("sandbox watcher is not running or has not synced yet")
+
+// SandboxEvent is a change in the derived status of a sandbox
+type SandboxEvent struct {
+	Type    string       `json:"type" example:"status"`
+	UserID  string       `json:"userId" example:"user123"`
+	Time    string       `json:"time" example:"2023-04-20T12:00:00Z"`
+	Sandbox *SandboxInfo `json:"sandbox,omitempty"`
+}
+
+// sandboxWatcher turns Deployment and Pod watch events into sandbox status events.
+// Status is derived with the same logic as GetSandboxStatus and emitted only when it changes.
+// One watcher covers the sandboxes of every namespace, and each subscriber only receives the
+// events of its own namespace, so tenant clients share the base client's watcher.
+type sandboxWatcher struct {
+	deployments appslisters.DeploymentLister
+	pods        corelisters.PodLister
+	synced      []cache.InformerSynced
+	defaultTTL  time.Duration
+
+	mu          sync.Mutex
+	last        map[sandboxKey]string
+	subscribers map[chan SandboxEvent]sandboxKey
+}
+
+// sandboxKey identifies a sandbox across namespaces; an empty userID in a subscription
+// matches every sandbox of the namespace
+type sandboxKey struct {
+	namespace string
+	userID    string
+}
+
+// StartSandboxWatcher starts informers on the sandbox Deployments and Pods of every namespace.
+// It returns without waiting for the caches to sync, and stops when ctx is cancelled.
+func (c *Client) StartSandboxWatcher(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, watcherResync,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = "app=user-sandbox"
+		}),
+	)
+
+	deploymentInformer := factory.Apps().V1().Deployments()
+	podInformer := factory.Core().V1().Pods()
+
+	w := &sandboxWatcher{
+		deployments: deploymentInformer.Lister(),
+		pods:        podInformer.Lister(),
+		synced: []cache.InformerSynced{
+			deploymentInformer.Informer().HasSynced,
+			podInformer.Informer().HasSynced,
+		},
+		defaultTTL:  c.config.SandboxTimeoutDuration,
+		last:        make(map[sandboxKey]string),
+		subscribers: make(map[chan SandboxEvent]sandboxKey),
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.onChange,
+		UpdateFunc: func(_, obj interface{}) { w.onChange(obj) },
+		DeleteFunc: w.onChange,
+	}
+	if _, err := deploymentInformer.Informer().AddEventHandler(handler); err != nil {
+		return err
+	}
+	if _, err := podInformer.Informer().AddEventHandler(handler); err != nil {
+		return err
+	}
+
+	factory.Start(ctx.Done())
+
+	c.watcher = w
+	log.Println("Sandbox watcher started for all sandbox namespaces")
+	return nil
+}
+
+// SubscribeSandboxEvents returns a channel of status events for one user, or for all
+// sandboxes of the client's namespace when userID is empty. The current status of matching
+// sandboxes is sent first.
+// The returned function must be called to unsubscribe.
+func (c *Client) SubscribeSandboxEvents(userID string) (<-chan SandboxEvent, func(), error) {
+	w := c.watcher
+	if w == nil || !w.hasSynced() {
+		return nil, nil, ErrWatcherNotReady
+	}
+
+	events := make(chan SandboxEvent, subscriberBuffer)
+
+	subscription := sandboxKey{namespace: c.namespace, userID: userID}
+	w.mu.Lock()
+	for _, info := range w.snapshot(subscription) {
+		events <- newSandboxEvent(SandboxEventStatus, info.UserID, info)
+		if len(events) == cap(events) {
+			break
+		}
+	}
+	w.subscribers[events] = subscription
+	w.mu.Unlock()
+
+	unsubscribe := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subscribers[events]; ok {
+			delete(w.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe, nil
+}
+
+// hasSynced reports whether the informer caches hold a complete view of the cluster
+func (w *sandboxWatcher) hasSynced() bool {
+	for _, synced := range w.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// onChange recomputes the status of the sandbox that a Deployment or Pod belongs to
+func (w *sandboxWatcher) onChange(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	var key sandboxKey
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		key = sandboxKey{namespace: o.Namespace, userID: o.Labels["user"]}
+		if key.userID == "" && strings.HasSuffix(o.Name, "-deployment") {
+			key.userID = strings.TrimSuffix(o.Name, "-deployment")
+		}
+	case *corev1.Pod:
+		key = sandboxKey{namespace: o.Namespace, userID: o.Labels["user"]}
+	}
+	if key.userID == "" {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	info, err := w.sandboxInfo(key)
+	if apierrors.IsNotFound(err) {
+		if _, known := w.last[key]; known {
+			delete(w.last, key)
+			w.publish(key.namespace, newSandboxEvent(SandboxEventDeleted, key.userID, nil))
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Sandbox watcher failed to derive status for user %s: %v", key.userID, err)
+		return
+	}
+
+	// Only emit when the derived status or the active pod changes
+	fingerprint := info.Status + "/" + info.PodName
+	if w.last[key] == fingerprint {
+		return
+	}
+	w.last[key] = fingerprint
+	w.publish(key.namespace, newSandboxEvent(SandboxEventStatus, key.userID, info))
+}
+
+// sandboxInfo derives a sandbox's status from the informer caches
+func (w *sandboxWatcher) sandboxInfo(key sandboxKey) (*SandboxInfo, error) {
+	deployment, err := w.deployments.Deployments(key.namespace).Get(key.userID + "-deployment")
+	if err != nil {
+		return nil, err
+	}
+
+	info := sandboxInfoFromDeployment(key.userID, deployment, w.defaultTTL)
+	if info.Status == "Paused" {
+		return info, nil
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "user-sandbox", "user": key.userID})
+	podPointers, err := w.pods.Pods(key.namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]corev1.Pod, 0, len(podPointers))
+	for _, pod := range podPointers {
+		pods = append(pods, *pod)
+	}
+
+	applyPodStatus(info, pods)
+	return info, nil
+}
+
+// snapshot returns the current status of every sandbox in a namespace, or of one user's sandbox
+func (w *sandboxWatcher) snapshot(subscription sandboxKey) []*SandboxInfo {
+	var userIDs []string
+	if subscription.userID != "" {
+		userIDs = []string{subscription.userID}
+	} else {
+		deployments, err := w.deployments.Deployments(subscription.namespace).List(labels.Everything())
+		if err != nil {
+			return nil
+		}
+		for _, deployment := range deployments {
+			if id := deployment.Labels["user"]; id != "" {
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+
+	infos := make([]*SandboxInfo, 0, len(userIDs))
+	for _, id := range userIDs {
+		if info, err := w.sandboxInfo(sandboxKey{namespace: subscription.namespace, userID: id}); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// publish sends an event of a namespace to every matching subscriber without blocking on slow readers
+func (w *sandboxWatcher) publish(namespace string, event SandboxEvent) {
+	for ch, subscription := range w.subscribers {
+		if subscription.namespace != namespace || (subscription.userID != "" && subscription.userID != event.UserID) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropping sandbox event for user %s: subscriber is not keeping up", event.UserID)
+		}
+	}
+}
+
+// newSandboxEvent builds a sandbox event stamped with the current time
+func newSandboxEvent(eventType, userID string, info *SandboxInfo) SandboxEvent {
+	return SandboxEvent{
+		Type:    eventType,
+		UserID:  userID,
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Sandbox: info,
+	}
+}
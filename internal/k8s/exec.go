@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// TerminalSize is the size of an interactive terminal in characters
+type TerminalSize struct {
+	Width  uint16 `json:"cols"`
+	Height uint16 `json:"rows"`
+}
+
+// ExecOptions configures a command run inside a sandbox container
+type ExecOptions struct {
+	// Command and its arguments
+	Command []string
+	// Stdin, Stdout and Stderr are attached when non-nil
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// TTY allocates a terminal; stderr is merged into stdout
+	TTY bool
+	// Resize delivers terminal size changes when TTY is set
+	Resize <-chan TerminalSize
+}
+
+// ExecInSandbox runs a command in the sandbox container of the user's newest pod.
+// It returns when the command exits or ctx is cancelled; a non-zero exit is reported
+// as an error that ExecExitCode understands.
+func (c *Client) ExecInSandbox(ctx context.Context, userID string, opts ExecOptions) error {
+	if len(opts.Command) == 0 {
+		return errors.New("command is required")
+	}
+
+	pod, err := c.getActiveSandboxPod(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.execInPod(ctx, pod.Name, "sandbox", opts)
+}
+
+// execInPod runs a command in a container of a pod via the pods/exec subresource
+func (c *Client) execInPod(ctx context.Context, podName, container string, opts ExecOptions) error {
+	req := c.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(c.namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			Stderr:    opts.Stderr != nil && !opts.TTY,
+			TTY:       opts.TTY,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor for pod %s: %w", podName, err)
+	}
+
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: opts.Stdout,
+		Tty:    opts.TTY,
+	}
+	if !opts.TTY {
+		streamOptions.Stderr = opts.Stderr
+	}
+	if opts.TTY && opts.Resize != nil {
+		streamOptions.TerminalSizeQueue = terminalSizeQueue(opts.Resize)
+	}
+
+	return executor.StreamWithContext(ctx, streamOptions)
+}
+
+// ExecExitCode extracts the exit code of a command from the error returned by ExecInSandbox.
+// It reports false if the error is not a command exit, e.g. a connection failure.
+func ExecExitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), true
+	}
+	return 0, false
+}
+
+// getNewestSandboxPod returns the user's newest sandbox pod, chosen the same way as GetSandboxStatus
+func (c *Client) getNewestSandboxPod(ctx context.Context, userID string) (*corev1.Pod, error) {
+	pods, err := c.listSandboxPods(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for user %s: %w", userID, err)
+	}
+
+	pod := findNewestPod(pods)
+	if pod == nil {
+		return nil, fmt.Errorf("sandbox pod not found for user ID %s", userID)
+	}
+	return pod, nil
+}
+
+// getActiveSandboxPod returns the user's newest sandbox pod, provided it is running
+func (c *Client) getActiveSandboxPod(ctx context.Context, userID string) (*corev1.Pod, error) {
+	pod, err := c.getNewestSandboxPod(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("sandbox pod %s for user ID %s is %s, not running", pod.Name, userID, pod.Status.Phase)
+	}
+	return pod, nil
+}
+
+// terminalSizeQueue adapts a channel of sizes to remotecommand.TerminalSizeQueue
+type terminalSizeQueue <-chan TerminalSize
+
+// Next blocks until the next size is available; nil ends the resize stream
+func (q terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-q
+	if !ok {
+		return nil
+	}
+	return &remotecommand.TerminalSize{Width: size.Width, Height: size.Height}
+}
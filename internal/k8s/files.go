@@ -0,0 +1,380 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Sandbox file access settings
+const (
+	// sandboxDataRoot is where the user data volume is mounted, and the only directory files may be accessed in
+	sandboxDataRoot = "/config"
+	// fileHelperStartTimeout bounds how long a file helper pod may take to start
+	fileHelperStartTimeout = 2 * time.Minute
+	// fileHelperDeadline stops helper pods that were not cleaned up
+	fileHelperDeadline int64 = 3600
+	// statFormat prints a file's type, size, modification time and name on one line
+	statFormat = "%F|%s|%Y|%n"
+)
+
+// Exit codes of the file scripts
+const (
+	fileExitNotFound  = 2
+	fileExitWrongType = 3
+)
+
+// File scripts take the target path as $1 so it is never interpreted by the shell. They run
+// as root, so each first refuses paths with a symbolic link below the data directory, which
+// the sandbox could otherwise use to point a read or write outside /config.
+const (
+	noSymlinksScript = `set -f; p=` + sandboxDataRoot + `; IFS=/; for e in ${1#` + sandboxDataRoot + `}; do [ -n "$e" ] || continue; p="$p/$e"; ` +
+		`[ ! -L "$p" ] || { echo "path contains a symbolic link" >&2; exit 3; }; done; unset IFS; set +f; `
+	statFileScript = noSymlinksScript +
+		`[ -e "$1" ] || exit 2; [ -f "$1" ] || { echo "not a regular file" >&2; exit 3; }; stat -c '` + statFormat + `' -- "$1"`
+	readFileScript = noSymlinksScript + `exec cat -- "$1"`
+	// Uploads are written next to the target and renamed into place, so readers never see a partial file.
+	// Noclobber keeps the temporary file from being opened through a link planted in its place.
+	writeFileScript = noSymlinksScript + `[ ! -d "$1" ] || { echo "is a directory" >&2; exit 3; }; ` +
+		`mkdir -p -- "$(dirname -- "$1")" || exit 1; set -C; ` +
+		`cat > "$1.upload.$$" && mv -f -- "$1.upload.$$" "$1" || { rm -f -- "$1.upload.$$"; exit 1; }; ` +
+		`stat -c '` + statFormat + `' -- "$1"`
+	listFilesScript = noSymlinksScript +
+		`[ -e "$1" ] || exit 2; [ -d "$1" ] || { echo "not a directory" >&2; exit 3; }; cd "$1" || exit 1; ` +
+		`for f in .[!.]* ..?* *; do { [ -e "$f" ] || [ -L "$f" ]; } && stat -c '` + statFormat + `' -- "$f"; done; exit 0`
+)
+
+// ErrInvalidPath is returned for file paths outside the sandbox's data directory or of the wrong type
+var ErrInvalidPath = errors.New("invalid path")
+
+// FileInfo describes a file in a sandbox's data directory
+type FileInfo struct {
+	// File name
+	Name string `json:"name" example:"report.pdf"`
+	// Path relative to the data directory
+	Path string `json:"path" example:"/downloads/report.pdf"`
+	// Type (file, directory, symlink or other)
+	Type string `json:"type" example:"file"`
+	// Size in bytes
+	Size int64 `json:"size" example:"52133"`
+	// Last modification time
+	ModifiedAt string `json:"modifiedAt" example:"2023-04-20T12:30:00Z"`
+}
+
+// SandboxPath maps a path relative to the sandbox's data directory to an absolute path in
+// the container. Paths may not contain ".." elements, so the result is always inside /config;
+// the file scripts also refuse symbolic links, which could lead out of it.
+func SandboxPath(p string) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", fmt.Errorf("%w: path contains a NUL byte", ErrInvalidPath)
+	}
+	for _, element := range strings.Split(p, "/") {
+		if element == ".." {
+			return "", fmt.Errorf("%w: path may not contain ..", ErrInvalidPath)
+		}
+	}
+
+	cleaned := path.Clean("/" + p)
+	if cleaned == "/" {
+		return sandboxDataRoot, nil
+	}
+	return sandboxDataRoot + cleaned, nil
+}
+
+// OpenSandboxFile streams a file from the user's data directory. The caller must close the
+// returned reader, which also stops the transfer and removes any helper pod.
+func (c *Client) OpenSandboxFile(ctx context.Context, userID, p string) (*FileInfo, io.ReadCloser, error) {
+	target, err := SandboxPath(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pod, err := c.acquireDataPod(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = c.execInPod(ctx, pod.name, pod.container, ExecOptions{
+		Command: []string{"sh", "-c", statFileScript, "sh", target},
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	if err != nil {
+		pod.release()
+		return nil, nil, fileScriptError(err, userID, p, &stderr)
+	}
+	info, err := parseStatLine(strings.TrimSpace(stdout.String()), path.Dir(target))
+	if err != nil {
+		pod.release()
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	reader, writer := io.Pipe()
+	go func() {
+		defer pod.release()
+		var stderr bytes.Buffer
+		err := c.execInPod(ctx, pod.name, pod.container, ExecOptions{
+			Command: []string{"sh", "-c", readFileScript, "sh", target},
+			Stdout:  writer,
+			Stderr:  &stderr,
+		})
+		if err != nil {
+			err = fileScriptError(err, userID, p, &stderr)
+		}
+		writer.CloseWithError(err)
+	}()
+
+	return info, &fileReader{PipeReader: reader, cancel: cancel}, nil
+}
+
+// WriteSandboxFile stores content at a path in the user's data directory, creating parent
+// directories as needed and replacing any existing file
+func (c *Client) WriteSandboxFile(ctx context.Context, userID, p string, content io.Reader) (*FileInfo, error) {
+	target, err := SandboxPath(p)
+	if err != nil {
+		return nil, err
+	}
+	if target == sandboxDataRoot {
+		return nil, fmt.Errorf("%w: a file name is required", ErrInvalidPath)
+	}
+
+	pod, err := c.acquireDataPod(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer pod.release()
+
+	var stdout, stderr bytes.Buffer
+	err = c.execInPod(ctx, pod.name, pod.container, ExecOptions{
+		Command: []string{"sh", "-c", writeFileScript, "sh", target},
+		Stdin:   content,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	if err != nil {
+		return nil, fileScriptError(err, userID, p, &stderr)
+	}
+
+	log.Printf("File %s written for user %s", target, userID)
+	return parseStatLine(strings.TrimSpace(stdout.String()), path.Dir(target))
+}
+
+// ListSandboxFiles lists a directory in the user's data directory
+func (c *Client) ListSandboxFiles(ctx context.Context, userID, p string) ([]FileInfo, error) {
+	target, err := SandboxPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := c.acquireDataPod(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer pod.release()
+
+	var stdout, stderr bytes.Buffer
+	err = c.execInPod(ctx, pod.name, pod.container, ExecOptions{
+		Command: []string{"sh", "-c", listFilesScript, "sh", target},
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	if err != nil {
+		return nil, fileScriptError(err, userID, p, &stderr)
+	}
+
+	files := []FileInfo{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		info, err := parseStatLine(scanner.Text(), target)
+		if err != nil {
+			log.Printf("Skipping unreadable file entry for user %s: %v", userID, err)
+			continue
+		}
+		files = append(files, *info)
+	}
+	return files, scanner.Err()
+}
+
+// dataPod is a pod with the user's data volume mounted at /config
+type dataPod struct {
+	name      string
+	container string
+	release   func()
+}
+
+// acquireDataPod returns the user's running sandbox pod, or starts a helper pod with the user's
+// volume when the sandbox has no pods, e.g. because it is paused. Release removes the helper.
+func (c *Client) acquireDataPod(ctx context.Context, userID string) (*dataPod, error) {
+	pods, err := c.listSandboxPods(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for user %s: %w", userID, err)
+	}
+	if len(pods) > 0 {
+		// A starting pod holds the volume, so wait for it rather than competing for it
+		pod, err := c.getActiveSandboxPod(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return &dataPod{name: pod.Name, container: "sandbox", release: func() {}}, nil
+	}
+
+	pvc, err := c.getDataClaim(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return c.startFileHelper(ctx, userID, pvc.Name)
+}
+
+// startFileHelper starts a short-lived pod that mounts a user's data volume and waits for it to run
+func (c *Client) startFileHelper(ctx context.Context, userID, claimName string) (*dataPod, error) {
+	helperID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := fileHelperDeadline
+	uid := int64(0) // Match the init container, which makes /config writable for the sandbox user
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-files-%s", userID, helperID),
+			Labels: map[string]string{
+				"app":  "sandbox-files",
+				"user": userID,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Containers: []corev1.Container{
+				{
+					Name:            "files",
+					Image:           c.config.FileHelperImage,
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Command:         []string{"sleep", strconv.FormatInt(fileHelperDeadline, 10)},
+					VolumeMounts:    c.getUserDataVolumeMounts(),
+					SecurityContext: &corev1.SecurityContext{
+						RunAsUser: &uid,
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				c.getUserDataVolume(claimName),
+			},
+		},
+	}
+
+	pods := c.clientset.CoreV1().Pods(c.namespace)
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start file helper for user %s: %w", userID, err)
+	}
+	release := func() {
+		// The request may already be over, so cleanup gets its own context
+		if err := pods.Delete(context.Background(), pod.Name, metav1.DeleteOptions{}); err != nil {
+			log.Printf("Error deleting file helper %s: %v", pod.Name, err)
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, fileHelperStartTimeout)
+	defer cancel()
+	err = pollUntil(waitCtx, restorePollInterval, func() (bool, error) {
+		current, err := pods.Get(waitCtx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch current.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, fmt.Errorf("file helper %s stopped: %s", pod.Name, current.Status.Reason)
+		}
+		return false, nil
+	})
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("file helper for user %s did not start: %w", userID, err)
+	}
+
+	log.Printf("Started file helper %s for user %s", pod.Name, userID)
+	return &dataPod{name: pod.Name, container: "files", release: release}, nil
+}
+
+// fileReader stops the transfer when the reader is closed early
+type fileReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+// Close stops the transfer
+func (r *fileReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// fileScriptError turns a failed file script into a not found, invalid path or exec error
+func fileScriptError(err error, userID, p string, stderr *bytes.Buffer) error {
+	exitCode, ok := ExecExitCode(err)
+	if !ok {
+		return err
+	}
+	message := strings.TrimSpace(stderr.String())
+	switch exitCode {
+	case fileExitNotFound:
+		return fmt.Errorf("path %s not found in sandbox for user ID %s", p, userID)
+	case fileExitWrongType:
+		return fmt.Errorf("%w: %s: %s", ErrInvalidPath, p, message)
+	}
+	return fmt.Errorf("file operation on %s failed with exit code %d: %s", p, exitCode, message)
+}
+
+// parseStatLine parses a line printed with statFormat. Names are resolved against dir,
+// an absolute directory in the container, and reported relative to the data directory.
+func parseStatLine(line, dir string) (*FileInfo, error) {
+	fields := strings.SplitN(line, "|", 4)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unexpected stat output: %q", line)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected size in stat output: %q", line)
+	}
+	modified, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected time in stat output: %q", line)
+	}
+
+	name := path.Base(fields[3])
+	return &FileInfo{
+		Name:       name,
+		Path:       strings.TrimPrefix(path.Join(dir, name), sandboxDataRoot),
+		Type:       fileType(fields[0]),
+		Size:       size,
+		ModifiedAt: time.Unix(modified, 0).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// fileType maps stat's file type description to a short name
+func fileType(description string) string {
+	switch description {
+	case "regular file", "regular empty file":
+		return "file"
+	case "directory":
+		return "directory"
+	case "symbolic link":
+		return "symlink"
+	}
+	return "other"
+}
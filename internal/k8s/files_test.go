@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSandboxPath(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		expected      string
+		expectedError bool
+	}{
+		{"Empty path is the data directory", "", "/config", false},
+		{"Root is the data directory", "/", "/config", false},
+		{"Relative path", "downloads/report.pdf", "/config/downloads/report.pdf", false},
+		{"Absolute path", "/downloads/report.pdf", "/config/downloads/report.pdf", false},
+		{"Redundant separators and dots", "//downloads/./report.pdf", "/config/downloads/report.pdf", false},
+		{"Parent element", "../etc/passwd", "", true},
+		{"Parent element inside path", "/downloads/../../etc/passwd", "", true},
+		{"NUL byte", "report\x00.pdf", "", true},
+		{"Dots inside names are allowed", "/notes..txt", "/config/notes..txt", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SandboxPath(tc.input)
+			if tc.expectedError {
+				if !errors.Is(err, ErrInvalidPath) {
+					t.Fatalf("Expected ErrInvalidPath, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseStatLine(t *testing.T) {
+	info, err := parseStatLine("regular file|52133|1681993800|a|b.pdf", "/config/downloads")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := FileInfo{
+		Name:       "a|b.pdf",
+		Path:       "/downloads/a|b.pdf",
+		Type:       "file",
+		Size:       52133,
+		ModifiedAt: "2023-04-20T12:30:00Z",
+	}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+
+	if _, err := parseStatLine("directory|4096", "/config"); err == nil {
+		t.Error("Expected an error for a truncated line")
+	}
+}
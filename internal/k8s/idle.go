@@ -0,0 +1,130 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// annotationLastActivity records when a sandbox was last known to be in use
+const annotationLastActivity = "sandbox.tryiris.dev/last-activity"
+
+// activityProbeTimeout bounds a request to a sandbox's activity endpoint
+const activityProbeTimeout = 2 * time.Second
+
+// activityReport is the body returned by a sandbox's activity endpoint
+type activityReport struct {
+	LastActivity string `json:"lastActivity"`
+}
+
+// sandboxLastActivity returns when a sandbox was last active, falling back to its creation time
+func sandboxLastActivity(deployment *appsv1.Deployment) time.Time {
+	lastActivity := deployment.CreationTimestamp.Time
+	if recorded, err := time.Parse(time.RFC3339, deployment.Annotations[annotationLastActivity]); err == nil && recorded.After(lastActivity) {
+		lastActivity = recorded
+	}
+	return lastActivity
+}
+
+// activityAnnotation returns the annotation recording activity at the given time
+func activityAnnotation(at time.Time) map[string]string {
+	return map[string]string{
+		annotationLastActivity: at.UTC().Format(time.RFC3339),
+	}
+}
+
+// handleIdleSandbox pauses or deletes a running sandbox that has had no activity for
+// longer than the configured idle timeout. When an activity path is configured the
+// sandbox's own API is asked before acting, so work that never called keepalive still counts.
+func (c *ClientWithTraefik) handleIdleSandbox(ctx context.Context, deployment *appsv1.Deployment, userID string, now time.Time) {
+	// A paused sandbox is already idle; only its TTL applies
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		return
+	}
+
+	lastActivity := sandboxLastActivity(deployment)
+	if now.Sub(lastActivity) < c.config.SandboxIdleTimeout {
+		return
+	}
+
+	lastActivity = c.observedLastActivity(ctx, userID, lastActivity)
+	idle := now.Sub(lastActivity)
+	if idle < c.config.SandboxIdleTimeout {
+		return
+	}
+
+	switch c.config.SandboxIdleAction {
+	case config.IdleActionDelete:
+		log.Printf("Deleting idle sandbox for user %s (idle: %v)", userID, idle.Round(time.Second))
+		if err := c.DeleteSandbox(ctx, userID); err != nil {
+			log.Printf("Error deleting idle sandbox for user %s: %v", userID, err)
+		}
+	default:
+		log.Printf("Pausing idle sandbox for user %s (idle: %v)", userID, idle.Round(time.Second))
+		if err := c.PauseSandbox(ctx, userID); err != nil {
+			log.Printf("Error pausing idle sandbox for user %s: %v", userID, err)
+		}
+	}
+}
+
+// observedLastActivity returns the later of lastActivity and the activity the sandbox reports
+// itself when an activity path is configured, recording newer activity on the deployment.
+// If the sandbox cannot be asked, lastActivity is returned.
+func (c *Client) observedLastActivity(ctx context.Context, userID string, lastActivity time.Time) time.Time {
+	if c.config.SandboxActivityPath == "" {
+		return lastActivity
+	}
+
+	reported, err := c.probeSandboxActivity(ctx, userID)
+	if err != nil {
+		log.Printf("Could not read activity for sandbox of user %s: %v", userID, err)
+		return lastActivity
+	}
+	if !reported.After(lastActivity) {
+		return lastActivity
+	}
+	if err := c.recordSandboxActivity(ctx, userID, reported); err != nil {
+		log.Printf("Error recording activity for user %s: %v", userID, err)
+	}
+	return reported
+}
+
+// probeSandboxActivity asks the sandbox's port 3000 API when it was last active.
+// The endpoint is expected to return {"lastActivity": "<RFC 3339 time>"}.
+func (c *Client) probeSandboxActivity(ctx context.Context, userID string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, activityProbeTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s-service.%s.svc.cluster.local:3000%s", userID, c.namespace, c.config.SandboxActivityPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("activity endpoint returned %s", resp.Status)
+	}
+
+	var report activityReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return time.Time{}, fmt.Errorf("invalid activity response: %w", err)
+	}
+	return time.Parse(time.RFC3339, report.LastActivity)
+}
+
+// recordSandboxActivity stores the time a sandbox was last active on its deployment
+func (c *Client) recordSandboxActivity(ctx context.Context, userID string, at time.Time) error {
+	return c.patchDeploymentAnnotations(ctx, userID, activityAnnotation(at))
+}
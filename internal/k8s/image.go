@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+)
+
+// annotationImage records an image chosen explicitly at create time
+const annotationImage = "sandbox.tryiris.dev/image"
+
+// imageTagPattern matches a valid container image tag
+var imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// sandboxImage returns the agent image reference for a tag
+func sandboxImage(tag string) string {
+	return fmt.Sprintf("%s/iris_agent:%s", config.DefaultImageRegistry, tag)
+}
+
+// validateImageOptions checks a requested image tag or image reference against the allow-lists
+func (c *Client) validateImageOptions(opts SandboxOptions) error {
+	if opts.ImageTag != "" && opts.Image != "" {
+		return fmt.Errorf("%w: imageTag and image cannot both be set", ErrInvalidSandboxOptions)
+	}
+
+	if opts.ImageTag != "" {
+		if !imageTagPattern.MatchString(opts.ImageTag) {
+			return fmt.Errorf("%w: invalid image tag %q", ErrInvalidSandboxOptions, opts.ImageTag)
+		}
+		for _, pattern := range c.config.AllowedImageTags {
+			if matched, _ := path.Match(pattern, opts.ImageTag); matched {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: image tag %q is not allowed", ErrInvalidSandboxOptions, opts.ImageTag)
+	}
+
+	if opts.Image != "" {
+		if strings.ContainsAny(opts.Image, " \t\n") {
+			return fmt.Errorf("%w: invalid image %q", ErrInvalidSandboxOptions, opts.Image)
+		}
+		for _, registry := range c.config.AllowedImageRegistries {
+			// Match whole path segments so "repo" does not allow "repo-other"
+			if strings.HasPrefix(opts.Image, strings.TrimSuffix(registry, "/")+"/") {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: image %q is not from an allowed registry", ErrInvalidSandboxOptions, opts.Image)
+	}
+
+	return nil
+}
+
+// resolveImage returns the image for a sandbox: the requested image or tag if one was chosen,
+// otherwise the default tag from the app-config ConfigMap
+func (c *Client) resolveImage(ctx context.Context, opts SandboxOptions) (string, error) {
+	switch {
+	case opts.Image != "":
+		return opts.Image, nil
+	case opts.ImageTag != "":
+		return sandboxImage(opts.ImageTag), nil
+	}
+
+	imageTag, err := c.getImageTagFromConfigMap(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image tag from configmap: %v", err)
+	}
+	return sandboxImage(imageTag), nil
+}
+
+// imageRequested reports whether the options choose an image explicitly
+func (opts SandboxOptions) imageRequested() bool {
+	return opts.Image != "" || opts.ImageTag != ""
+}
@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PauseSandbox scales the user's deployment to zero replicas.
+// The PVC, Service and IngressRoutes are left in place so the sandbox can be resumed quickly.
+func (c *Client) PauseSandbox(ctx context.Context, userID string) error {
+	if err := c.scaleSandbox(ctx, userID, 0); err != nil {
+		return err
+	}
+
+	c.triggerQueue()
+
+	log.Printf("Sandbox paused for user: %s", userID)
+	return nil
+}
+
+// ResumeSandbox scales a paused sandbox back up to a single replica.
+// Resuming counts as activity, so idle cleanup does not pause it again straight away.
+// It fails with a QuotaExceededError if the running sandbox would exceed a quota,
+// and with ErrInsufficientCapacity if no node has room for it.
+func (c *Client) ResumeSandbox(ctx context.Context, userID string) error {
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, fmt.Sprintf("%s-deployment", userID), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("sandbox not found for user ID %s: %w", userID, err)
+		}
+		return err
+	}
+	owner, _ := deploymentOwnership(deployment)
+	unlockQuota := c.lockQuota(owner)
+	defer unlockQuota()
+	if err := c.checkResumeQuota(ctx, userID, deployment); err != nil {
+		return err
+	}
+	preempted, err := c.checkResumeCapacity(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	if err := c.scaleSandbox(ctx, userID, 1); err != nil {
+		c.resumePreempted(preempted)
+		return err
+	}
+	if err := c.recordSandboxActivity(ctx, userID, time.Now()); err != nil {
+		log.Printf("Error recording activity for user %s: %v", userID, err)
+	}
+
+	log.Printf("Sandbox resumed for user: %s", userID)
+	return nil
+}
+
+// scaleSandbox sets the replica count of the user's deployment
+func (c *Client) scaleSandbox(ctx context.Context, userID string, replicas int32) error {
+	deploymentName := fmt.Sprintf("%s-deployment", userID)
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	_, err := c.clientset.AppsV1().Deployments(c.namespace).Patch(ctx, deploymentName,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("sandbox not found for user ID %s: %w", userID, err)
+		}
+		return fmt.Errorf("failed to scale deployment %s: %w", deploymentName, err)
+	}
+
+	return nil
+}
+
+// patchDeploymentAnnotations merges annotations into the user's deployment
+func (c *Client) patchDeploymentAnnotations(ctx context.Context, userID string, annotations map[string]string) error {
+	deploymentName := fmt.Sprintf("%s-deployment", userID)
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.clientset.AppsV1().Deployments(c.namespace).Patch(ctx, deploymentName,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("sandbox not found for user ID %s: %w", userID, err)
+		}
+		return fmt.Errorf("failed to annotate deployment %s: %w", deploymentName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// SandboxLogContainers are the containers of a sandbox pod whose logs may be read
+var SandboxLogContainers = []string{"sandbox", "volume-permissions"}
+
+// ErrLogsUnavailable is returned when the requested logs do not exist, e.g. previous
+// logs of a container that has not restarted or logs of a container that has not started
+var ErrLogsUnavailable = errors.New("logs are not available")
+
+// LogOptions selects which container logs to read and how much of them
+type LogOptions struct {
+	// Container name, defaults to sandbox
+	Container string
+	// TailLines limits the output to the last lines when set
+	TailLines *int64
+	// SinceSeconds limits the output to recent lines when set
+	SinceSeconds *int64
+	// Previous reads the logs of the previous, terminated instance of the container
+	Previous bool
+	// Follow keeps the stream open for new lines until ctx is cancelled
+	Follow bool
+}
+
+// IsSandboxLogContainer reports whether logs may be read from the named container
+func IsSandboxLogContainer(name string) bool {
+	for _, container := range SandboxLogContainers {
+		if container == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamSandboxLogs opens the logs of a container in the user's newest pod.
+// Unlike exec the pod does not have to be running, so crashed containers can be inspected.
+// It returns the pod name along with the stream, which the caller must close.
+func (c *Client) StreamSandboxLogs(ctx context.Context, userID string, opts LogOptions) (io.ReadCloser, string, error) {
+	if opts.Container == "" {
+		opts.Container = "sandbox"
+	}
+	if !IsSandboxLogContainer(opts.Container) {
+		return nil, "", fmt.Errorf("%w: unknown container %q", ErrLogsUnavailable, opts.Container)
+	}
+
+	pod, err := c.getNewestSandboxPod(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stream, err := c.clientset.CoreV1().Pods(c.namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:    opts.Container,
+		TailLines:    opts.TailLines,
+		SinceSeconds: opts.SinceSeconds,
+		Previous:     opts.Previous,
+		Follow:       opts.Follow,
+	}).Stream(ctx)
+	if err != nil {
+		// The API server answers 400 when the container has no such logs yet
+		if apierrors.IsBadRequest(err) {
+			return nil, pod.Name, fmt.Errorf("%w for pod %s: %v", ErrLogsUnavailable, pod.Name, err)
+		}
+		return nil, pod.Name, fmt.Errorf("failed to read logs for pod %s: %w", pod.Name, err)
+	}
+	return stream, pod.Name, nil
+}
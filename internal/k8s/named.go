@@ -0,0 +1,165 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Named sandbox settings
+const (
+	// DefaultSandboxName is the name of the sandbox created by POST /v1/sandbox/{userId}
+	DefaultSandboxName = "default"
+	// sandboxIDSeparator joins a user ID and a sandbox name into a sandbox ID
+	sandboxIDSeparator = "--"
+	// maxSandboxNameLength keeps hostnames and service names within the 63 character label limit
+	maxSandboxNameLength = 20
+	// maxSandboxIDLength leaves room for the -service suffix in a 63 character service name
+	maxSandboxIDLength = 55
+)
+
+// ErrSandboxOwnedByOtherUser is returned when a sandbox ID is already used by another user's sandbox
+var ErrSandboxOwnedByOtherUser = errors.New("sandbox ID belongs to another user")
+
+// SandboxID returns the ID a sandbox's resources and hostnames are named after.
+// A user's default sandbox is identified by the user ID alone, which keeps existing
+// sandboxes working; named sandboxes are {userId}--{name}.
+func SandboxID(userID, name string) string {
+	if name == "" || name == DefaultSandboxName {
+		return userID
+	}
+	return userID + sandboxIDSeparator + name
+}
+
+// NewSandboxName returns a generated name for a named sandbox
+func NewSandboxName() (string, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+	return "sb" + id, nil
+}
+
+// validateSandboxName checks the owner and name of a named sandbox
+func validateSandboxName(opts SandboxOptions) error {
+	if opts.Owner == "" {
+		if opts.Name != "" || opts.SharedStorage {
+			return fmt.Errorf("%w: name and shared storage are only supported for named sandboxes", ErrInvalidSandboxOptions)
+		}
+		return nil
+	}
+
+	if strings.Contains(opts.Owner, sandboxIDSeparator) {
+		return fmt.Errorf("%w: user IDs with named sandboxes may not contain %q", ErrInvalidSandboxOptions, sandboxIDSeparator)
+	}
+	if opts.Name == DefaultSandboxName {
+		return fmt.Errorf("%w: %q is reserved for the user's default sandbox", ErrInvalidSandboxOptions, DefaultSandboxName)
+	}
+	if len(opts.Name) > maxSandboxNameLength {
+		return fmt.Errorf("%w: sandbox name must be %d characters or less", ErrInvalidSandboxOptions, maxSandboxNameLength)
+	}
+	if valid, errMsg := IsValidKubernetesName(opts.Name); !valid {
+		return fmt.Errorf("%w: invalid sandbox name: %s", ErrInvalidSandboxOptions, errMsg)
+	}
+	if strings.Contains(opts.Name, sandboxIDSeparator) {
+		return fmt.Errorf("%w: sandbox name may not contain %q", ErrInvalidSandboxOptions, sandboxIDSeparator)
+	}
+	if id := SandboxID(opts.Owner, opts.Name); len(id) > maxSandboxIDLength {
+		return fmt.Errorf("%w: sandbox ID %s must be %d characters or less", ErrInvalidSandboxOptions, id, maxSandboxIDLength)
+	}
+	return nil
+}
+
+// ownership returns the user and sandbox name of the sandbox with the given ID
+func (opts SandboxOptions) ownership(sandboxID string) (string, string) {
+	if opts.Owner == "" {
+		return sandboxID, DefaultSandboxName
+	}
+	return opts.Owner, opts.Name
+}
+
+// ownerLabels returns the labels that group a sandbox's resources by user: owner is the
+// user and sandbox the sandbox name. The user label that sandbox resources also carry is
+// the sandbox ID, so selectors on it match one sandbox, not every sandbox of a user.
+func (opts SandboxOptions) ownerLabels(sandboxID string) map[string]string {
+	owner, name := opts.ownership(sandboxID)
+	return map[string]string{
+		"owner":   owner,
+		"sandbox": name,
+	}
+}
+
+// deploymentOwnership returns the user and sandbox name of a sandbox deployment.
+// Deployments created before named sandboxes only carry the user label.
+func deploymentOwnership(deployment *appsv1.Deployment) (string, string) {
+	owner := deployment.Labels["owner"]
+	if owner == "" {
+		owner = deployment.Labels["user"]
+	}
+	name := deployment.Labels["sandbox"]
+	if name == "" {
+		name = DefaultSandboxName
+	}
+	return owner, name
+}
+
+// checkSandboxOwner makes sure an existing sandbox with the given ID belongs to the requesting user,
+// so a user ID that looks like {userId}--{name} cannot take over another user's named sandbox
+func (c *Client) checkSandboxOwner(ctx context.Context, sandboxID string, opts SandboxOptions) error {
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, fmt.Sprintf("%s-deployment", sandboxID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	owner, name := opts.ownership(sandboxID)
+	existingOwner, existingName := deploymentOwnership(deployment)
+	if existingOwner != owner || existingName != name {
+		return fmt.Errorf("%w: %s", ErrSandboxOwnedByOtherUser, sandboxID)
+	}
+	return nil
+}
+
+// ListUserSandboxes lists the default and named sandboxes of a user
+func (c *ClientWithTraefik) ListUserSandboxes(ctx context.Context, userID string) ([]SandboxInfo, error) {
+	sandboxes, err := c.ListSandboxes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []SandboxInfo{}
+	for _, sandbox := range sandboxes {
+		if sandbox.Owner == userID {
+			owned = append(owned, sandbox)
+		}
+	}
+	return owned, nil
+}
+
+// DeleteUserSandboxes deletes the default and named sandboxes of a user and returns their IDs.
+// Data volumes are kept, as with DeleteSandbox.
+func (c *ClientWithTraefik) DeleteUserSandboxes(ctx context.Context, userID string) ([]string, error) {
+	sandboxes, err := c.ListUserSandboxes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []string{}
+	for _, sandbox := range sandboxes {
+		if err := c.DeleteSandbox(ctx, sandbox.UserID); err != nil {
+			return deleted, fmt.Errorf("failed to delete sandbox %s: %w", sandbox.UserID, err)
+		}
+		deleted = append(deleted, sandbox.UserID)
+	}
+
+	log.Printf("Deleted %d sandboxes for user: %s", len(deleted), userID)
+	return deleted, nil
+}
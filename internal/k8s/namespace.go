@@ -7,20 +7,21 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// ensureNamespace creates the namespace if it doesn't exist
+// ensureNamespace creates the namespace if it doesn't exist, along with its limits
 func (c *Client) ensureNamespace(ctx context.Context) error {
 	_, err := c.clientset.CoreV1().Namespaces().Get(ctx, c.namespace, metav1.GetOptions{})
-	if err == nil {
-		// Namespace exists
-		return nil
+	if err != nil {
+		// Create namespace
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   c.namespace,
+				Labels: c.namespaceLabels(),
+			},
+		}
+		if _, err := c.clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+			return err
+		}
 	}
 
-	// Create namespace
-	ns := &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: c.namespace,
-		},
-	}
-	_, err = c.clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
-	return err
+	return c.ensureNamespaceLimits(ctx)
 }
\ No newline at end of file
@@ -12,9 +12,15 @@ import (
 
 // createService creates a service for the user's sandbox
 func (c *Client) createService(ctx context.Context, userID string) error {
+	_, err := c.clientset.CoreV1().Services(c.namespace).Create(ctx, c.buildService(userID), metav1.CreateOptions{})
+	return err
+}
+
+// buildService returns the desired service for the user's sandbox
+func (c *Client) buildService(userID string) *corev1.Service {
 	serviceName := fmt.Sprintf("%s-service", userID)
-	
-	service := &corev1.Service{
+
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: serviceName,
 		},
@@ -37,9 +43,6 @@ func (c *Client) createService(ctx context.Context, userID string) error {
 			},
 		},
 	}
-
-	_, err := c.clientset.CoreV1().Services(c.namespace).Create(ctx, service, metav1.CreateOptions{})
-	return err
 }
 
 // createIngress creates an ingress for the user's sandbox
@@ -104,4 +107,9 @@ func (c *Client) createIngress(ctx context.Context, userID string) error {
 
 	_, err := c.clientset.NetworkingV1().Ingresses(c.namespace).Create(ctx, ingress, metav1.CreateOptions{})
 	return err
-}
\ No newline at end of file
+}
+
+// SandboxURL returns the public URL of a sandbox's vnc or api route
+func (c *Client) SandboxURL(userID, suffix string) string {
+	return fmt.Sprintf("https://%s-%s.%s", userID, suffix, c.domain)
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSandboxOptions is returned when a create request asks for settings that are not allowed
+var ErrInvalidSandboxOptions = errors.New("invalid sandbox options")
+
+// SandboxOptions are the per-sandbox settings chosen at create time.
+// Zero values select the configured defaults.
+type SandboxOptions struct {
+	// TTL is how long the sandbox lives before auto cleanup removes it
+	TTL time.Duration
+	// Profile names the configured sandbox size
+	Profile string
+	// ImageTag selects an allow-listed tag of the agent image
+	ImageTag string
+	// Image is a full image reference from an allow-listed registry
+	Image string
+	// Env holds plain environment variables with allow-listed names
+	Env map[string]string
+	// Secrets holds values stored in the sandbox's Secret and exposed as environment variables
+	Secrets map[string]string
+	// Owner is the user a named sandbox belongs to; empty for a user's default sandbox
+	Owner string
+	// Name of a named sandbox
+	Name string
+	// SharedStorage mounts the owner's default data volume instead of a separate one
+	SharedStorage bool
+	// Tier is the priority tier (free, internal or paying); empty means the default tier
+	Tier string
+}
+
+// ValidateSandboxOptions checks the options against the configured limits
+func (c *Client) ValidateSandboxOptions(opts SandboxOptions) error {
+	if opts.TTL < 0 {
+		return fmt.Errorf("%w: ttl must not be negative", ErrInvalidSandboxOptions)
+	}
+	if opts.TTL > c.config.SandboxMaxTTL {
+		return fmt.Errorf("%w: ttl must not exceed %v", ErrInvalidSandboxOptions, c.config.SandboxMaxTTL)
+	}
+	if _, _, err := c.resolveProfile(opts.Profile); err != nil {
+		return err
+	}
+	if err := c.validateImageOptions(opts); err != nil {
+		return err
+	}
+	if err := c.validateEnvOptions(opts); err != nil {
+		return err
+	}
+	if err := validateTier(opts.Tier); err != nil {
+		return err
+	}
+	return validateSandboxName(opts)
+}
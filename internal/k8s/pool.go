@@ -0,0 +1,553 @@
+package k8s
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Warm pool settings
+const (
+	// poolAppLabel marks unassigned pool pods and their claims
+	poolAppLabel = "sandbox-pool"
+	// poolReconcileInterval is how often the pool is topped up and stale pods replaced
+	poolReconcileInterval = 15 * time.Second
+	// MaxPoolSize bounds the number of warm sandboxes
+	MaxPoolSize = 20
+)
+
+// ErrPoolNotRunning is returned when managing the pool before it has been started
+var ErrPoolNotRunning = errors.New("warm pool is not running")
+
+// PoolStatus describes the warm pool
+type PoolStatus struct {
+	// Number of warm sandboxes the pool keeps
+	TargetSize int `json:"targetSize" example:"3"`
+	// Pool pods that are ready to be claimed
+	Ready int `json:"ready" example:"2"`
+	// Pool pods that are still starting
+	Warming int `json:"warming" example:"1"`
+	// Creates served from the pool since startup
+	Hits int64 `json:"hits" example:"42"`
+	// Eligible creates that found no ready pool pod since startup
+	Misses int64 `json:"misses" example:"5"`
+}
+
+// warmPool holds the pool's target size and hit counters
+type warmPool struct {
+	mu      sync.Mutex
+	target  int
+	hits    atomic.Int64
+	misses  atomic.Int64
+	trigger chan struct{}
+}
+
+// poolClaim is a pool pod and claim handed over to a user
+type poolClaim struct {
+	podName        string
+	pvcName        string
+	replicaSetName string
+}
+
+// StartWarmPool starts the pool manager with the configured size.
+// It keeps that many unassigned sandbox pods running with the current default image.
+func (c *Client) StartWarmPool(ctx context.Context) {
+	c.pool = &warmPool{
+		target:  min(c.config.SandboxPoolSize, MaxPoolSize),
+		trigger: make(chan struct{}, 1),
+	}
+
+	go func() {
+		ticker := time.NewTicker(poolReconcileInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.reconcilePool(ctx); err != nil {
+				log.Printf("Error reconciling warm pool: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("Warm pool manager stopped")
+				return
+			case <-ticker.C:
+			case <-c.pool.trigger:
+			}
+		}
+	}()
+	log.Printf("Warm pool manager started with %d sandboxes", c.pool.target)
+}
+
+// GetPoolStatus returns the pool's size, readiness and hit counters
+func (c *Client) GetPoolStatus(ctx context.Context) (*PoolStatus, error) {
+	if c.pool == nil {
+		return nil, ErrPoolNotRunning
+	}
+
+	pods, err := c.listPoolPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &PoolStatus{
+		TargetSize: c.pool.targetSize(),
+		Hits:       c.pool.hits.Load(),
+		Misses:     c.pool.misses.Load(),
+	}
+	for i := range pods {
+		if pods[i].DeletionTimestamp != nil {
+			continue
+		}
+		if poolPodReady(&pods[i]) {
+			status.Ready++
+		} else {
+			status.Warming++
+		}
+	}
+	return status, nil
+}
+
+// ResizePool changes the number of warm sandboxes. The change is applied in the background
+// and lasts until the service restarts, when SANDBOX_POOL_SIZE applies again.
+func (c *Client) ResizePool(ctx context.Context, size int) (*PoolStatus, error) {
+	if c.pool == nil {
+		return nil, ErrPoolNotRunning
+	}
+	if size < 0 || size > MaxPoolSize {
+		return nil, fmt.Errorf("%w: pool size must be between 0 and %d", ErrInvalidSandboxOptions, MaxPoolSize)
+	}
+
+	c.pool.mu.Lock()
+	c.pool.target = size
+	c.pool.mu.Unlock()
+
+	select {
+	case c.pool.trigger <- struct{}{}:
+	default:
+	}
+
+	log.Printf("Warm pool resized to %d sandboxes", size)
+	return c.GetPoolStatus(ctx)
+}
+
+// targetSize returns the number of warm sandboxes to keep
+func (p *warmPool) targetSize() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.target
+}
+
+// reconcilePool replaces failed and outdated pool pods and creates or removes pods to reach the target size
+func (c *Client) reconcilePool(ctx context.Context) error {
+	image, err := c.resolveImage(ctx, SandboxOptions{})
+	if err != nil {
+		return err
+	}
+
+	pods, err := c.listPoolPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	var current, pending []corev1.Pod
+	live := make(map[string]bool)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			live[pod.Labels["pool-id"]] = true
+			continue
+		}
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded || podImage(&pod) != image {
+			log.Printf("Replacing warm pool pod %s", pod.Name)
+			c.deletePoolSandbox(ctx, &pod)
+			continue
+		}
+		live[pod.Labels["pool-id"]] = true
+		if poolPodReady(&pod) {
+			current = append(current, pod)
+		} else {
+			pending = append(pending, pod)
+		}
+	}
+
+	// Shrink starting with pods that are not ready yet
+	target := c.pool.targetSize()
+	current = append(pending, current...)
+	for len(current) > target {
+		c.deletePoolSandbox(ctx, &current[0])
+		current = current[1:]
+	}
+
+	for i := len(current); i < target; i++ {
+		poolID, err := c.createPoolSandbox(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create warm pool sandbox: %w", err)
+		}
+		live[poolID] = true
+	}
+
+	// Claimed pods keep their pool-id, so a claim being handed over is not mistaken for an orphan
+	claimed, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox,pool-id",
+	})
+	if err != nil {
+		return err
+	}
+	for _, pod := range claimed.Items {
+		live[pod.Labels["pool-id"]] = true
+	}
+
+	// Remove claims left behind by pool pods that no longer exist
+	claims, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=" + poolAppLabel,
+	})
+	if err != nil {
+		return err
+	}
+	for _, claim := range claims.Items {
+		if !live[claim.Labels["pool-id"]] {
+			if err := c.deletePVC(ctx, claim.Name); err != nil && !apierrors.IsNotFound(err) {
+				log.Printf("Error deleting warm pool claim %s: %v", claim.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// createPoolSandbox creates an unassigned sandbox pod and its claim with the default settings
+func (c *Client) createPoolSandbox(ctx context.Context) (string, error) {
+	poolID, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+	name := "pool-" + poolID
+	labels := map[string]string{
+		"app":     poolAppLabel,
+		"pool-id": poolID,
+	}
+
+	_, size, err := c.resolveProfile("")
+	if err != nil {
+		return "", err
+	}
+	if err := c.ensurePriorityClass(ctx, DefaultTier); err != nil {
+		return "", err
+	}
+	pvc := c.buildDataPVC(fmt.Sprintf("%s-pvc", name), size, true)
+	pvc.Labels = labels
+	if _, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		return "", err
+	}
+
+	// The pod is built like a sandbox deployment's pod, but waits for a user before starting
+	deployment, err := c.buildDeployment(ctx, name, SandboxOptions{})
+	if err != nil {
+		return "", err
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: deployment.Spec.Template.Annotations,
+		},
+		Spec: poolPodSpec(deployment.Spec.Template.Spec),
+	}
+	if _, err := c.clientset.CoreV1().Pods(c.namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		if removeErr := c.deletePVC(ctx, pvc.Name); removeErr != nil {
+			log.Printf("Error deleting warm pool claim %s: %v", pvc.Name, removeErr)
+		}
+		return "", err
+	}
+
+	log.Printf("Created warm pool sandbox %s", name)
+	return poolID, nil
+}
+
+// deletePoolSandbox deletes an unassigned pool pod and its claim
+func (c *Client) deletePoolSandbox(ctx context.Context, pod *corev1.Pod) {
+	if err := c.clientset.CoreV1().Pods(c.namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Error deleting warm pool pod %s: %v", pod.Name, err)
+		return
+	}
+	if err := c.deletePVC(ctx, fmt.Sprintf("%s-pvc", pod.Name)); err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Error deleting warm pool claim for %s: %v", pod.Name, err)
+	}
+}
+
+// listPoolPods lists the unassigned pool pods
+func (c *Client) listPoolPods(ctx context.Context) ([]corev1.Pod, error) {
+	pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=" + poolAppLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// poolEligible reports whether a create can be served from the pool. Only brand new
+// sandboxes with default settings qualify: a user's existing PVC cannot be attached to
+// a pool pod, and other profiles, images or environments need a different pod.
+func (c *Client) poolEligible(ctx context.Context, userID string, opts SandboxOptions) (bool, error) {
+	if c.pool == nil || c.pool.targetSize() == 0 {
+		return false, nil
+	}
+	if (opts.Profile != "" && opts.Profile != c.config.DefaultSandboxProfile) ||
+		(opts.Tier != "" && opts.Tier != DefaultTier) ||
+		opts.imageRequested() || len(opts.Env) > 0 || len(opts.Secrets) > 0 || opts.SharedStorage {
+		return false, nil
+	}
+
+	_, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, fmt.Sprintf("%s-deployment", userID), metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	claimName, err := c.dataClaimName(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	_, err = c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// claimPoolSandbox hands a ready pool pod and its claim over to a new user.
+// The pod is relabelled into a ReplicaSet built from the user's pod template, which the
+// user's Deployment then adopts, so the pod is never restarted. The user label lets the
+// pod's sandbox container start, with the user's USER_ID.
+// It returns nil without an error when the create is not eligible or no pod is ready.
+func (c *Client) claimPoolSandbox(ctx context.Context, userID string, opts SandboxOptions) (*poolClaim, error) {
+	eligible, err := c.poolEligible(ctx, userID, opts)
+	if err != nil || !eligible {
+		return nil, err
+	}
+
+	image, err := c.resolveImage(ctx, SandboxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := c.listPoolPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil || !poolPodReady(pod) || podImage(pod) != image {
+			continue
+		}
+
+		claim, err := c.claimPoolPod(ctx, userID, opts, pod)
+		if apierrors.IsConflict(err) {
+			// Another create claimed this pod first
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		c.pool.hits.Add(1)
+		c.triggerPoolRefill()
+		log.Printf("Claimed warm pool pod %s for user %s", pod.Name, userID)
+		return claim, nil
+	}
+
+	c.pool.misses.Add(1)
+	log.Printf("No warm pool pod ready for user %s, falling back to a cold start", userID)
+	return nil, nil
+}
+
+// claimPoolPod moves one pool pod and its claim to a user
+func (c *Client) claimPoolPod(ctx context.Context, userID string, opts SandboxOptions, pod *corev1.Pod) (*poolClaim, error) {
+	poolID := pod.Labels["pool-id"]
+	templateHash := "warm-" + poolID
+	userLabels := map[string]string{
+		"app":  "user-sandbox",
+		"user": userID,
+	}
+
+	// The update carries the listed resourceVersion, so only one create can claim the pod
+	pod.Labels = map[string]string{
+		"app":               "user-sandbox",
+		"user":              userID,
+		"pod-template-hash": templateHash,
+		"pool-id":           poolID,
+	}
+	if _, err := c.clientset.CoreV1().Pods(c.namespace).Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+
+	claim := &poolClaim{
+		podName:        pod.Name,
+		pvcName:        fmt.Sprintf("%s-pvc", pod.Name),
+		replicaSetName: fmt.Sprintf("%s-deployment-%s", userID, templateHash),
+	}
+
+	// From here on a failure removes the pod and claim; the pool replaces them
+	fail := func(err error) (*poolClaim, error) {
+		c.deletePoolSandbox(context.Background(), pod)
+		return nil, err
+	}
+
+	claims := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace)
+	pvc, err := claims.Get(ctx, claim.pvcName, metav1.GetOptions{})
+	if err != nil {
+		return fail(err)
+	}
+	pvc.Labels = opts.ownerLabels(userID)
+	for key, value := range userLabels {
+		pvc.Labels[key] = value
+	}
+	if _, err := claims.Update(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+		return fail(err)
+	}
+
+	// The template now references the pool claim, since it is labelled for the user
+	desired, err := c.buildDeployment(ctx, userID, opts)
+	if err != nil {
+		return fail(err)
+	}
+
+	template := *desired.Spec.Template.DeepCopy()
+	template.Labels["pod-template-hash"] = templateHash
+	replicas := int32(1)
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   claim.replicaSetName,
+			Labels: template.Labels,
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":               "user-sandbox",
+					"user":              userID,
+					"pod-template-hash": templateHash,
+				},
+			},
+			Template: template,
+		},
+	}
+	if _, err := c.clientset.AppsV1().ReplicaSets(c.namespace).Create(ctx, replicaSet, metav1.CreateOptions{}); err != nil {
+		return fail(err)
+	}
+
+	return claim, nil
+}
+
+// triggerPoolRefill asks the pool manager to replace a claimed pod without waiting for the next tick
+func (c *Client) triggerPoolRefill() {
+	select {
+	case c.pool.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// deleteReplicaSet deletes a ReplicaSet and its pods by name
+func (c *Client) deleteReplicaSet(ctx context.Context, name string) error {
+	propagation := metav1.DeletePropagationBackground
+	return c.clientset.AppsV1().ReplicaSets(c.namespace).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+}
+
+// deletePod deletes a pod by name
+func (c *Client) deletePod(ctx context.Context, name string) error {
+	return c.clientset.CoreV1().Pods(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// poolWaitContainer is the init container that holds a pool pod until it is claimed
+const poolWaitContainer = "wait-for-claim"
+
+// poolPodSpec turns a sandbox pod spec into a pool pod's. A last init container runs the
+// sandbox image, so it is pulled ahead, and waits until the pod has a user label. The sandbox
+// container then starts with that label as USER_ID, since a container's environment is only
+// resolved when it starts.
+func poolPodSpec(spec corev1.PodSpec) corev1.PodSpec {
+	spec = *spec.DeepCopy()
+	sandbox := findContainer(spec.Containers, "sandbox")
+	if sandbox == nil {
+		return spec
+	}
+	for i := range sandbox.Env {
+		if sandbox.Env[i].Name == "USER_ID" {
+			sandbox.Env[i] = corev1.EnvVar{
+				Name: "USER_ID",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['user']"},
+				},
+			}
+		}
+	}
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: "pool-claim",
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{{
+					Path:     "user",
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['user']"},
+				}},
+			},
+		},
+	})
+	spec.InitContainers = append(spec.InitContainers, corev1.Container{
+		Name:            poolWaitContainer,
+		Image:           sandbox.Image,
+		ImagePullPolicy: sandbox.ImagePullPolicy,
+		Command:         []string{"sh", "-c", "until [ -s /etc/pool/user ]; do sleep 1; done"},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      "pool-claim",
+			MountPath: "/etc/pool",
+			ReadOnly:  true,
+		}},
+	})
+	return spec
+}
+
+// poolPodReady reports whether a pool pod has started everything up to its sandbox container
+// and is waiting to be claimed
+func poolPodReady(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == poolWaitContainer {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
+
+// podImage returns the image of a pod's sandbox container
+func podImage(pod *corev1.Pod) string {
+	if container := findContainer(pod.Spec.Containers, "sandbox"); container != nil {
+		return container.Image
+	}
+	return ""
+}
+
+// newRandomID returns a short random identifier for naming pool and helper pods
+func newRandomID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+)
+
+func TestPoolPodMatchesUserTemplate(t *testing.T) {
+	c := &Client{config: &config.Configuration{
+		SandboxProfiles: map[string]config.SandboxProfile{
+			config.DefaultSandboxProfile: {
+				CPURequest:    "1",
+				CPULimit:      "2",
+				MemoryRequest: "2Gi",
+				MemoryLimit:   "4Gi",
+				ShmSize:       "1Gi",
+				StorageSize:   "10Gi",
+			},
+		},
+		DefaultSandboxProfile: config.DefaultSandboxProfile,
+	}}
+	image := "iris_agent:latest"
+
+	// A pool pod is built like a sandbox under its pool name; once claimed, the user's
+	// template references the pool claim, which is now labelled for the user
+	pool, err := c.sandboxDeployment("pool-ab12cd34", SandboxOptions{}, "pool-ab12cd34-pvc", false, image)
+	if err != nil {
+		t.Fatalf("Failed to build the pool pod: %v", err)
+	}
+	user, err := c.sandboxDeployment("user1", SandboxOptions{}, "pool-ab12cd34-pvc", false, image)
+	if err != nil {
+		t.Fatalf("Failed to build the user's template: %v", err)
+	}
+
+	// The user's template sets USER_ID itself, and the claimed pod reads it from the user label
+	sandbox := findContainer(user.Spec.Template.Spec.Containers, "sandbox")
+	if sandbox == nil || len(sandbox.Env) == 0 || sandbox.Env[0].Name != "USER_ID" || sandbox.Env[0].Value != "user1" {
+		t.Fatal("Expected USER_ID to be the user's ID")
+	}
+	poolSpec := poolPodSpec(pool.Spec.Template.Spec)
+	poolEnv := findContainer(poolSpec.Containers, "sandbox").Env
+	if poolEnv[0].ValueFrom == nil || poolEnv[0].ValueFrom.FieldRef.FieldPath != "metadata.labels['user']" {
+		t.Error("Expected the pool pod's USER_ID to come from the user label")
+	}
+	last := poolSpec.InitContainers[len(poolSpec.InitContainers)-1]
+	if last.Name != poolWaitContainer || last.Image != image {
+		t.Errorf("Expected the pool pod to wait for a claim with the sandbox image, got %s running %s", last.Name, last.Image)
+	}
+
+	// Apart from waiting for its user, the pool pod is the one the user's template describes
+	poolSpec.InitContainers = poolSpec.InitContainers[:len(poolSpec.InitContainers)-1]
+	poolSpec.Volumes = poolSpec.Volumes[:len(poolSpec.Volumes)-1]
+	findContainer(poolSpec.Containers, "sandbox").Env = sandbox.Env
+	if !reflect.DeepEqual(poolSpec, user.Spec.Template.Spec) {
+		t.Errorf("Expected the pool pod's spec to match the user's template:\npool: %+v\nuser: %+v",
+			poolSpec, user.Spec.Template.Spec)
+	}
+	if !reflect.DeepEqual(pool.Spec.Template.Annotations, user.Spec.Template.Annotations) {
+		t.Errorf("Expected the same pod annotations, got %v and %v", pool.Spec.Template.Annotations, user.Spec.Template.Annotations)
+	}
+}
@@ -0,0 +1,355 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Sandbox priority tiers, from lowest to highest priority
+const (
+	TierFree     = "free"
+	TierInternal = "internal"
+	TierPaying   = "paying"
+	// DefaultTier is the tier of sandboxes created without one
+	DefaultTier = TierFree
+)
+
+// annotationPreemptedAt records when a sandbox was paused to make room for a higher tier
+const annotationPreemptedAt = "sandbox.tryiris.dev/preempted-at"
+
+// ReasonPreempted is the reason given for a sandbox paused to make room for a higher tier
+const ReasonPreempted = "Preempted"
+
+// sandboxTier is a priority tier and the PriorityClass its pods run with
+type sandboxTier struct {
+	name          string
+	priorityClass string
+	value         int32
+}
+
+// sandboxTiers lists the tiers from lowest to highest priority
+var sandboxTiers = []sandboxTier{
+	{name: TierFree, priorityClass: "sandbox-free", value: 1000},
+	{name: TierInternal, priorityClass: "sandbox-internal", value: 2000},
+	{name: TierPaying, priorityClass: "sandbox-paying", value: 3000},
+}
+
+// preemptionCandidate is a running sandbox that may be paused for a higher tier
+type preemptionCandidate struct {
+	deployment   *appsv1.Deployment
+	rank         int
+	lastActivity time.Time
+	pods         []*corev1.Pod
+}
+
+// validateTier rejects unknown tiers
+func validateTier(tier string) error {
+	if tier != "" && tierRank(tier) < 0 {
+		return fmt.Errorf("%w: unknown tier %q", ErrInvalidSandboxOptions, tier)
+	}
+	return nil
+}
+
+// resolveTier returns the requested tier, or the default tier
+func resolveTier(tier string) sandboxTier {
+	if rank := tierRank(tier); rank >= 0 {
+		return sandboxTiers[rank]
+	}
+	return sandboxTiers[tierRank(DefaultTier)]
+}
+
+// tierRank returns the position of a tier from lowest to highest priority, or -1
+func tierRank(tier string) int {
+	for i, t := range sandboxTiers {
+		if t.name == tier {
+			return i
+		}
+	}
+	return -1
+}
+
+// withTenantTier gives a create request of a tenant without a tier the tier configured for
+// the tenant. Tenant API keys cannot set a tier themselves.
+func (c *Client) withTenantTier(opts SandboxOptions) SandboxOptions {
+	if opts.Tier == "" && c.tenant != "" {
+		opts.Tier = c.config.SandboxTenantTiers[c.tenant]
+	}
+	return opts
+}
+
+// deploymentTier returns the tier of a sandbox from its pod's PriorityClass. Sandboxes
+// created before tiers existed have none and count as the default tier.
+func deploymentTier(deployment *appsv1.Deployment) string {
+	for _, t := range sandboxTiers {
+		if t.priorityClass == deployment.Spec.Template.Spec.PriorityClassName {
+			return t.name
+		}
+	}
+	return DefaultTier
+}
+
+// ensurePriorityClass creates the PriorityClass of a tier if it does not exist. The classes
+// never preempt pods themselves; lower tiers are paused by preemptForSandbox instead, which
+// keeps their data and URLs, and the scheduler only uses the priority to order pending pods.
+func (c *Client) ensurePriorityClass(ctx context.Context, tier string) error {
+	t := resolveTier(tier)
+	classes := c.clientset.SchedulingV1().PriorityClasses()
+	if _, err := classes.Get(ctx, t.priorityClass, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	never := corev1.PreemptNever
+	class := &schedulingv1.PriorityClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   t.priorityClass,
+			Labels: map[string]string{"app": "user-sandbox"},
+		},
+		Value:            t.value,
+		PreemptionPolicy: &never,
+		Description:      fmt.Sprintf("Sandboxes of the %s tier", t.name),
+	}
+	if _, err := classes.Create(ctx, class, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to create PriorityClass %s: %w", t.priorityClass, err)
+	}
+	log.Printf("PriorityClass %s created", t.priorityClass)
+	return nil
+}
+
+// preemptForSandbox pauses idle running sandboxes of lower tiers, those idle longest first, until
+// one node has room for a sandbox of the given tier requesting cpu and memory. Only the
+// sandboxes on that node are paused, and they are returned so that a create or resume that
+// fails afterwards can resume them. It returns none if pausing them would not make room.
+func (c *Client) preemptForSandbox(ctx context.Context, tier string, nodes []nodeCapacity, cpu, memory resource.Quantity) ([]*preemptionCandidate, error) {
+	rank := tierRank(resolveTier(tier).name)
+	if rank == 0 {
+		return nil, nil
+	}
+
+	candidates, err := c.preemptionCandidates(ctx, rank)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	victims := selectPreemptionVictims(nodes, candidates, cpu, memory)
+	if len(victims) == 0 {
+		return nil, nil
+	}
+	preempted, err := c.preemptSandboxes(ctx, victims, tier)
+	if err != nil {
+		// Without all of them there is no room, so the ones already paused go back
+		c.resumePreempted(preempted)
+		return nil, err
+	}
+	return preempted, nil
+}
+
+// selectPreemptionVictims takes the candidates' pods off their nodes, in order, until a
+// schedulable node has room for a pod requesting cpu and memory. It returns the candidates
+// on that node, or nil if no node fits even without all of them.
+func selectPreemptionVictims(nodes []nodeCapacity, candidates []preemptionCandidate, cpu, memory resource.Quantity) []*preemptionCandidate {
+	byNode := make(map[string]*nodeCapacity, len(nodes))
+	for i := range nodes {
+		if nodeSchedulable(nodes[i].node) {
+			node := nodes[i]
+			node.requested = node.requested.DeepCopy()
+			byNode[node.node.Name] = &node
+		}
+	}
+
+	victims := make(map[string][]*preemptionCandidate)
+	for i := range candidates {
+		candidate := &candidates[i]
+		for _, pod := range candidate.pods {
+			node, ok := byNode[pod.Spec.NodeName]
+			if !ok {
+				continue
+			}
+			for name, quantity := range podSpecRequests(&pod.Spec) {
+				total := node.requested[name]
+				total.Sub(quantity)
+				node.requested[name] = total
+			}
+			node.pods--
+
+			nodeVictims := victims[pod.Spec.NodeName]
+			if len(nodeVictims) == 0 || nodeVictims[len(nodeVictims)-1] != candidate {
+				victims[pod.Spec.NodeName] = append(nodeVictims, candidate)
+			}
+			if nodeFreeSlots(*node, cpu, memory) > 0 {
+				return victims[pod.Spec.NodeName]
+			}
+		}
+	}
+	return nil
+}
+
+// preemptionCandidates returns the running sandboxes with a tier below rank that have been idle
+// for at least the configured time, lowest tier first and then longest idle first, with their
+// pods. As for idle cleanup, a sandbox that looks idle is asked for its own activity first. Only sandboxes in the client's namespace are candidates, plus those of other namespaces
+// whose tier is configured for cross-tenant preemption.
+func (c *Client) preemptionCandidates(ctx context.Context, rank int) ([]preemptionCandidate, error) {
+	namespace := c.namespace
+	if len(c.config.SandboxCrossTenantPreemption) > 0 {
+		namespace = ""
+	}
+	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sandboxes: %w", err)
+	}
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sandbox pods: %w", err)
+	}
+
+	podsBySandbox := make(map[string][]*corev1.Pod)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp == nil && pod.Spec.NodeName != "" {
+			key := pod.Namespace + "/" + pod.Labels["user"]
+			podsBySandbox[key] = append(podsBySandbox[key], pod)
+		}
+	}
+
+	var candidates []preemptionCandidate
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		tier := deploymentTier(deployment)
+		candidateRank := tierRank(tier)
+		if deploymentReplicas(deployment) == 0 || candidateRank >= rank {
+			continue
+		}
+		if deployment.Namespace != c.namespace && !slices.Contains(c.config.SandboxCrossTenantPreemption, tier) {
+			continue
+		}
+		sandboxPods := podsBySandbox[deployment.Namespace+"/"+deployment.Labels["user"]]
+		if len(sandboxPods) == 0 {
+			continue
+		}
+		candidates = append(candidates, preemptionCandidate{
+			deployment:   deployment,
+			rank:         candidateRank,
+			lastActivity: sandboxLastActivity(deployment),
+			pods:         sandboxPods,
+		})
+	}
+	now := time.Now()
+	candidates = idleCandidates(candidates, now, c.config.SandboxPreemptIdle)
+	// Sandboxes in use whose clients never call keepalive report their activity themselves
+	for i := range candidates {
+		candidateClient := *c
+		candidateClient.namespace = candidates[i].deployment.Namespace
+		candidates[i].lastActivity = candidateClient.observedLastActivity(ctx, candidates[i].deployment.Labels["user"], candidates[i].lastActivity)
+	}
+	candidates = idleCandidates(candidates, now, c.config.SandboxPreemptIdle)
+	sortPreemptionCandidates(candidates)
+	return candidates, nil
+}
+
+// idleCandidates returns the candidates without activity for at least minIdle, so sandboxes
+// in use are never paused
+func idleCandidates(candidates []preemptionCandidate, now time.Time, minIdle time.Duration) []preemptionCandidate {
+	var idle []preemptionCandidate
+	for _, candidate := range candidates {
+		if now.Sub(candidate.lastActivity) >= minIdle {
+			idle = append(idle, candidate)
+		}
+	}
+	return idle
+}
+
+// sortPreemptionCandidates orders candidates lowest tier first and then longest idle first
+func sortPreemptionCandidates(candidates []preemptionCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		return candidates[i].lastActivity.Before(candidates[j].lastActivity)
+	})
+}
+
+// preemptSandboxes pauses sandboxes to make room for a sandbox of a higher tier and returns
+// those it paused, also when it fails part way. The queue is not triggered, since the freed
+// room is already taken.
+func (c *Client) preemptSandboxes(ctx context.Context, victims []*preemptionCandidate, tier string) ([]*preemptionCandidate, error) {
+	var preempted []*preemptionCandidate
+	for _, victim := range victims {
+		userID := victim.deployment.Labels["user"]
+		victimClient := *c
+		victimClient.namespace = victim.deployment.Namespace
+
+		if err := victimClient.scaleSandbox(ctx, userID, 0); err != nil {
+			return preempted, fmt.Errorf("failed to preempt sandbox %s: %w", userID, err)
+		}
+		preempted = append(preempted, victim)
+		annotations := map[string]string{annotationPreemptedAt: time.Now().UTC().Format(time.RFC3339)}
+		if err := victimClient.patchDeploymentAnnotations(ctx, userID, annotations); err != nil {
+			log.Printf("Error recording preemption of sandbox %s: %v", userID, err)
+		}
+		log.Printf("Paused %s sandbox %s in %s (idle since %s) to make room for a %s sandbox",
+			deploymentTier(victim.deployment), userID, victim.deployment.Namespace,
+			victim.lastActivity.UTC().Format(time.RFC3339), resolveTier(tier).name)
+	}
+	return preempted, nil
+}
+
+// resumePreemptedSandbox scales a sandbox paused by preemptSandboxes back up and forgets the
+// preemption, after the sandbox it made room for was not created or resumed after all
+func (c *Client) resumePreemptedSandbox(ctx context.Context, victim *preemptionCandidate) error {
+	userID := victim.deployment.Labels["user"]
+	victimClient := *c
+	victimClient.namespace = victim.deployment.Namespace
+
+	if err := victimClient.scaleSandbox(ctx, userID, 1); err != nil {
+		return err
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, annotationPreemptedAt))
+	if _, err := c.clientset.AppsV1().Deployments(victim.deployment.Namespace).Patch(ctx, victim.deployment.Name,
+		types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		log.Printf("Error clearing preemption of sandbox %s: %v", userID, err)
+	}
+	log.Printf("Resumed preempted sandbox %s in %s", userID, victim.deployment.Namespace)
+	return nil
+}
+
+// resumePreempted resumes sandboxes paused for a create or resume that then failed. It has its own
+// context, since the caller's may already be cancelled.
+func (c *Client) resumePreempted(victims []*preemptionCandidate) {
+	if len(victims) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	for _, victim := range victims {
+		if err := c.resumePreemptedSandbox(ctx, victim); err != nil {
+			log.Printf("Error resuming preempted sandbox %s: %v", victim.deployment.Labels["user"], err)
+		}
+	}
+}
+
+// sandboxPreempted reports whether a paused sandbox was paused to make room for a higher
+// tier. Resuming records activity, so a later pause for another reason is not reported.
+func sandboxPreempted(deployment *appsv1.Deployment) bool {
+	preemptedAt, err := time.Parse(time.RFC3339, deployment.Annotations[annotationPreemptedAt])
+	return err == nil && !preemptedAt.Before(sandboxLastActivity(deployment))
+}
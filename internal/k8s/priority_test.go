@@ -0,0 +1,207 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testSandboxPod(node, cpu, memory string) *corev1.Pod {
+	return &corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Name: "sandbox",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		},
+	}
+}
+
+func testCandidate(name string, pods ...*corev1.Pod) preemptionCandidate {
+	return preemptionCandidate{
+		deployment: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name + "-deployment"}},
+		pods:       pods,
+	}
+}
+
+func TestSelectPreemptionVictims(t *testing.T) {
+	full := func(name string) nodeCapacity {
+		node := testNode("4", "16Gi", "110", true)
+		node.Name = name
+		return nodeCapacity{
+			node:      node,
+			requested: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3500m"), corev1.ResourceMemory: resource.MustParse("6Gi")},
+			pods:      4,
+		}
+	}
+	nodes := []nodeCapacity{full("node-a"), full("node-b")}
+	cpu, memory := resource.MustParse("1"), resource.MustParse("2Gi")
+
+	testCases := []struct {
+		name       string
+		candidates []preemptionCandidate
+		expected   []string
+	}{
+		{"No candidates", nil, nil},
+		{"Too small to make room", []preemptionCandidate{
+			testCandidate("tiny", testSandboxPod("node-a", "250m", "512Mi")),
+		}, nil},
+		{"First candidate makes room", []preemptionCandidate{
+			testCandidate("idle", testSandboxPod("node-b", "1", "2Gi")),
+			testCandidate("active", testSandboxPod("node-a", "1", "2Gi")),
+		}, []string{"idle"}},
+		{"Only candidates on the freed node are paused", []preemptionCandidate{
+			testCandidate("small-a", testSandboxPod("node-a", "250m", "512Mi")),
+			testCandidate("small-b", testSandboxPod("node-b", "250m", "512Mi")),
+			testCandidate("medium-b", testSandboxPod("node-b", "500m", "1Gi")),
+		}, []string{"small-b", "medium-b"}},
+		{"Pods on unschedulable or unknown nodes do not count", []preemptionCandidate{
+			testCandidate("elsewhere", testSandboxPod("node-c", "4", "8Gi")),
+		}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			victims := selectPreemptionVictims(nodes, tc.candidates, cpu, memory)
+			if len(victims) != len(tc.expected) {
+				t.Fatalf("Expected %d victims, got %d", len(tc.expected), len(victims))
+			}
+			for i, victim := range victims {
+				if victim.deployment.Name != tc.expected[i]+"-deployment" {
+					t.Errorf("Expected victim %d to be %s, got %s", i, tc.expected[i], victim.deployment.Name)
+				}
+			}
+		})
+	}
+
+	if requested := nodes[0].requested[corev1.ResourceCPU]; requested.Cmp(resource.MustParse("3500m")) != 0 {
+		t.Errorf("Expected the node requests to be left unchanged, got %s", requested.String())
+	}
+}
+
+func TestSortPreemptionCandidates(t *testing.T) {
+	now := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	candidate := func(name string, rank int, idle time.Duration) preemptionCandidate {
+		c := testCandidate(name)
+		c.rank = rank
+		c.lastActivity = now.Add(-idle)
+		return c
+	}
+	candidates := []preemptionCandidate{
+		candidate("internal-idle", 1, time.Hour),
+		candidate("free-active", 0, time.Minute),
+		candidate("free-idle", 0, 30*time.Minute),
+	}
+
+	sortPreemptionCandidates(candidates)
+	expected := []string{"free-idle", "free-active", "internal-idle"}
+	for i, name := range expected {
+		if candidates[i].deployment.Name != name+"-deployment" {
+			t.Errorf("Expected %s at position %d, got %s", name, i, candidates[i].deployment.Name)
+		}
+	}
+}
+
+func TestIdleCandidates(t *testing.T) {
+	now := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	candidate := func(name string, idle time.Duration) preemptionCandidate {
+		c := testCandidate(name)
+		c.lastActivity = now.Add(-idle)
+		return c
+	}
+	candidates := []preemptionCandidate{
+		candidate("active", time.Minute),
+		candidate("just-idle", 15*time.Minute),
+		candidate("idle", time.Hour),
+	}
+
+	testCases := []struct {
+		name     string
+		minIdle  time.Duration
+		expected []string
+	}{
+		{"Sandboxes in use are skipped", 15 * time.Minute, []string{"just-idle", "idle"}},
+		{"Longer idle time", 30 * time.Minute, []string{"idle"}},
+		{"No idle time required", 0, []string{"active", "just-idle", "idle"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			idle := idleCandidates(candidates, now, tc.minIdle)
+			if len(idle) != len(tc.expected) {
+				t.Fatalf("Expected %d candidates, got %d", len(tc.expected), len(idle))
+			}
+			for i, name := range tc.expected {
+				if idle[i].deployment.Name != name+"-deployment" {
+					t.Errorf("Expected %s at position %d, got %s", name, i, idle[i].deployment.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDeploymentTier(t *testing.T) {
+	testCases := []struct {
+		priorityClass string
+		expected      string
+	}{
+		{"sandbox-paying", TierPaying},
+		{"sandbox-internal", TierInternal},
+		{"sandbox-free", TierFree},
+		{"", DefaultTier},
+		{"system-cluster-critical", DefaultTier},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.priorityClass, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.PriorityClassName = tc.priorityClass
+			if got := deploymentTier(deployment); got != tc.expected {
+				t.Errorf("Expected tier %s, got %s", tc.expected, got)
+			}
+		})
+	}
+
+	if err := validateTier("gold"); err == nil {
+		t.Error("Expected an unknown tier to be rejected")
+	}
+}
+
+func TestSandboxPreempted(t *testing.T) {
+	created := metav1.NewTime(time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC))
+	deployment := func(annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created, Annotations: annotations}}
+	}
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{"Never preempted", nil, false},
+		{"Preempted", map[string]string{
+			annotationLastActivity: "2023-04-20T12:10:00Z",
+			annotationPreemptedAt:  "2023-04-20T12:40:00Z",
+		}, true},
+		{"Resumed after preemption", map[string]string{
+			annotationPreemptedAt:  "2023-04-20T12:40:00Z",
+			annotationLastActivity: "2023-04-20T12:45:00Z",
+		}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sandboxPreempted(deployment(tc.annotations)); got != tc.expected {
+				t.Errorf("Expected preempted %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
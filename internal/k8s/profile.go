@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// annotationProfile records the profile a sandbox was created with
+const annotationProfile = "sandbox.tryiris.dev/profile"
+
+// sandboxSize is a profile with its quantities parsed
+type sandboxSize struct {
+	cpuRequest    resource.Quantity
+	cpuLimit      resource.Quantity
+	memoryRequest resource.Quantity
+	memoryLimit   resource.Quantity
+	shmSize       resource.Quantity
+	storageSize   resource.Quantity
+	storageClass  string
+}
+
+// resolveProfile returns the name and size of the requested profile, or of the default profile
+func (c *Client) resolveProfile(name string) (string, *sandboxSize, error) {
+	if name == "" {
+		name = c.config.DefaultSandboxProfile
+	}
+
+	profile, ok := c.config.SandboxProfiles[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown profile %q", ErrInvalidSandboxOptions, name)
+	}
+
+	size, err := parseSandboxSize(profile)
+	if err != nil {
+		return "", nil, fmt.Errorf("profile %q is misconfigured: %w", name, err)
+	}
+	return name, size, nil
+}
+
+// parseSandboxSize parses the quantities of a profile
+func parseSandboxSize(profile config.SandboxProfile) (*sandboxSize, error) {
+	size := &sandboxSize{storageClass: profile.StorageClass}
+	fields := []struct {
+		name  string
+		value string
+		into  *resource.Quantity
+	}{
+		{"cpuRequest", profile.CPURequest, &size.cpuRequest},
+		{"cpuLimit", profile.CPULimit, &size.cpuLimit},
+		{"memoryRequest", profile.MemoryRequest, &size.memoryRequest},
+		{"memoryLimit", profile.MemoryLimit, &size.memoryLimit},
+		{"shmSize", profile.ShmSize, &size.shmSize},
+		{"storageSize", profile.StorageSize, &size.storageSize},
+	}
+	for _, field := range fields {
+		quantity, err := resource.ParseQuantity(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", field.name, field.value, err)
+		}
+		*field.into = quantity
+	}
+	return size, nil
+}
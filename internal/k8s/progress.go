@@ -0,0 +1,22 @@
+package k8s
+
+import "context"
+
+// ProgressFunc is called each time a step of a long-running sandbox operation completes.
+// Create reports the steps pvc, deployment, service, routes and ready;
+// delete reports routes, service and deployment.
+type ProgressFunc func(step string)
+
+type progressKey struct{}
+
+// WithProgress returns a context that reports completed steps to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress notifies the context's ProgressFunc, if any, that a step completed
+func reportProgress(ctx context.Context, step string) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(step)
+	}
+}
@@ -0,0 +1,302 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Data purge settings
+const (
+	// ActionDeleted marks a resource removed by a purge
+	ActionDeleted = "deleted"
+	// annotationLastUsed on a user's PVC records when the user last had a sandbox
+	annotationLastUsed = "sandbox.tryiris.dev/last-used"
+	// purgeTimeout bounds how long a purge waits for the deleted data to be gone
+	purgeTimeout = 2 * time.Minute
+	// retentionSweepInterval is how often PVCs of users without a sandbox are checked
+	retentionSweepInterval = time.Hour
+)
+
+// ErrSandboxExists is returned when purging the data of a user who still has a sandbox
+var ErrSandboxExists = errors.New("sandbox still exists")
+
+// PurgeResult lists the user data removed by a purge
+type PurgeResult struct {
+	UserID  string
+	Deleted []ResourceAction
+}
+
+// PurgeSandboxData permanently deletes a user's PVCs and snapshots and waits until they are gone.
+// The user's sandboxes, including named sandboxes sharing the user's volume, must be deleted
+// first, so data is never removed from under a running pod.
+func (c *ClientWithTraefik) PurgeSandboxData(ctx context.Context, userID string) (*PurgeResult, error) {
+	claimNames, err := c.userClaimNames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := c.clientset.AppsV1().Deployments(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sandboxes := sandboxesUsingData(deployments.Items, userID, claimNames); len(sandboxes) > 0 {
+		return nil, fmt.Errorf("%w for user ID %s: delete %s before purging its data",
+			ErrSandboxExists, userID, strings.Join(sandboxes, ", "))
+	}
+
+	result := &PurgeResult{UserID: userID}
+
+	claims := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace)
+	for _, name := range claimNames {
+		if err := claims.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to delete PVC %s: %w", name, err)
+		}
+		result.Deleted = append(result.Deleted, ResourceAction{Resource: "pvc", Name: name, Action: ActionDeleted})
+	}
+
+	// The user label of a snapshot is the sandbox ID, so the user's snapshots are those of
+	// every sandbox they own
+	snapshots := c.dynamicClient.Resource(VolumeSnapshotGVR()).Namespace(c.namespace)
+	list, err := snapshots.List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		// A cluster without the snapshot CRDs has no snapshots to purge
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	var snapshotNames []string
+	if list != nil {
+		for _, item := range list.Items {
+			if !snapshotOwnedBy(item.GetLabels(), userID) {
+				continue
+			}
+			if err := snapshots.Delete(ctx, item.GetName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to delete snapshot %s: %w", item.GetName(), err)
+			}
+			snapshotNames = append(snapshotNames, item.GetName())
+			result.Deleted = append(result.Deleted, ResourceAction{Resource: "snapshot", Name: item.GetName(), Action: ActionDeleted})
+		}
+	}
+
+	// Confirm the removal rather than trusting the accepted delete requests
+	waitCtx, cancel := context.WithTimeout(ctx, purgeTimeout)
+	defer cancel()
+	for _, name := range claimNames {
+		if err := c.waitForPVCGone(waitCtx, name); err != nil {
+			return nil, fmt.Errorf("PVC %s was not removed: %w", name, err)
+		}
+	}
+	for _, name := range snapshotNames {
+		err := pollUntil(waitCtx, restorePollInterval, func() (bool, error) {
+			_, err := snapshots.Get(waitCtx, name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s was not removed: %w", name, err)
+		}
+	}
+	reportProgress(ctx, "data")
+
+	log.Printf("Data purged for user %s: %d PVCs, %d snapshots", userID, len(claimNames), len(snapshotNames))
+	return result, nil
+}
+
+// userClaimNames returns the names of the PVCs holding a user's data: {userId}-pvc,
+// which older sandboxes created without labels, any PVC labelled for the user's default
+// sandbox, and the PVCs of the user's named sandboxes, whose owner label is the user
+func (c *Client) userClaimNames(ctx context.Context, userID string) ([]string, error) {
+	claims := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace)
+
+	var names []string
+	pvcName := fmt.Sprintf("%s-pvc", userID)
+	if _, err := claims.Get(ctx, pvcName, metav1.GetOptions{}); err == nil {
+		names = append(names, pvcName)
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	for _, selector := range []string{
+		fmt.Sprintf("app=user-sandbox,user=%s", userID),
+		fmt.Sprintf("app=user-sandbox,owner=%s", userID),
+	} {
+		labelled, err := claims.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for _, claim := range labelled.Items {
+			if !slices.Contains(names, claim.Name) {
+				names = append(names, claim.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// snapshotOwnedBy reports whether a snapshot with the given labels was taken of the sandbox
+// with the given ID or, for a user ID, of one of the user's sandboxes. Snapshots taken before
+// they carried an owner label only name the sandbox, which for a named sandbox starts with
+// the user ID.
+func snapshotOwnedBy(labels map[string]string, userID string) bool {
+	sandboxID := labels["user"]
+	if sandboxID == userID {
+		return true
+	}
+	if owner := labels["owner"]; owner != "" {
+		return owner == userID
+	}
+	return strings.HasPrefix(sandboxID, userID+sandboxIDSeparator)
+}
+
+// sandboxesUsingData returns the IDs of the sandboxes that use a user's data: the user's own
+// sandbox, the user's named sandboxes, and any sandbox mounting one of the user's PVCs
+func sandboxesUsingData(deployments []appsv1.Deployment, userID string, claimNames []string) []string {
+	var sandboxes []string
+	for i := range deployments {
+		deployment := &deployments[i]
+		owner, _ := deploymentOwnership(deployment)
+		if deployment.Name == fmt.Sprintf("%s-deployment", userID) || owner == userID ||
+			slices.Contains(claimNames, deploymentDataClaim(deployment)) {
+			sandboxes = append(sandboxes, strings.TrimSuffix(deployment.Name, "-deployment"))
+		}
+	}
+	return sandboxes
+}
+
+// markDataLastUsed records on the user's PVCs that the user had a sandbox until now
+func (c *Client) markDataLastUsed(ctx context.Context, userID string, now time.Time) error {
+	claimNames, err := c.userClaimNames(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, annotationLastUsed, now.UTC().Format(time.RFC3339)))
+	for _, name := range claimNames {
+		if _, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Patch(ctx, name,
+			types.MergePatchType, patch, metav1.PatchOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartDataRetentionSweeper starts a background goroutine that purges the data of users
+// who have had no sandbox for longer than the configured retention. It does nothing when
+// retention is disabled.
+func (c *ClientWithTraefik) StartDataRetentionSweeper(ctx context.Context) {
+	if c.config.SandboxDataRetention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(retentionSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.sweepExpiredData(ctx); err != nil {
+				log.Printf("Error sweeping expired sandbox data: %v", err)
+			}
+			c.forEachTenant(ctx, "sweeping expired sandbox data", func(tenant *ClientWithTraefik) error {
+				return tenant.sweepExpiredData(ctx)
+			})
+
+			select {
+			case <-ctx.Done():
+				log.Println("Data retention sweeper stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Data retention sweeper started - data is purged %d days after a user's last sandbox",
+		int(c.config.SandboxDataRetention.Hours()/24))
+}
+
+// sweepExpiredData purges the data of users whose PVCs have all outlived the retention period.
+// Claims are grouped by their owner, so a user's named sandboxes keep the user's data in use.
+func (c *ClientWithTraefik) sweepExpiredData(ctx context.Context) error {
+	claims, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	deployments, err := c.clientset.AppsV1().Deployments(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil {
+		return err
+	}
+
+	var users []string
+	claimNames := make(map[string][]string)
+	lastUsed := make(map[string]time.Time)
+	for _, claim := range claims.Items {
+		userID := claimQuotaOwner(&claim)
+		if userID == "" {
+			continue
+		}
+		if _, ok := claimNames[userID]; !ok {
+			users = append(users, userID)
+		}
+		claimNames[userID] = append(claimNames[userID], claim.Name)
+		if used := dataLastUsed(&claim); used.After(lastUsed[userID]) {
+			lastUsed[userID] = used
+		}
+	}
+
+	now := time.Now()
+	for _, userID := range users {
+		if len(sandboxesUsingData(deployments.Items, userID, claimNames[userID])) > 0 {
+			// A sandbox of the user, or one sharing the user's volume, has the data in use
+			if err := c.markDataLastUsed(ctx, userID, now); err != nil {
+				log.Printf("Error recording data use for user %s: %v", userID, err)
+			}
+			continue
+		}
+
+		if now.Sub(lastUsed[userID]) < c.config.SandboxDataRetention {
+			continue
+		}
+		log.Printf("Purging data for user %s after %d days without a sandbox", userID, int(c.config.SandboxDataRetention.Hours()/24))
+		if _, err := c.PurgeSandboxData(ctx, userID); err != nil {
+			log.Printf("Error purging data for user %s: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// claimUserID returns the user a PVC belongs to, or "" for claims that are not user data
+func claimUserID(claim *corev1.PersistentVolumeClaim) string {
+	if claim.Labels["app"] == "user-sandbox" {
+		return claim.Labels["user"]
+	}
+	if claim.Labels["app"] == "" && strings.HasSuffix(claim.Name, "-pvc") {
+		return strings.TrimSuffix(claim.Name, "-pvc")
+	}
+	return ""
+}
+
+// dataLastUsed returns when a PVC's user last had a sandbox, falling back to the PVC's creation
+func dataLastUsed(claim *corev1.PersistentVolumeClaim) time.Time {
+	lastUsed := claim.CreationTimestamp.Time
+	if value, ok := claim.Annotations[annotationLastUsed]; ok {
+		if t, err := time.Parse(time.RFC3339, value); err == nil && t.After(lastUsed) {
+			lastUsed = t
+		}
+	}
+	return lastUsed
+}
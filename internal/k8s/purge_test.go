@@ -0,0 +1,86 @@
+package k8s
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSandboxesUsingData(t *testing.T) {
+	deployment := func(sandboxID string, labels map[string]string, claimName string) appsv1.Deployment {
+		d := appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: sandboxID + "-deployment", Labels: labels}}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "user-data",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			},
+		}}
+		return d
+	}
+
+	testCases := []struct {
+		name        string
+		deployments []appsv1.Deployment
+		expected    []string
+	}{
+		{"No sandboxes", nil, nil},
+		{"Default sandbox", []appsv1.Deployment{
+			deployment("user1", map[string]string{"user": "user1"}, "user1-pvc"),
+		}, []string{"user1"}},
+		{"Named sandbox sharing the volume", []appsv1.Deployment{
+			deployment("user1--work", map[string]string{"user": "user1--work", "owner": "user1", "sandbox": "work"}, "user1-pvc"),
+		}, []string{"user1--work"}},
+		{"Named sandbox with its own volume", []appsv1.Deployment{
+			deployment("user1--work", map[string]string{"user": "user1--work", "owner": "user1", "sandbox": "work"}, "user1--work-pvc"),
+		}, []string{"user1--work"}},
+		{"Sandbox started from the pool claim", []appsv1.Deployment{
+			deployment("other", map[string]string{"user": "other"}, "pool-abc12-pvc"),
+		}, []string{"other"}},
+		{"Other users", []appsv1.Deployment{
+			deployment("user2", map[string]string{"user": "user2"}, "user2-pvc"),
+			deployment("user10", map[string]string{"user": "user10"}, "user10-pvc"),
+		}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := sandboxesUsingData(tc.deployments, "user1", []string{"user1-pvc", "pool-abc12-pvc"})
+			if len(got) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Errorf("Expected %v, got %v", tc.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotOwnedBy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		labels   map[string]string
+		userID   string
+		expected bool
+	}{
+		{"Default sandbox", map[string]string{"user": "user1", "owner": "user1"}, "user1", true},
+		{"Named sandbox of the user", map[string]string{"user": "user1--work", "owner": "user1"}, "user1", true},
+		{"Named sandbox by its ID", map[string]string{"user": "user1--work", "owner": "user1"}, "user1--work", true},
+		{"Named sandbox without an owner label", map[string]string{"user": "user1--work"}, "user1", true},
+		{"Default sandbox without an owner label", map[string]string{"user": "user1"}, "user1", true},
+		{"Other user with a longer ID", map[string]string{"user": "user10"}, "user1", false},
+		{"Other user's named sandbox", map[string]string{"user": "user2--work", "owner": "user2"}, "user1", false},
+		{"Other sandbox of the same user", map[string]string{"user": "user1", "owner": "user1"}, "user1--work", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := snapshotOwnedBy(tc.labels, tc.userID); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,737 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Sandbox queue settings
+const (
+	// queueSecretName is the Secret holding the queue. The secret values of queued requests
+	// are kept apart, in a Secret per sandbox in its own namespace, which the queue names.
+	queueSecretName = "sandbox-queue"
+	// queueDataKey is the key of the queue's entries in the Secret
+	queueDataKey = "queue.json"
+	// queueDroppedKey is the key of the recently dropped requests in the Secret
+	queueDroppedKey = "dropped.json"
+	// queueDropRetention is how long the reason a request was dropped is kept
+	queueDropRetention = 24 * time.Hour
+	// queueDispatchInterval is how often queued sandboxes are retried
+	queueDispatchInterval = 15 * time.Second
+	// queueEntryTimeout is how long a request waits in the queue before it is dropped
+	queueEntryTimeout = time.Hour
+	// queueCleanupDelay is how long an expired sandbox can run before auto cleanup removes it
+	queueCleanupDelay = time.Minute
+)
+
+// ErrQueueUnavailable is returned when a sandbox cannot be queued because the queue is disabled or full
+var ErrQueueUnavailable = errors.New("sandbox queue is disabled or full")
+
+// ErrSandboxNotQueued is returned when looking up a sandbox that is not waiting in the queue
+var ErrSandboxNotQueued = errors.New("sandbox is not queued")
+
+// What a queued request waits for
+const (
+	// queueWaitCluster is a request waiting for room on the cluster's nodes
+	queueWaitCluster = "cluster"
+	// queueWaitTenant is a request waiting for room in its tenant's quota
+	queueWaitTenant = "tenant"
+)
+
+// Reasons a queued request was dropped
+const (
+	// ReasonQueueTimeout is given for a request that waited longer than the queue allows
+	ReasonQueueTimeout = "QueueTimeout"
+	// ReasonQueueCreateFailed is given for a request whose create failed for a reason other than capacity
+	ReasonQueueCreateFailed = "CreateFailed"
+)
+
+// QueuePosition describes a create request waiting in the queue
+type QueuePosition struct {
+	// Position in the queue, starting at 1
+	Position int `json:"position" example:"3"`
+	// Number of requests in the queue
+	Length int `json:"length" example:"5"`
+	// When the request was queued
+	QueuedAt string `json:"queuedAt" example:"2023-04-20T12:00:00Z"`
+	// Estimated start, based on when the running sandboxes expire; omitted when unknown
+	EstimatedStartAt string `json:"estimatedStartAt,omitempty" example:"2023-04-20T12:20:00Z"`
+}
+
+// QueueDrop describes a create request that was dropped from the queue
+type QueueDrop struct {
+	// Why the request was dropped: QueueTimeout or CreateFailed
+	Reason string `json:"reason" example:"QueueTimeout"`
+	// Details, e.g. the error of the failed create
+	Message string `json:"message" example:"no capacity after waiting 1h0m0s in the queue"`
+	// When the request was queued
+	QueuedAt string `json:"queuedAt" example:"2023-04-20T12:00:00Z"`
+	// When the request was dropped
+	DroppedAt string `json:"droppedAt" example:"2023-04-20T13:00:00Z"`
+}
+
+// queueEntry is a create request waiting for capacity
+type queueEntry struct {
+	Tenant    string         `json:"tenant,omitempty"`
+	SandboxID string         `json:"sandboxId"`
+	Options   SandboxOptions `json:"options"`
+	QueuedAt  time.Time      `json:"queuedAt"`
+	// WaitingFor is queueWaitCluster or queueWaitTenant; requests queued before it was
+	// recorded wait for the cluster
+	WaitingFor string `json:"waitingFor,omitempty"`
+	// SecretsRef names the Secret in the sandbox's namespace holding the request's secret
+	// values, which are not stored in the queue itself
+	SecretsRef string `json:"secretsRef,omitempty"`
+}
+
+// waitsForCluster reports whether the request waits for room on the cluster's nodes, which
+// every tenant's new creates have to queue behind
+func (e queueEntry) waitsForCluster() bool {
+	return e.WaitingFor != queueWaitTenant
+}
+
+// queuedAheadError is returned while queued requests wait for the room a new create needs
+type queuedAheadError struct {
+	ahead      int
+	waitingFor string
+}
+
+func (e *queuedAheadError) Error() string {
+	return fmt.Sprintf("%s: %d queued requests are waiting ahead of this one", ErrInsufficientCapacity, e.ahead)
+}
+
+// Unwrap lets errors.Is match ErrInsufficientCapacity
+func (e *queuedAheadError) Unwrap() error {
+	return ErrInsufficientCapacity
+}
+
+// droppedEntry records why a request was dropped from the queue, until it is queued again,
+// created or deleted, or the record expires
+type droppedEntry struct {
+	Tenant    string    `json:"tenant,omitempty"`
+	SandboxID string    `json:"sandboxId"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	QueuedAt  time.Time `json:"queuedAt"`
+	DroppedAt time.Time `json:"droppedAt"`
+}
+
+// queueState is the content of the queue's Secret
+type queueState struct {
+	entries []queueEntry
+	dropped []droppedEntry
+}
+
+type queueDispatchKey struct{}
+
+// sandboxQueue is the queue shared by the base client and its tenant clients
+type sandboxQueue struct {
+	namespace string
+	maxSize   int
+	mu        sync.Mutex
+	trigger   chan struct{}
+}
+
+// WaitsForCapacity reports whether a create failed only because the cluster or the tenant
+// is full, so that it can succeed later without changes
+func WaitsForCapacity(err error) bool {
+	if errors.Is(err, ErrInsufficientCapacity) {
+		return true
+	}
+	var quotaErr *QuotaExceededError
+	return errors.As(err, &quotaErr) && quotaErr.Scope == QuotaScopeTenant
+}
+
+// queueWaitReason returns what a create that failed with err waits for in the queue
+func queueWaitReason(err error) string {
+	var quotaErr *QuotaExceededError
+	if errors.As(err, &quotaErr) && quotaErr.Scope == QuotaScopeTenant {
+		return queueWaitTenant
+	}
+	var aheadErr *queuedAheadError
+	if errors.As(err, &aheadErr) {
+		return aheadErr.waitingFor
+	}
+	return queueWaitCluster
+}
+
+// StartSandboxQueue starts the dispatcher that creates queued sandboxes, highest tier and
+// then oldest first, once there is capacity for them. It does nothing when SANDBOX_QUEUE_SIZE
+// is 0.
+func (c *ClientWithTraefik) StartSandboxQueue(ctx context.Context) {
+	if c.config.SandboxQueueSize <= 0 {
+		return
+	}
+
+	c.queue = &sandboxQueue{
+		namespace: c.namespace,
+		maxSize:   c.config.SandboxQueueSize,
+		trigger:   make(chan struct{}, 1),
+	}
+
+	go func() {
+		ticker := time.NewTicker(queueDispatchInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.dispatchQueue(ctx); err != nil {
+				log.Printf("Error dispatching queued sandboxes: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("Sandbox queue dispatcher stopped")
+				return
+			case <-ticker.C:
+			case <-c.queue.trigger:
+			}
+		}
+	}()
+	log.Printf("Sandbox queue dispatcher started with room for %d requests", c.queue.maxSize)
+}
+
+// QueueSandbox adds a create request that failed with cause to the queue. A sandbox that is
+// already queued keeps its place and takes the new options. It fails with ErrQueueUnavailable
+// when the queue is disabled or full.
+func (c *Client) QueueSandbox(ctx context.Context, sandboxID string, opts SandboxOptions, cause error) (*QueuePosition, error) {
+	if c.queue == nil {
+		return nil, ErrQueueUnavailable
+	}
+	opts = c.withTenantTier(opts)
+	waitingFor := queueWaitReason(cause)
+
+	// The queue is shared by every tenant, so secret values stay in the sandbox's namespace
+	var secretsRef string
+	if len(opts.Secrets) > 0 {
+		secretsRef = queuedSecretsName(sandboxID)
+		if err := c.storeQueuedSecrets(ctx, secretsRef, opts.Secrets); err != nil {
+			return nil, fmt.Errorf("failed to store secrets of queued sandbox %s: %w", sandboxID, err)
+		}
+		opts.Secrets = nil
+	}
+
+	var entries []queueEntry
+	err := c.updateQueue(ctx, func(state *queueState) (bool, error) {
+		if i := queueIndex(state.entries, c.tenant, sandboxID); i >= 0 {
+			state.entries[i].Options = opts
+			state.entries[i].WaitingFor = waitingFor
+			state.entries[i].SecretsRef = secretsRef
+		} else if len(state.entries) >= c.queue.maxSize {
+			return false, ErrQueueUnavailable
+		} else {
+			state.entries = append(state.entries, queueEntry{
+				Tenant:     c.tenant,
+				SandboxID:  sandboxID,
+				Options:    opts,
+				QueuedAt:   time.Now().UTC(),
+				WaitingFor: waitingFor,
+				SecretsRef: secretsRef,
+			})
+		}
+		state.dropped, _ = removeDropped(state.dropped, c.tenant, sandboxID)
+		entries = state.entries
+		return true, nil
+	})
+	if errors.Is(err, ErrQueueUnavailable) || (err == nil && secretsRef == "") {
+		// Secrets the queue does not reference, new or left by an earlier request, are removed
+		c.deleteQueuedSecrets(ctx, sandboxID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Sandbox %s queued until there is capacity for it", sandboxID)
+	c.triggerQueue()
+	return c.queuePosition(ctx, entries, sandboxID)
+}
+
+// GetQueuePosition returns the place of a sandbox in the queue, or ErrSandboxNotQueued
+func (c *Client) GetQueuePosition(ctx context.Context, sandboxID string) (*QueuePosition, error) {
+	if c.queue == nil {
+		return nil, ErrSandboxNotQueued
+	}
+
+	state, _, err := c.loadQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.queuePosition(ctx, state.entries, sandboxID)
+}
+
+// GetQueueDrop returns why a sandbox was recently dropped from the queue, or ErrSandboxNotQueued
+func (c *Client) GetQueueDrop(ctx context.Context, sandboxID string) (*QueueDrop, error) {
+	if c.queue == nil {
+		return nil, ErrSandboxNotQueued
+	}
+
+	state, _, err := c.loadQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, drop := range state.dropped {
+		if drop.Tenant == c.tenant && drop.SandboxID == sandboxID {
+			return &QueueDrop{
+				Reason:    drop.Reason,
+				Message:   drop.Message,
+				QueuedAt:  drop.QueuedAt.Format(time.RFC3339),
+				DroppedAt: drop.DroppedAt.Format(time.RFC3339),
+			}, nil
+		}
+	}
+	return nil, ErrSandboxNotQueued
+}
+
+// removeQueuedSandbox takes a sandbox out of the queue, if it is queued, and forgets why it
+// was dropped, if it was
+func (c *Client) removeQueuedSandbox(ctx context.Context, sandboxID string) error {
+	if c.queue == nil {
+		return nil
+	}
+
+	var secretsRef string
+	err := c.updateQueue(ctx, func(state *queueState) (bool, error) {
+		var removed bool
+		state.dropped, removed = removeDropped(state.dropped, c.tenant, sandboxID)
+		if i := queueIndex(state.entries, c.tenant, sandboxID); i >= 0 {
+			secretsRef = state.entries[i].SecretsRef
+			state.entries = append(state.entries[:i], state.entries[i+1:]...)
+			removed = true
+		}
+		return removed, nil
+	})
+	if err == nil && secretsRef != "" {
+		c.deleteQueuedSecrets(ctx, sandboxID)
+	}
+	return err
+}
+
+// queuedSecretsName returns the name of the Secret holding a queued sandbox's secret values
+func queuedSecretsName(sandboxID string) string {
+	return fmt.Sprintf("%s-queued-secrets", sandboxID)
+}
+
+// storeQueuedSecrets creates or replaces the Secret holding a queued sandbox's secret values
+func (c *Client) storeQueuedSecrets(ctx context.Context, name string, values map[string]string) error {
+	if err := c.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	data := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data[key] = []byte(value)
+	}
+	secrets := c.clientset.CoreV1().Secrets(c.namespace)
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"app": queueSecretName},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Data = data
+	_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// loadQueuedSecrets returns the secret values of a queued request, read from the Secret it
+// references, or the values stored inline by requests queued before they were kept apart
+func (c *Client) loadQueuedSecrets(ctx context.Context, entry queueEntry) (map[string]string, error) {
+	if entry.SecretsRef == "" {
+		return entry.Options.Secrets, nil
+	}
+
+	secret, err := c.clientset.CoreV1().Secrets(c.namespace).Get(ctx, entry.SecretsRef, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets of queued sandbox %s: %w", entry.SandboxID, err)
+	}
+	values := make(map[string]string, len(secret.Data))
+	for key, value := range secret.Data {
+		values[key] = string(value)
+	}
+	return values, nil
+}
+
+// deleteQueuedSecrets deletes the Secret holding a queued sandbox's secret values, if there is one
+func (c *Client) deleteQueuedSecrets(ctx context.Context, sandboxID string) {
+	if err := c.deleteSecret(ctx, queuedSecretsName(sandboxID)); err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Error deleting secrets of queued sandbox %s: %v", sandboxID, err)
+	}
+}
+
+// withQueueDispatch marks a create made by the queue dispatcher, which may take room that
+// new creates have to queue for
+func withQueueDispatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queueDispatchKey{}, true)
+}
+
+// checkQueueAhead returns ErrInsufficientCapacity while requests of the same or a higher tier
+// wait in the queue for room on the cluster, or for room in the caller's tenant, so that a new
+// create queues behind them instead of taking the room the dispatcher is waiting for. Requests
+// of other tenants waiting for their own quota do not hold it back, and neither are the
+// dispatcher's own creates.
+func (c *Client) checkQueueAhead(ctx context.Context, sandboxID, tier string) error {
+	if c.queue == nil || ctx.Value(queueDispatchKey{}) != nil {
+		return nil
+	}
+
+	state, _, err := c.loadQueue(ctx)
+	if err != nil {
+		log.Printf("Skipping queue check for sandbox %s, the queue could not be read: %v", sandboxID, err)
+		return nil
+	}
+	if ahead, waitingFor := queuedAhead(state.entries, c.tenant, sandboxID, tier); ahead > 0 {
+		return &queuedAheadError{ahead: ahead, waitingFor: waitingFor}
+	}
+	return nil
+}
+
+// triggerQueue asks the dispatcher to retry the queue, e.g. after capacity was freed
+func (c *Client) triggerQueue() {
+	if c.queue == nil {
+		return
+	}
+	select {
+	case c.queue.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchQueue creates queued sandboxes in dispatch order. It stops at the first one the
+// cluster has no room for, so that later requests cannot overtake it, and skips those waiting
+// for their tenant's quota. Requests that fail for other reasons or wait too long are dropped,
+// and the reason is kept for the status endpoint.
+func (c *ClientWithTraefik) dispatchQueue(ctx context.Context) error {
+	state, _, err := c.loadQueue(ctx)
+	if err != nil || len(state.entries) == 0 {
+		return err
+	}
+	entries := dispatchOrder(state.entries)
+
+	now := time.Now()
+	var removed, rewaited []queueEntry
+	var dropped []droppedEntry
+	// waitFor records what a request still waits for, so that new creates of other tenants
+	// only queue behind it while it waits for the cluster
+	waitFor := func(entry queueEntry, err error) {
+		if reason := queueWaitReason(err); reason != entry.WaitingFor {
+			entry.WaitingFor = reason
+			rewaited = append(rewaited, entry)
+		}
+	}
+	drop := func(entry queueEntry, reason, message string) {
+		log.Printf("Dropping queued sandbox %s: %s", entry.SandboxID, message)
+		removed = append(removed, entry)
+		dropped = append(dropped, droppedEntry{
+			Tenant:    entry.Tenant,
+			SandboxID: entry.SandboxID,
+			Reason:    reason,
+			Message:   message,
+			QueuedAt:  entry.QueuedAt,
+			DroppedAt: now.UTC(),
+		})
+	}
+	for _, entry := range entries {
+		if now.Sub(entry.QueuedAt) > queueEntryTimeout {
+			drop(entry, ReasonQueueTimeout, fmt.Sprintf("no capacity after waiting %v in the queue", queueEntryTimeout))
+			continue
+		}
+
+		client := c.queueClient(entry.Tenant)
+		opts := entry.Options
+		secrets, err := client.loadQueuedSecrets(ctx, entry)
+		if err != nil {
+			drop(entry, ReasonQueueCreateFailed, err.Error())
+			continue
+		}
+		opts.Secrets = secrets
+
+		// A successful create takes the sandbox out of the queue
+		_, err = client.CreateSandbox(withQueueDispatch(ctx), entry.SandboxID, opts)
+		if err == nil {
+			log.Printf("Queued sandbox %s started after waiting %v", entry.SandboxID, now.Sub(entry.QueuedAt).Round(time.Second))
+			continue
+		}
+		if errors.Is(err, ErrInsufficientCapacity) {
+			waitFor(entry, err)
+			break
+		}
+		if WaitsForCapacity(err) {
+			waitFor(entry, err)
+			continue
+		}
+		drop(entry, ReasonQueueCreateFailed, err.Error())
+	}
+
+	if len(dropped) == 0 && len(rewaited) == 0 {
+		return nil
+	}
+	err = c.updateQueue(ctx, func(state *queueState) (bool, error) {
+		kept := state.entries[:0]
+		for _, entry := range state.entries {
+			if queueContains(removed, entry) {
+				continue
+			}
+			for _, updated := range rewaited {
+				if sameQueueRequest(updated, entry) {
+					entry.WaitingFor = updated.WaitingFor
+				}
+			}
+			kept = append(kept, entry)
+		}
+		state.entries = kept
+		for _, entry := range dropped {
+			state.dropped, _ = removeDropped(state.dropped, entry.Tenant, entry.SandboxID)
+			state.dropped = append(state.dropped, entry)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range removed {
+		if entry.SecretsRef != "" {
+			c.queueClient(entry.Tenant).deleteQueuedSecrets(ctx, entry.SandboxID)
+		}
+	}
+	return nil
+}
+
+// queueClient returns the client of the namespace a queued request belongs to
+func (c *ClientWithTraefik) queueClient(tenant string) *ClientWithTraefik {
+	if tenant == "" {
+		return c
+	}
+	return c.ForTenant(tenant)
+}
+
+// queuePosition builds the position of a sandbox in the given queue entries
+func (c *Client) queuePosition(ctx context.Context, entries []queueEntry, sandboxID string) (*QueuePosition, error) {
+	entries = dispatchOrder(entries)
+	i := queueIndex(entries, c.tenant, sandboxID)
+	if i < 0 {
+		return nil, ErrSandboxNotQueued
+	}
+
+	position := &QueuePosition{
+		Position: i + 1,
+		Length:   len(entries),
+		QueuedAt: entries[i].QueuedAt.Format(time.RFC3339),
+	}
+	expiries, err := c.runningSandboxExpiries(ctx)
+	if err != nil {
+		log.Printf("Error estimating start of queued sandbox %s: %v", sandboxID, err)
+		return position, nil
+	}
+	if start, ok := estimateQueueStart(expiries, position.Position, time.Now()); ok {
+		position.EstimatedStartAt = start.UTC().Format(time.RFC3339)
+	}
+	return position, nil
+}
+
+// runningSandboxExpiries returns when the running sandboxes of every namespace expire, soonest first
+func (c *Client) runningSandboxExpiries(ctx context.Context) ([]time.Time, error) {
+	deployments, err := c.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var expiries []time.Time
+	for i := range deployments.Items {
+		if deploymentReplicas(&deployments.Items[i]) > 0 {
+			expiries = append(expiries, sandboxExpiry(&deployments.Items[i], c.config.SandboxTimeoutDuration))
+		}
+	}
+	sort.Slice(expiries, func(i, j int) bool { return expiries[i].Before(expiries[j]) })
+	return expiries, nil
+}
+
+// estimateQueueStart estimates when the request at a queue position starts, assuming each
+// running sandbox frees room for one queued sandbox when auto cleanup removes it
+func estimateQueueStart(expiries []time.Time, position int, now time.Time) (time.Time, bool) {
+	if position < 1 || position > len(expiries) {
+		return time.Time{}, false
+	}
+
+	start := expiries[position-1].Add(queueCleanupDelay)
+	if start.Before(now) {
+		start = now.Add(queueCleanupDelay)
+	}
+	return start, true
+}
+
+// loadQueue returns the queued requests, oldest first, and the recently dropped requests,
+// along with the Secret holding them
+func (c *Client) loadQueue(ctx context.Context) (*queueState, *corev1.Secret, error) {
+	state := &queueState{}
+	secret, err := c.clientset.CoreV1().Secrets(c.queue.namespace).Get(ctx, queueSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return state, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read sandbox queue: %w", err)
+	}
+
+	if data := secret.Data[queueDataKey]; len(data) > 0 {
+		if err := json.Unmarshal(data, &state.entries); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse sandbox queue: %w", err)
+		}
+	}
+	if data := secret.Data[queueDroppedKey]; len(data) > 0 {
+		if err := json.Unmarshal(data, &state.dropped); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse dropped queue requests: %w", err)
+		}
+	}
+	return state, secret, nil
+}
+
+// updateQueue applies fn to the queue and stores the result if fn reports a change.
+// Records of dropped requests older than queueDropRetention are removed on the way.
+func (c *Client) updateQueue(ctx context.Context, fn func(*queueState) (bool, error)) error {
+	c.queue.mu.Lock()
+	defer c.queue.mu.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		state, secret, err := c.loadQueue(ctx)
+		if err != nil {
+			return err
+		}
+		changed, err := fn(state)
+		if err != nil || !changed {
+			return err
+		}
+		state.dropped = recentDrops(state.dropped, time.Now(), c.queue.maxSize)
+
+		entries, err := json.Marshal(state.entries)
+		if err != nil {
+			return err
+		}
+		dropped, err := json.Marshal(state.dropped)
+		if err != nil {
+			return err
+		}
+		if secret == nil {
+			_, err = c.clientset.CoreV1().Secrets(c.queue.namespace).Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: queueSecretName},
+				Data:       map[string][]byte{queueDataKey: entries, queueDroppedKey: dropped},
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("secrets"), queueSecretName, err)
+			}
+			return err
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[queueDataKey] = entries
+		secret.Data[queueDroppedKey] = dropped
+		_, err = c.clientset.CoreV1().Secrets(c.queue.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// dispatchOrder returns the entries in the order they are dispatched: higher tiers first,
+// so that free sandboxes never hold up paying ones, and oldest first within a tier
+func dispatchOrder(entries []queueEntry) []queueEntry {
+	ordered := append([]queueEntry(nil), entries...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return tierRank(resolveTier(ordered[i].Options.Tier).name) > tierRank(resolveTier(ordered[j].Options.Tier).name)
+	})
+	return ordered
+}
+
+// queuedAhead counts the requests of other sandboxes in the queue with the same or a higher
+// tier, which a new create of the given tier in tenant must not overtake: those waiting for
+// the cluster and those of the same tenant. It also returns what the new create waits for,
+// which is the tenant's quota only when no counted request waits for the cluster.
+func queuedAhead(entries []queueEntry, tenant, sandboxID, tier string) (int, string) {
+	rank := tierRank(resolveTier(tier).name)
+	ahead, waitingFor := 0, queueWaitTenant
+	for _, entry := range entries {
+		if entry.Tenant == tenant && entry.SandboxID == sandboxID {
+			continue
+		}
+		if !entry.waitsForCluster() && entry.Tenant != tenant {
+			continue
+		}
+		if tierRank(resolveTier(entry.Options.Tier).name) >= rank {
+			ahead++
+			if entry.waitsForCluster() {
+				waitingFor = queueWaitCluster
+			}
+		}
+	}
+	return ahead, waitingFor
+}
+
+// removeDropped removes the drop record of a tenant's sandbox and reports whether there was one
+func removeDropped(dropped []droppedEntry, tenant, sandboxID string) ([]droppedEntry, bool) {
+	for i, entry := range dropped {
+		if entry.Tenant == tenant && entry.SandboxID == sandboxID {
+			return append(dropped[:i], dropped[i+1:]...), true
+		}
+	}
+	return dropped, false
+}
+
+// recentDrops returns the drop records younger than queueDropRetention, at most max of them,
+// keeping the newest
+func recentDrops(dropped []droppedEntry, now time.Time, max int) []droppedEntry {
+	recent := make([]droppedEntry, 0, len(dropped))
+	for _, entry := range dropped {
+		if now.Sub(entry.DroppedAt) < queueDropRetention {
+			recent = append(recent, entry)
+		}
+	}
+	if len(recent) > max {
+		recent = recent[len(recent)-max:]
+	}
+	return recent
+}
+
+// queueIndex returns the index of a tenant's sandbox in the queue, or -1
+func queueIndex(entries []queueEntry, tenant, sandboxID string) int {
+	for i, entry := range entries {
+		if entry.Tenant == tenant && entry.SandboxID == sandboxID {
+			return i
+		}
+	}
+	return -1
+}
+
+// queueContains reports whether entries hold the same request as entry. Requests are
+// compared with their queue time so that a sandbox queued again is not removed.
+func queueContains(entries []queueEntry, entry queueEntry) bool {
+	for _, e := range entries {
+		if sameQueueRequest(e, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameQueueRequest reports whether two entries are the same request of the same sandbox
+func sameQueueRequest(a, b queueEntry) bool {
+	return a.Tenant == b.Tenant && a.SandboxID == b.SandboxID && a.QueuedAt.Equal(b.QueuedAt)
+}
@@ -0,0 +1,203 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWaitsForCapacity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"No node has room", fmt.Errorf("%w: no schedulable node has 1 CPU free", ErrInsufficientCapacity), true},
+		{"Tenant quota", &QuotaExceededError{Scope: QuotaScopeTenant, Limit: QuotaLimitSandboxes}, true},
+		{"User quota", &QuotaExceededError{Scope: QuotaScopeUser, Limit: QuotaLimitSandboxes}, false},
+		{"Invalid options", fmt.Errorf("%w: unknown profile", ErrInvalidSandboxOptions), false},
+		{"Other error", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := WaitsForCapacity(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEstimateQueueStart(t *testing.T) {
+	now := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	expiries := []time.Time{
+		now.Add(-2 * time.Minute),
+		now.Add(10 * time.Minute),
+		now.Add(25 * time.Minute),
+	}
+
+	testCases := []struct {
+		name     string
+		position int
+		expected time.Time
+		ok       bool
+	}{
+		{"Overdue sandbox frees room at the next cleanup", 1, now.Add(queueCleanupDelay), true},
+		{"Second place waits for the second expiry", 2, now.Add(10*time.Minute + queueCleanupDelay), true},
+		{"Last expiry", 3, now.Add(25*time.Minute + queueCleanupDelay), true},
+		{"More queued than running", 4, time.Time{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := estimateQueueStart(expiries, tc.position, now)
+			if ok != tc.ok || !got.Equal(tc.expected) {
+				t.Errorf("Expected %v (%v), got %v (%v)", tc.expected, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestQueueIndex(t *testing.T) {
+	queuedAt := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	entries := []queueEntry{
+		{SandboxID: "user1", QueuedAt: queuedAt},
+		{Tenant: "acme", SandboxID: "user1", QueuedAt: queuedAt},
+		{Tenant: "acme", SandboxID: "user2", QueuedAt: queuedAt},
+	}
+
+	if i := queueIndex(entries, "acme", "user1"); i != 1 {
+		t.Errorf("Expected the tenant's sandbox at index 1, got %d", i)
+	}
+	if i := queueIndex(entries, "", "user2"); i != -1 {
+		t.Errorf("Expected another tenant's sandbox not to be found, got index %d", i)
+	}
+
+	requeued := queueEntry{SandboxID: "user1", QueuedAt: queuedAt.Add(time.Minute)}
+	if queueContains(entries, requeued) {
+		t.Error("Expected a sandbox queued again not to match its earlier request")
+	}
+	if !queueContains(entries, entries[2]) {
+		t.Error("Expected the same request to match")
+	}
+}
+
+func TestDispatchOrder(t *testing.T) {
+	queuedAt := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	entries := []queueEntry{
+		{SandboxID: "free-first", QueuedAt: queuedAt},
+		{SandboxID: "paying", Options: SandboxOptions{Tier: TierPaying}, QueuedAt: queuedAt.Add(time.Minute)},
+		{SandboxID: "free-second", Options: SandboxOptions{Tier: TierFree}, QueuedAt: queuedAt.Add(2 * time.Minute)},
+		{SandboxID: "internal", Options: SandboxOptions{Tier: TierInternal}, QueuedAt: queuedAt.Add(3 * time.Minute)},
+	}
+
+	ordered := dispatchOrder(entries)
+	expected := []string{"paying", "internal", "free-first", "free-second"}
+	for i, sandboxID := range expected {
+		if ordered[i].SandboxID != sandboxID {
+			t.Errorf("Expected %s at position %d, got %s", sandboxID, i+1, ordered[i].SandboxID)
+		}
+	}
+	if entries[0].SandboxID != "free-first" {
+		t.Error("Expected the stored order to be left unchanged")
+	}
+}
+
+func TestQueuedAhead(t *testing.T) {
+	queuedAt := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	entries := []queueEntry{
+		{SandboxID: "free", QueuedAt: queuedAt},
+		{Tenant: "acme", SandboxID: "internal", Options: SandboxOptions{Tier: TierInternal}, QueuedAt: queuedAt, WaitingFor: queueWaitCluster},
+		{SandboxID: "user1", Options: SandboxOptions{Tier: TierPaying}, QueuedAt: queuedAt},
+		{Tenant: "globex", SandboxID: "over-quota", Options: SandboxOptions{Tier: TierPaying}, QueuedAt: queuedAt, WaitingFor: queueWaitTenant},
+	}
+
+	testCases := []struct {
+		name       string
+		tenant     string
+		sandboxID  string
+		tier       string
+		expected   int
+		waitingFor string
+	}{
+		{"Free create waits behind everything waiting for the cluster", "", "new", "", 3, queueWaitCluster},
+		{"Internal create overtakes free requests", "", "new", TierInternal, 2, queueWaitCluster},
+		{"Paying create waits for paying requests", "", "new", TierPaying, 1, queueWaitCluster},
+		{"A queued sandbox does not wait for itself", "", "user1", TierPaying, 0, queueWaitTenant},
+		{"Another tenant waits for the cluster", "acme", "new", TierPaying, 1, queueWaitCluster},
+		{"A tenant waits behind its own requests for its quota", "globex", "new", TierPaying, 2, queueWaitCluster},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ahead, waitingFor := queuedAhead(entries, tc.tenant, tc.sandboxID, tc.tier)
+			if ahead != tc.expected || waitingFor != tc.waitingFor {
+				t.Errorf("Expected %d requests ahead waiting for the %s, got %d waiting for the %s",
+					tc.expected, tc.waitingFor, ahead, waitingFor)
+			}
+		})
+	}
+
+	// With no requests waiting for the cluster, another tenant at its quota holds nobody back
+	tenantOnly := []queueEntry{
+		{Tenant: "globex", SandboxID: "over-quota", QueuedAt: queuedAt, WaitingFor: queueWaitTenant},
+	}
+	if ahead, _ := queuedAhead(tenantOnly, "acme", "new", ""); ahead != 0 {
+		t.Errorf("Expected another tenant's quota not to hold back a create, got %d requests ahead", ahead)
+	}
+	ahead, waitingFor := queuedAhead(tenantOnly, "globex", "new", "")
+	if ahead != 1 || waitingFor != queueWaitTenant {
+		t.Errorf("Expected the tenant's create to wait for its quota, got %d ahead waiting for the %s", ahead, waitingFor)
+	}
+	if reason := queueWaitReason(&queuedAheadError{ahead: ahead, waitingFor: waitingFor}); reason != queueWaitTenant {
+		t.Errorf("Expected the create to be queued for the tenant's quota, got %s", reason)
+	}
+}
+
+func TestQueueWaitReason(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"No node has room", fmt.Errorf("%w: no schedulable node has 1 CPU free", ErrInsufficientCapacity), queueWaitCluster},
+		{"Tenant quota", &QuotaExceededError{Scope: QuotaScopeTenant, Limit: QuotaLimitSandboxes}, queueWaitTenant},
+		{"Behind requests for the cluster", &queuedAheadError{ahead: 2, waitingFor: queueWaitCluster}, queueWaitCluster},
+		{"Behind requests for the tenant", &queuedAheadError{ahead: 1, waitingFor: queueWaitTenant}, queueWaitTenant},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := queueWaitReason(tc.err); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+			if !WaitsForCapacity(tc.err) {
+				t.Error("Expected the create to wait in the queue")
+			}
+		})
+	}
+}
+
+func TestRecentDrops(t *testing.T) {
+	now := time.Date(2023, 4, 20, 12, 0, 0, 0, time.UTC)
+	dropped := []droppedEntry{
+		{SandboxID: "expired", DroppedAt: now.Add(-queueDropRetention)},
+		{SandboxID: "older", DroppedAt: now.Add(-2 * time.Hour)},
+		{SandboxID: "newer", DroppedAt: now.Add(-time.Hour)},
+		{Tenant: "acme", SandboxID: "newest", DroppedAt: now},
+	}
+
+	recent := recentDrops(dropped, now, 2)
+	if len(recent) != 2 || recent[0].SandboxID != "newer" || recent[1].SandboxID != "newest" {
+		t.Errorf("Expected the two newest records, got %+v", recent)
+	}
+
+	recent, removed := removeDropped(recent, "", "newest")
+	if removed || len(recent) != 2 {
+		t.Error("Expected another tenant's record to be kept")
+	}
+	if recent, removed = removeDropped(recent, "acme", "newest"); !removed || len(recent) != 1 {
+		t.Errorf("Expected the tenant's record to be removed, got %+v", recent)
+	}
+}
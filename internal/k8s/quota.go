@@ -0,0 +1,455 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Quota scopes and limits
+const (
+	QuotaScopeTenant    = "tenant"
+	QuotaScopeUser      = "user"
+	QuotaLimitSandboxes = "sandboxes"
+	QuotaLimitCPU       = "cpu"
+	QuotaLimitMemory    = "memory"
+	QuotaLimitStorage   = "storage"
+)
+
+// Names of the objects that back the quotas up in each sandbox namespace
+const (
+	namespaceQuotaName      = "sandbox-quota"
+	namespaceLimitRangeName = "sandbox-limits"
+)
+
+// ErrQuotaExceeded is returned when a sandbox would take a tenant or user over a quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaExceededError reports which quota a create or resume would exceed
+type QuotaExceededError struct {
+	// Scope is the quota that was hit (tenant or user)
+	Scope string
+	// Limit is the exceeded limit (sandboxes, cpu, memory or storage)
+	Limit string
+	// Max is the quota's value for the limit
+	Max string
+	// Used is the amount already in use
+	Used string
+	// Requested is the amount the sandbox needs
+	Requested string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %s limit is %s, %s in use and %s requested",
+		e.Scope, e.Limit, e.Max, e.Used, e.Requested)
+}
+
+// Unwrap lets errors.Is match ErrQuotaExceeded
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// quotaUsage is the amount of each limit used by, or requested for, a set of sandboxes
+type quotaUsage struct {
+	sandboxes int
+	cpu       resource.Quantity
+	memory    resource.Quantity
+	storage   resource.Quantity
+}
+
+// addDeployment adds a running sandbox and the limits of its containers
+func (u *quotaUsage) addDeployment(deployment *appsv1.Deployment) {
+	u.sandboxes++
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		u.cpu.Add(container.Resources.Limits[corev1.ResourceCPU])
+		u.memory.Add(container.Resources.Limits[corev1.ResourceMemory])
+	}
+}
+
+// quotaLocks serialises each quota check with the create or resume it allows, so concurrent
+// requests cannot each see room for one more sandbox. Locks are keyed by namespace or owner
+// and shared by the tenant clients.
+type quotaLocks struct {
+	mu    sync.Mutex
+	locks map[string]*quotaLock
+}
+
+// quotaLock is one key's lock and the number of requests holding or waiting for it
+type quotaLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock blocks until the key's lock is held and returns the function that releases it
+func (l *quotaLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*quotaLock)
+	}
+	entry := l.locks[key]
+	if entry == nil {
+		entry = &quotaLock{}
+		l.locks[key] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+		l.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// lockQuota holds the quota lock for a sandbox of owner until the returned function is called,
+// which may be done more than once. A tenant quota covers the whole namespace, so it is locked
+// as a whole; otherwise only the owner's sandboxes are.
+func (c *Client) lockQuota(owner string) func() {
+	if c.quotaLocks == nil || !c.quotasEnabled() {
+		return func() {}
+	}
+	key := c.namespace + "/" + owner
+	if !c.tenantQuota().IsZero() {
+		key = c.namespace
+	}
+	return sync.OnceFunc(c.quotaLocks.lock(key))
+}
+
+// checkSandboxQuota checks that creating or reconciling a sandbox with opts keeps its tenant and
+// owner within their quotas. The sandbox's current pod, if any, is replaced by the new one.
+// Callers hold lockQuota until the deployment is in place.
+func (c *Client) checkSandboxQuota(ctx context.Context, sandboxID string, opts SandboxOptions) error {
+	if !c.quotasEnabled() {
+		return nil
+	}
+
+	_, size, err := c.resolveProfile(opts.Profile)
+	if err != nil {
+		return err
+	}
+	requested := quotaUsage{sandboxes: 1, cpu: size.cpuLimit, memory: size.memoryLimit}
+
+	// A new data volume is only created when the sandbox has none yet
+	claimName, err := c.sandboxClaimName(ctx, sandboxID, opts)
+	if err != nil {
+		return err
+	}
+	_, err = c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		requested.storage = size.storageSize
+	} else if err != nil {
+		return err
+	}
+
+	owner, _ := opts.ownership(sandboxID)
+	return c.enforceQuotas(ctx, owner, sandboxID, requested)
+}
+
+// checkResumeQuota checks that scaling a paused sandbox back up keeps its tenant and owner within their quotas.
+// Callers hold lockQuota until the deployment is scaled.
+func (c *Client) checkResumeQuota(ctx context.Context, sandboxID string, deployment *appsv1.Deployment) error {
+	if !c.quotasEnabled() || deploymentReplicas(deployment) > 0 {
+		return nil
+	}
+
+	var requested quotaUsage
+	requested.addDeployment(deployment)
+	owner, _ := deploymentOwnership(deployment)
+	return c.enforceQuotas(ctx, owner, sandboxID, requested)
+}
+
+// checkStorageQuota checks that growing one of owner's data volumes from current to size keeps
+// its tenant and owner within their storage quotas. Callers hold lockQuota until the volume is changed.
+func (c *Client) checkStorageQuota(ctx context.Context, owner string, current, size resource.Quantity) error {
+	if !c.quotasEnabled() || size.Cmp(current) <= 0 {
+		return nil
+	}
+
+	requested := quotaUsage{storage: size.DeepCopy()}
+	requested.storage.Sub(current)
+	return c.enforceQuotas(ctx, owner, "", requested)
+}
+
+// quotasEnabled reports whether any quota applies to the client's namespace
+func (c *Client) quotasEnabled() bool {
+	return !c.tenantQuota().IsZero() || !c.config.SandboxQuotas.User.IsZero()
+}
+
+// tenantQuota returns the quota of the client's tenant; the default namespace has none
+func (c *Client) tenantQuota() config.Quota {
+	if c.tenant == "" {
+		return config.Quota{}
+	}
+	return c.config.SandboxQuotas.ForTenant(c.tenant)
+}
+
+// enforceQuotas returns a QuotaExceededError if adding requested to the current usage of the
+// namespace or of the owner's sandboxes exceeds a quota. The sandbox being changed is not counted.
+func (c *Client) enforceQuotas(ctx context.Context, owner, sandboxID string, requested quotaUsage) error {
+	tenantUsed, userUsed, err := c.quotaUsage(ctx, owner, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkQuota(QuotaScopeTenant, c.tenantQuota(), tenantUsed, requested); err != nil {
+		return err
+	}
+	return checkQuota(QuotaScopeUser, c.config.SandboxQuotas.User, userUsed, requested)
+}
+
+// quotaUsage returns what the running sandboxes and data volumes of the namespace, and those of
+// one owner, use. The deployment of the given sandbox is left out.
+func (c *Client) quotaUsage(ctx context.Context, owner, sandboxID string) (*quotaUsage, *quotaUsage, error) {
+	tenantUsed, userUsed := &quotaUsage{}, &quotaUsage{}
+
+	deployments, err := c.clientset.AppsV1().Deployments(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if deployment.Name == fmt.Sprintf("%s-deployment", sandboxID) || deploymentReplicas(deployment) == 0 {
+			continue
+		}
+		tenantUsed.addDeployment(deployment)
+		if deploymentOwner, _ := deploymentOwnership(deployment); deploymentOwner == owner {
+			userUsed.addDeployment(deployment)
+		}
+	}
+
+	claims, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		claimOwner := claimQuotaOwner(claim)
+		if claimOwner == "" {
+			continue
+		}
+		size := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+		tenantUsed.storage.Add(size)
+		if claimOwner == owner {
+			userUsed.storage.Add(size)
+		}
+	}
+	return tenantUsed, userUsed, nil
+}
+
+// claimQuotaOwner returns the user whose quota a data volume counts against, or "" for claims
+// that are not user data. A named sandbox's own volume counts against its owner.
+func claimQuotaOwner(claim *corev1.PersistentVolumeClaim) string {
+	owner := claimUserID(claim)
+	if owner == "" {
+		return ""
+	}
+	if labelled := claim.Labels["owner"]; labelled != "" {
+		owner = labelled
+	}
+	return owner
+}
+
+// checkQuota compares usage plus a request against one quota
+func checkQuota(scope string, quota config.Quota, used *quotaUsage, requested quotaUsage) error {
+	if quota.MaxSandboxes > 0 && requested.sandboxes > 0 && used.sandboxes+requested.sandboxes > quota.MaxSandboxes {
+		return &QuotaExceededError{
+			Scope:     scope,
+			Limit:     QuotaLimitSandboxes,
+			Max:       strconv.Itoa(quota.MaxSandboxes),
+			Used:      strconv.Itoa(used.sandboxes),
+			Requested: strconv.Itoa(requested.sandboxes),
+		}
+	}
+
+	limits := []struct {
+		name      string
+		max       string
+		used      resource.Quantity
+		requested resource.Quantity
+	}{
+		{QuotaLimitCPU, quota.CPU, used.cpu, requested.cpu},
+		{QuotaLimitMemory, quota.Memory, used.memory, requested.memory},
+		{QuotaLimitStorage, quota.Storage, used.storage, requested.storage},
+	}
+	for _, limit := range limits {
+		if limit.max == "" || limit.requested.IsZero() {
+			continue
+		}
+		max, err := resource.ParseQuantity(limit.max)
+		if err != nil {
+			return fmt.Errorf("%s quota is misconfigured: invalid %s %q: %w", scope, limit.name, limit.max, err)
+		}
+		total := limit.used.DeepCopy()
+		total.Add(limit.requested)
+		if total.Cmp(max) > 0 {
+			return &QuotaExceededError{
+				Scope:     scope,
+				Limit:     limit.name,
+				Max:       max.String(),
+				Used:      limit.used.String(),
+				Requested: limit.requested.String(),
+			}
+		}
+	}
+	return nil
+}
+
+// ensureNamespaceLimits creates, in tenant namespaces with a quota, a ResourceQuota matching the
+// tenant quota and the LimitRange that gives containers without resources a default size, which
+// the ResourceQuota requires. They are a backstop for the checks made before a sandbox is created,
+// and also cover pods made outside the API. Namespaces without a quota get neither, so the init
+// containers and helper pods there keep running without limits.
+func (c *Client) ensureNamespaceLimits(ctx context.Context) error {
+	var desired *corev1.ResourceQuota
+	if c.tenant != "" {
+		var err error
+		if desired, err = buildResourceQuota(c.tenantQuota()); err != nil {
+			return err
+		}
+	}
+	if err := c.ensureLimitRange(ctx, desired != nil); err != nil {
+		return err
+	}
+
+	if c.tenant == "" {
+		return nil
+	}
+
+	quotas := c.clientset.CoreV1().ResourceQuotas(c.namespace)
+	existing, err := quotas.Get(ctx, namespaceQuotaName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		if desired == nil {
+			return nil
+		}
+		if _, err := quotas.Create(ctx, desired, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create ResourceQuota: %w", err)
+		}
+		log.Printf("Resource quota created for tenant %s", c.tenant)
+	case err != nil:
+		return err
+	case desired == nil:
+		if err := quotas.Delete(ctx, namespaceQuotaName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ResourceQuota: %w", err)
+		}
+	case !resourceListsEqual(existing.Spec.Hard, desired.Spec.Hard):
+		existing.Spec.Hard = desired.Spec.Hard
+		if _, err := quotas.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update ResourceQuota: %w", err)
+		}
+		log.Printf("Resource quota updated for tenant %s", c.tenant)
+	}
+	return nil
+}
+
+// ensureLimitRange creates the namespace's LimitRange if wanted, and otherwise deletes the one
+// earlier versions created in every namespace
+func (c *Client) ensureLimitRange(ctx context.Context, wanted bool) error {
+	limitRanges := c.clientset.CoreV1().LimitRanges(c.namespace)
+	if !wanted {
+		if err := limitRanges.Delete(ctx, namespaceLimitRangeName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete LimitRange: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := limitRanges.Get(ctx, namespaceLimitRangeName, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+		if _, err := limitRanges.Create(ctx, buildLimitRange(), metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create LimitRange: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// buildLimitRange returns the default container size for a sandbox namespace
+func buildLimitRange() *corev1.LimitRange {
+	return &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespaceLimitRangeName,
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type: corev1.LimitTypeContainer,
+					Default: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("512Mi"),
+					},
+					DefaultRequest: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("100m"),
+						corev1.ResourceMemory: resource.MustParse("128Mi"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildResourceQuota returns the ResourceQuota for a tenant quota, or nil if it sets no resource limits
+func buildResourceQuota(quota config.Quota) (*corev1.ResourceQuota, error) {
+	hard := corev1.ResourceList{}
+	limits := []struct {
+		name     string
+		value    string
+		resource corev1.ResourceName
+	}{
+		{QuotaLimitCPU, quota.CPU, corev1.ResourceLimitsCPU},
+		{QuotaLimitMemory, quota.Memory, corev1.ResourceLimitsMemory},
+		{QuotaLimitStorage, quota.Storage, corev1.ResourceRequestsStorage},
+	}
+	for _, limit := range limits {
+		if limit.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(limit.value)
+		if err != nil {
+			return nil, fmt.Errorf("tenant quota is misconfigured: invalid %s %q: %w", limit.name, limit.value, err)
+		}
+		hard[limit.resource] = quantity
+	}
+	if len(hard) == 0 {
+		return nil, nil
+	}
+
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespaceQuotaName,
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: hard,
+		},
+	}, nil
+}
+
+// resourceListsEqual reports whether two resource lists hold the same quantities
+func resourceListsEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
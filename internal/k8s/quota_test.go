@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckQuota(t *testing.T) {
+	quota := config.Quota{MaxSandboxes: 3, CPU: "4", Memory: "8Gi", Storage: "10Gi"}
+	used := &quotaUsage{
+		sandboxes: 1,
+		cpu:       resource.MustParse("2"),
+		memory:    resource.MustParse("4Gi"),
+		storage:   resource.MustParse("9Gi"),
+	}
+	standard := quotaUsage{
+		sandboxes: 1,
+		cpu:       resource.MustParse("2"),
+		memory:    resource.MustParse("4Gi"),
+	}
+
+	testCases := []struct {
+		name          string
+		quota         config.Quota
+		used          *quotaUsage
+		requested     quotaUsage
+		expectedLimit string
+	}{
+		{"No quota", config.Quota{}, used, standard, ""},
+		{"Within every limit", quota, used, standard, ""},
+		{"Exactly at the limit", config.Quota{CPU: "4"}, used, standard, ""},
+		{"Too many sandboxes", config.Quota{MaxSandboxes: 1}, used, standard, QuotaLimitSandboxes},
+		{"CPU", quota, used, quotaUsage{sandboxes: 1, cpu: resource.MustParse("2500m")}, QuotaLimitCPU},
+		{"Memory", quota, used, quotaUsage{sandboxes: 1, memory: resource.MustParse("5Gi")}, QuotaLimitMemory},
+		{"New volume", quota, used, quotaUsage{sandboxes: 1, storage: resource.MustParse("2Gi")}, QuotaLimitStorage},
+		{"Existing volumes over quota do not block a sandbox without a new volume", config.Quota{Storage: "5Gi"}, used, standard, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkQuota(QuotaScopeUser, tc.quota, tc.used, tc.requested)
+			if tc.expectedLimit == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var quotaErr *QuotaExceededError
+			if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) {
+				t.Fatalf("Expected a QuotaExceededError, got %v", err)
+			}
+			if quotaErr.Limit != tc.expectedLimit || quotaErr.Scope != QuotaScopeUser {
+				t.Errorf("Expected %s limit of the user quota, got %s limit of the %s quota", tc.expectedLimit, quotaErr.Limit, quotaErr.Scope)
+			}
+		})
+	}
+
+	if err := checkQuota(QuotaScopeTenant, config.Quota{CPU: "lots"}, used, standard); err == nil || errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected a configuration error for an invalid quantity, got %v", err)
+	}
+}
+
+func TestLockQuota(t *testing.T) {
+	c := &Client{
+		namespace:  "user-sandboxes",
+		config:     &config.Configuration{SandboxQuotas: config.SandboxQuotas{User: config.Quota{MaxSandboxes: 1}}},
+		quotaLocks: &quotaLocks{},
+	}
+
+	unlock := c.lockQuota("alice")
+
+	// Another owner is not held up
+	other := make(chan struct{})
+	go func() {
+		c.lockQuota("bob")()
+		close(other)
+	}()
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("Expected another owner's lock to be free")
+	}
+
+	// The same owner waits until the lock is released
+	acquired := make(chan struct{})
+	go func() {
+		c.lockQuota("alice")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the same owner's lock to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the lock to be acquired after release")
+	}
+	if len(c.quotaLocks.locks) != 0 {
+		t.Errorf("Expected released locks to be removed, got %d", len(c.quotaLocks.locks))
+	}
+}
+
+func TestClaimQuotaOwner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		labels   map[string]string
+		expected string
+	}{
+		{"Default volume", map[string]string{"app": "user-sandbox", "user": "user1"}, "user1"},
+		{"Named sandbox volume", map[string]string{"app": "user-sandbox", "user": "user1-work", "owner": "user1"}, "user1"},
+		{"Not user data", map[string]string{"app": "warm-pool"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "claim", Labels: tc.labels}}
+			if got := claimQuotaOwner(claim); got != tc.expected {
+				t.Errorf("Expected owner %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readinessPollInterval is how often the sandbox status is checked while waiting for readiness
+const readinessPollInterval = 2 * time.Second
+
+// terminalWaitingReasons are container waiting reasons that will not resolve by waiting longer
+var terminalWaitingReasons = map[string]bool{
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// persistentWaitingReasons are container waiting reasons that often clear on their own,
+// such as a registry hiccup or a dependency that starts late. They only fail the wait
+// once they have lasted persistentFailureGrace, or for a crash loop, crashLoopRestarts restarts.
+// The value groups reasons a container alternates between, so ErrImagePull and
+// ImagePullBackOff share one grace period.
+var persistentWaitingReasons = map[string]string{
+	"ImagePullBackOff": "ImagePull",
+	"ErrImagePull":     "ImagePull",
+	"CrashLoopBackOff": "CrashLoop",
+}
+
+// persistentFailureGrace is how long a container may stay in a persistent waiting reason
+const persistentFailureGrace = 1 * time.Minute
+
+// crashLoopRestarts is the restart count at which a crash-looping container is given up on
+const crashLoopRestarts = 3
+
+// SandboxNotReadyError is returned when a sandbox fails or times out while waiting for readiness.
+// Sandbox holds the last observed status with its pod and container diagnostics.
+type SandboxNotReadyError struct {
+	UserID  string
+	Reason  string
+	Sandbox *SandboxInfo
+	Err     error
+}
+
+// Error implements the error interface
+func (e *SandboxNotReadyError) Error() string {
+	return fmt.Sprintf("sandbox for user %s did not become ready (%s): %v", e.UserID, e.Reason, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *SandboxNotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the wait ended because the deadline passed rather than a pod failure
+func (e *SandboxNotReadyError) Timeout() bool {
+	return e.Reason == "Timeout"
+}
+
+// WaitForSandboxReady polls the sandbox status until its pod passes the readiness probe,
+// the timeout elapses or ctx is cancelled. Pod status is only trusted once the deployment has
+// pods from its current template, so the old pod of an update or rollout is not mistaken for it. It gives up early when a container is in a state
+// that waiting will not fix, such as InvalidImageName, or has stayed in ImagePullBackOff
+// or CrashLoopBackOff for longer than a transient failure would.
+// On failure a *SandboxNotReadyError carries the last observed status.
+func (c *Client) WaitForSandboxReady(ctx context.Context, userID string, timeout time.Duration) (*SandboxInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	var info *SandboxInfo
+	waitingSince := make(map[string]time.Time)
+	for {
+		current, err := c.GetSandboxStatus(ctx, userID)
+		if err == nil {
+			info = current
+		}
+		if err == nil && c.sandboxRolledOut(ctx, userID) {
+			if info.Status == "Running" {
+				reportProgress(ctx, "ready")
+				return info, nil
+			}
+			if reason := sandboxFailureReason(info, waitingSince, time.Now()); reason != "" {
+				return info, &SandboxNotReadyError{
+					UserID:  userID,
+					Reason:  reason,
+					Sandbox: info,
+					Err:     fmt.Errorf("sandbox is in state %s", reason),
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			reason := "Timeout"
+			if ctx.Err() == context.Canceled {
+				reason = "Cancelled"
+			}
+			return info, &SandboxNotReadyError{
+				UserID:  userID,
+				Reason:  reason,
+				Sandbox: info,
+				Err:     fmt.Errorf("gave up after %v: %w", timeout, ctx.Err()),
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// sandboxRolledOut reports whether the sandbox's deployment controller has seen its latest
+// template and created pods from it, so the newest pod is one of them
+func (c *Client) sandboxRolledOut(ctx context.Context, userID string) bool {
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, fmt.Sprintf("%s-deployment", userID), metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	return deploymentRolledOut(deployment)
+}
+
+// deploymentRolledOut reports whether a deployment's status describes its current spec and
+// all of its replicas come from the current template
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false
+	}
+	return deployment.Status.UpdatedReplicas >= deploymentReplicas(deployment)
+}
+
+// sandboxFailureReason returns the reason a sandbox cannot become ready without
+// intervention, or an empty string if it may still become ready.
+// waitingSince records when each container was first seen in a persistent waiting
+// reason; it is updated in place so it can be carried across polls.
+func sandboxFailureReason(info *SandboxInfo, waitingSince map[string]time.Time, now time.Time) string {
+	if terminalWaitingReasons[info.Status] {
+		return info.Status
+	}
+	seen := make(map[string]bool)
+	failure := ""
+	for _, statuses := range [][]ContainerStatus{info.InitContainerStatuses, info.ContainerStatuses} {
+		for _, cs := range statuses {
+			if cs.State != "waiting" {
+				continue
+			}
+			if terminalWaitingReasons[cs.Reason] {
+				return cs.Reason
+			}
+			group, ok := persistentWaitingReasons[cs.Reason]
+			if !ok {
+				continue
+			}
+			key := cs.Name + "/" + group
+			seen[key] = true
+			since, ok := waitingSince[key]
+			if !ok {
+				since = now
+				waitingSince[key] = now
+			}
+			persisted := now.Sub(since) >= persistentFailureGrace ||
+				(cs.Reason == "CrashLoopBackOff" && cs.RestartCount >= crashLoopRestarts)
+			if persisted && failure == "" {
+				failure = cs.Reason
+			}
+		}
+	}
+	// A container that left a persistent reason starts its grace period afresh if it returns
+	for key := range waitingSince {
+		if !seen[key] {
+			delete(waitingSince, key)
+		}
+	}
+	return failure
+}
@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestSandboxFailureReason(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	waiting := func(reason string, restarts int32) *SandboxInfo {
+		return &SandboxInfo{
+			Status: "Pending",
+			ContainerStatuses: []ContainerStatus{
+				{Name: "sandbox", State: "waiting", Reason: reason, RestartCount: restarts},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		polls    []*SandboxInfo
+		interval time.Duration
+		expected string
+	}{
+		{"invalid image fails at once", []*SandboxInfo{waiting("InvalidImageName", 0)}, 0, "InvalidImageName"},
+		{"config error fails at once", []*SandboxInfo{waiting("CreateContainerConfigError", 0)}, 0, "CreateContainerConfigError"},
+		{"first image pull error is tolerated", []*SandboxInfo{waiting("ErrImagePull", 0)}, 0, ""},
+		{"image pull within grace is tolerated", []*SandboxInfo{
+			waiting("ErrImagePull", 0), waiting("ImagePullBackOff", 0),
+		}, 30 * time.Second, ""},
+		{"image pull alternating past grace fails", []*SandboxInfo{
+			waiting("ErrImagePull", 0), waiting("ImagePullBackOff", 0), waiting("ErrImagePull", 0),
+		}, 30 * time.Second, "ErrImagePull"},
+		{"image pull that cleared restarts grace", []*SandboxInfo{
+			waiting("ErrImagePull", 0), waiting("ContainerCreating", 0), waiting("ImagePullBackOff", 0),
+		}, 40 * time.Second, ""},
+		{"early crash loop is tolerated", []*SandboxInfo{waiting("CrashLoopBackOff", 1)}, 0, ""},
+		{"crash loop past restart limit fails", []*SandboxInfo{waiting("CrashLoopBackOff", crashLoopRestarts)}, 0, "CrashLoopBackOff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waitingSince := make(map[string]time.Time)
+			var result string
+			for i, info := range tt.polls {
+				result = sandboxFailureReason(info, waitingSince, start.Add(time.Duration(i)*tt.interval))
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDeploymentRolledOut(t *testing.T) {
+	deployment := func(generation, observed int64, replicas, updated int32) *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		d.Generation = generation
+		d.Spec.Replicas = &replicas
+		d.Status.ObservedGeneration = observed
+		d.Status.UpdatedReplicas = updated
+		return d
+	}
+
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		expected   bool
+	}{
+		{"new pod created from the current template", deployment(2, 2, 1, 1), true},
+		{"update not yet seen by the controller", deployment(3, 2, 1, 1), false},
+		{"old pod still the only one", deployment(3, 3, 1, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deploymentRolledOut(tt.deployment); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Reconcile outcomes for a single sandbox resource
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+	ActionClaimed   = "claimed"
+)
+
+// ResourceAction records what CreateSandbox did with one of the sandbox's resources
+type ResourceAction struct {
+	Resource string `json:"resource" example:"deployment"`
+	Name     string `json:"name" example:"user123-deployment"`
+	Action   string `json:"action" example:"created"`
+}
+
+// SandboxCreateResult summarises the resources reconciled by CreateSandbox
+type SandboxCreateResult struct {
+	Resources []ResourceAction
+}
+
+// WarmStart reports whether the sandbox's pod was claimed from the warm pool
+func (r *SandboxCreateResult) WarmStart() bool {
+	for _, res := range r.Resources {
+		if res.Resource == "pod" && res.Action == ActionClaimed {
+			return true
+		}
+	}
+	return false
+}
+
+// Created reports whether the sandbox's deployment was newly created
+func (r *SandboxCreateResult) Created() bool {
+	for _, res := range r.Resources {
+		if res.Resource == "deployment" && res.Action == ActionCreated {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed reports whether any resource was created or repaired
+func (r *SandboxCreateResult) Changed() bool {
+	for _, res := range r.Resources {
+		if res.Action != ActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// ensurePVC creates the user's PVC if it is missing and returns the claim's name.
+// An existing claim, including one adopted from the warm pool, is always kept as-is.
+func (c *Client) ensurePVC(ctx context.Context, userID string, opts SandboxOptions) (string, string, error) {
+	claimName, err := c.dataClaimName(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if claimName != fmt.Sprintf("%s-pvc", userID) {
+		return claimName, ActionUnchanged, nil
+	}
+
+	created, err := c.createPVC(ctx, userID, opts)
+	if err != nil {
+		return "", "", err
+	}
+	if created {
+		return claimName, ActionCreated, nil
+	}
+	return claimName, ActionUnchanged, nil
+}
+
+// ensureDeployment creates the user's deployment, or repairs an existing one whose
+// pod template no longer matches the service selector, ports or PVC.
+// A paused deployment is scaled back up, since create is expected to yield a running sandbox.
+// An explicitly requested TTL restarts the sandbox's lifetime from now, and an explicitly
+// requested profile that differs from the current one resizes the pod (not the PVC).
+// The running image, environment, secrets and tier are kept unless the request sets them.
+func (c *Client) ensureDeployment(ctx context.Context, userID string, opts SandboxOptions) (string, error) {
+	desired, err := c.buildDeployment(ctx, userID, opts)
+	if err != nil {
+		return "", err
+	}
+
+	deployments := c.clientset.AppsV1().Deployments(c.namespace)
+	existing, err := deployments.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = deployments.Create(ctx, desired, metav1.CreateOptions{})
+		return createOutcome(err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The selector is immutable, so a mismatch cannot be repaired in place
+	if !reflect.DeepEqual(existing.Spec.Selector, desired.Spec.Selector) {
+		return "", fmt.Errorf("deployment %s has an unexpected selector; delete the sandbox and create it again", desired.Name)
+	}
+
+	// Carry over what the request does not set, so a repeated create does not
+	// upgrade the sandbox or drop its environment
+	keepUnrequestedSettings(&existing.Spec.Template, &desired.Spec.Template, opts)
+
+	changed := false
+	profileChanged := opts.Profile != "" && existing.Annotations[annotationProfile] != desired.Annotations[annotationProfile]
+	tierChanged := existing.Spec.Template.Spec.PriorityClassName != desired.Spec.Template.Spec.PriorityClassName
+	if profileChanged || tierChanged || podTemplateDrifted(&existing.Spec.Template, &desired.Spec.Template) ||
+		sandboxSettingsDrifted(&existing.Spec.Template, &desired.Spec.Template) {
+		existing.Spec.Template = desired.Spec.Template
+		changed = true
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	// Sandboxes created before named sandboxes gain the owner labels without a restart
+	for key, value := range opts.ownerLabels(userID) {
+		if existing.Labels[key] != value {
+			existing.Labels[key] = value
+			changed = true
+		}
+	}
+	if existing.Spec.Replicas == nil || *existing.Spec.Replicas == 0 {
+		existing.Spec.Replicas = desired.Spec.Replicas
+		// Scaling back up counts as activity for idle cleanup
+		for key, value := range activityAnnotation(time.Now()) {
+			existing.Annotations[key] = value
+		}
+		changed = true
+	}
+	if profileChanged {
+		existing.Annotations[annotationProfile] = desired.Annotations[annotationProfile]
+	}
+	if opts.imageRequested() && existing.Annotations[annotationImage] != desired.Annotations[annotationImage] {
+		existing.Annotations[annotationImage] = desired.Annotations[annotationImage]
+		changed = true
+	}
+	if opts.TTL > 0 {
+		for _, key := range []string{annotationTTL, annotationExpiresAt} {
+			existing.Annotations[key] = desired.Annotations[key]
+		}
+		changed = true
+	}
+	if !changed {
+		return ActionUnchanged, nil
+	}
+
+	if _, err := deployments.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return ActionUpdated, nil
+}
+
+// ensureService creates the user's service, or restores its selector and ports
+func (c *Client) ensureService(ctx context.Context, userID string) (string, error) {
+	desired := c.buildService(userID)
+
+	services := c.clientset.CoreV1().Services(c.namespace)
+	existing, err := services.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = services.Create(ctx, desired, metav1.CreateOptions{})
+		return createOutcome(err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if reflect.DeepEqual(existing.Spec.Selector, desired.Spec.Selector) &&
+		servicePortsMatch(existing.Spec.Ports, desired.Spec.Ports) {
+		return ActionUnchanged, nil
+	}
+
+	// ClusterIP and other allocated fields are kept from the existing service
+	existing.Spec.Selector = desired.Spec.Selector
+	existing.Spec.Ports = desired.Spec.Ports
+	if _, err := services.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return ActionUpdated, nil
+}
+
+// ensureIngressRoute creates an IngressRoute, or restores its spec if it has drifted
+func (c *ClientWithTraefik) ensureIngressRoute(ctx context.Context, desired *IngressRoute) (string, error) {
+	routes := c.dynamicClient.Resource(IngressRouteGVR()).Namespace(c.namespace)
+	existing, err := routes.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return createOutcome(c.createIngressRouteObject(ctx, desired))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var current IngressRoute
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existing.Object, &current); err == nil &&
+		reflect.DeepEqual(current.Spec, desired.Spec) {
+		return ActionUnchanged, nil
+	}
+
+	desiredObj, err := convertToUnstructured(desired)
+	if err != nil {
+		return "", err
+	}
+	existing.Object["spec"] = desiredObj.Object["spec"]
+	if _, err := routes.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return ActionUpdated, nil
+}
+
+// createOutcome maps the result of a create call to a reconcile action.
+// A concurrent request may have created the resource first, which is not an error.
+func createOutcome(err error) (string, error) {
+	if apierrors.IsAlreadyExists(err) {
+		return ActionUnchanged, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ActionCreated, nil
+}
+
+// podTemplateDrifted reports whether the parts of a pod template that routing and
+// storage depend on differ from the desired template
+func podTemplateDrifted(existing, desired *corev1.PodTemplateSpec) bool {
+	for key, value := range desired.Labels {
+		if existing.Labels[key] != value {
+			return true
+		}
+	}
+
+	if !reflect.DeepEqual(claimNames(existing.Spec.Volumes), claimNames(desired.Spec.Volumes)) {
+		return true
+	}
+
+	current := findContainer(existing.Spec.Containers, "sandbox")
+	wanted := findContainer(desired.Spec.Containers, "sandbox")
+	if current == nil || wanted == nil {
+		return true
+	}
+	// A sandbox that shares a volume must keep its own browser directory on it
+	if !reflect.DeepEqual(dataVolumeMounts(current), dataVolumeMounts(wanted)) {
+		return true
+	}
+	return !reflect.DeepEqual(containerPortNumbers(current), containerPortNumbers(wanted))
+}
+
+// dataVolumeMounts returns a container's mounts of the user data volume
+func dataVolumeMounts(container *corev1.Container) []corev1.VolumeMount {
+	var mounts []corev1.VolumeMount
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == "user-data" {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+// keepUnrequestedSettings copies the image, environment, secrets reference and priority
+// class of the existing sandbox into the desired template unless the options set them
+func keepUnrequestedSettings(existing, desired *corev1.PodTemplateSpec, opts SandboxOptions) {
+	if opts.Tier == "" {
+		desired.Spec.PriorityClassName = existing.Spec.PriorityClassName
+	}
+
+	current := findContainer(existing.Spec.Containers, "sandbox")
+	wanted := findContainer(desired.Spec.Containers, "sandbox")
+	if current == nil || wanted == nil {
+		return
+	}
+
+	if !opts.imageRequested() {
+		wanted.Image = current.Image
+	}
+	if len(opts.Env) == 0 {
+		wanted.Env = current.Env
+	}
+	if len(opts.Secrets) == 0 {
+		wanted.EnvFrom = current.EnvFrom
+		if hash, ok := existing.Annotations[annotationSecretsHash]; ok {
+			if desired.Annotations == nil {
+				desired.Annotations = map[string]string{}
+			}
+			desired.Annotations[annotationSecretsHash] = hash
+		}
+	}
+}
+
+// sandboxSettingsDrifted reports whether the image, environment or secrets of the
+// sandbox container differ from the desired template
+func sandboxSettingsDrifted(existing, desired *corev1.PodTemplateSpec) bool {
+	current := findContainer(existing.Spec.Containers, "sandbox")
+	wanted := findContainer(desired.Spec.Containers, "sandbox")
+	if current == nil || wanted == nil {
+		return true
+	}
+
+	return current.Image != wanted.Image ||
+		!reflect.DeepEqual(current.Env, wanted.Env) ||
+		!reflect.DeepEqual(current.EnvFrom, wanted.EnvFrom) ||
+		existing.Annotations[annotationSecretsHash] != desired.Annotations[annotationSecretsHash]
+}
+
+// servicePortsMatch compares service ports ignoring fields defaulted by the API server
+func servicePortsMatch(existing, desired []corev1.ServicePort) bool {
+	if len(existing) != len(desired) {
+		return false
+	}
+	for i := range desired {
+		if existing[i].Name != desired[i].Name ||
+			existing[i].Port != desired[i].Port ||
+			existing[i].TargetPort != desired[i].TargetPort {
+			return false
+		}
+	}
+	return true
+}
+
+// findContainer returns the container with the given name, or nil
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// claimNames returns the PVC claim names referenced by a set of volumes
+func claimNames(volumes []corev1.Volume) []string {
+	var names []string
+	for _, volume := range volumes {
+		if volume.PersistentVolumeClaim != nil {
+			names = append(names, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return names
+}
+
+// containerPortNumbers returns the port numbers exposed by a container
+func containerPortNumbers(container *corev1.Container) []int32 {
+	var ports []int32
+	for _, port := range container.Ports {
+		ports = append(ports, port.ContainerPort)
+	}
+	return ports
+}
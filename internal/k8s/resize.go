@@ -0,0 +1,172 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Storage resize states
+const (
+	StorageResized                 = "Resized"
+	StorageResizing                = "Resizing"
+	StorageFileSystemResizePending = "FileSystemResizePending"
+)
+
+// ErrStorageNotExpandable is returned when the PVC's storage class does not allow volume expansion
+var ErrStorageNotExpandable = errors.New("storage class does not allow volume expansion")
+
+// StorageInfo describes the size of a user's data volume and the progress of a resize
+type StorageInfo struct {
+	// PVC holding the user's data
+	ClaimName string `json:"claimName" example:"user123-pvc"`
+	// Storage class of the PVC
+	StorageClass string `json:"storageClass,omitempty" example:"standard-rwo"`
+	// Requested size
+	Requested string `json:"requested" example:"5Gi"`
+	// Size of the provisioned volume
+	Capacity string `json:"capacity,omitempty" example:"1Gi"`
+	// Resize state (Resized, Resizing or FileSystemResizePending)
+	Status string `json:"status" example:"FileSystemResizePending"`
+	// Whether the sandbox must be restarted (paused and resumed) to finish growing the file system
+	RestartRequired bool `json:"restartRequired" example:"false"`
+	// Message from the PVC's resize condition
+	Message string `json:"message,omitempty" example:""`
+}
+
+// GetSandboxStorage reports the size of the user's data volume and any resize in progress
+func (c *Client) GetSandboxStorage(ctx context.Context, userID string) (*StorageInfo, error) {
+	pvc, err := c.getDataClaim(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return storageInfo(pvc), nil
+}
+
+// ResizeSandboxStorage expands the user's data volume to size. The volume is grown in
+// place by the storage driver; volumes can only grow and the PVC's storage class must
+// allow expansion. It fails with a QuotaExceededError if the larger volume would exceed
+// the tenant's or owner's storage quota. Progress is reported by GetSandboxStorage.
+func (c *Client) ResizeSandboxStorage(ctx context.Context, userID, size string) (*StorageInfo, error) {
+	requested, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid size %q: %v", ErrInvalidSandboxOptions, size, err)
+	}
+
+	pvc, err := c.getDataClaim(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	switch requested.Cmp(current) {
+	case -1:
+		return nil, fmt.Errorf("%w: size %s is smaller than the current %s; volumes cannot shrink",
+			ErrInvalidSandboxOptions, requested.String(), current.String())
+	case 0:
+		return storageInfo(pvc), nil
+	}
+
+	if err := c.checkVolumeExpansion(ctx, pvc); err != nil {
+		return nil, err
+	}
+
+	// Another resize or create must not pass the quota check until the claim has grown
+	owner := claimQuotaOwner(pvc)
+	if owner == "" {
+		owner = userID
+	}
+	unlockQuota := c.lockQuota(owner)
+	defer unlockQuota()
+	if err := c.checkStorageQuota(ctx, owner, current, requested); err != nil {
+		return nil, err
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q}}}}`, requested.String()))
+	updated, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Patch(ctx, pvc.Name,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resize PVC %s: %w", pvc.Name, err)
+	}
+
+	log.Printf("Storage for user %s resizing from %s to %s", userID, current.String(), requested.String())
+	return storageInfo(updated), nil
+}
+
+// getDataClaim returns the PVC holding the user's data
+func (c *Client) getDataClaim(ctx context.Context, userID string) (*corev1.PersistentVolumeClaim, error) {
+	claimName, err := c.dataClaimName(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("sandbox data not found for user ID %s: %w", userID, err)
+		}
+		return nil, err
+	}
+	return pvc, nil
+}
+
+// checkVolumeExpansion returns ErrStorageNotExpandable unless the PVC's storage class allows expansion
+func (c *Client) checkVolumeExpansion(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return fmt.Errorf("%w: PVC %s has no storage class", ErrStorageNotExpandable, pvc.Name)
+	}
+
+	class, err := c.clientset.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get storage class %s: %w", *pvc.Spec.StorageClassName, err)
+	}
+	if class.AllowVolumeExpansion == nil || !*class.AllowVolumeExpansion {
+		return fmt.Errorf("%w: %s", ErrStorageNotExpandable, class.Name)
+	}
+	return nil
+}
+
+// storageInfo summarises a PVC's size and resize conditions
+func storageInfo(pvc *corev1.PersistentVolumeClaim) *StorageInfo {
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	info := &StorageInfo{
+		ClaimName: pvc.Name,
+		Requested: requested.String(),
+		Status:    StorageResized,
+	}
+	if pvc.Spec.StorageClassName != nil {
+		info.StorageClass = *pvc.Spec.StorageClassName
+	}
+
+	capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if ok {
+		info.Capacity = capacity.String()
+	}
+	if !ok || capacity.Cmp(requested) < 0 {
+		info.Status = StorageResizing
+	}
+
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			// The volume has grown; the node grows the file system when the pod next mounts it
+			info.Status = StorageFileSystemResizePending
+			info.RestartRequired = true
+			info.Message = condition.Message
+		case corev1.PersistentVolumeClaimResizing:
+			info.Status = StorageResizing
+			info.Message = condition.Message
+		}
+	}
+	return info
+}
@@ -0,0 +1,141 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rollbackTimeout bounds how long cleanup of a failed create may take
+const rollbackTimeout = 30 * time.Second
+
+// RollbackAction describes a cleanup performed after a failed sandbox creation
+type RollbackAction struct {
+	Resource string `json:"resource" example:"deployment"`
+	Name     string `json:"name" example:"user123-deployment"`
+	Deleted  bool   `json:"deleted" example:"true"`
+	// Resumed is set for a sandbox that was paused to make room and has been resumed
+	Resumed bool   `json:"resumed,omitempty" example:"false"`
+	Error   string `json:"error,omitempty" example:""`
+}
+
+// SandboxCreateError is returned when CreateSandbox fails part way through.
+// It records the step that failed and the rollback actions that were run.
+type SandboxCreateError struct {
+	UserID   string
+	Step     string
+	Err      error
+	Rollback []RollbackAction
+}
+
+// Error implements the error interface
+func (e *SandboxCreateError) Error() string {
+	return fmt.Sprintf("failed to create sandbox for user %s at step %s: %v", e.UserID, e.Step, e.Err)
+}
+
+// Unwrap returns the underlying Kubernetes error
+func (e *SandboxCreateError) Unwrap() error {
+	return e.Err
+}
+
+// createdResource is a resource made during a create call, with the function that removes it.
+// A preempted sandbox is resumed instead.
+type createdResource struct {
+	resource  string
+	name      string
+	remove    func(ctx context.Context, name string) error
+	preempted bool
+}
+
+// createTransaction tracks the resources reconciled by a single CreateSandbox call
+type createTransaction struct {
+	userID  string
+	actions []ResourceAction
+	created []createdResource
+}
+
+// newCreateTransaction starts tracking resources created for a user
+func newCreateTransaction(userID string) *createTransaction {
+	return &createTransaction{userID: userID}
+}
+
+// track records what happened to a resource. Only resources created or claimed by
+// this call are removed on rollback; existing or repaired resources are left alone.
+func (t *createTransaction) track(resource, name, action string, remove func(ctx context.Context, name string) error) {
+	t.actions = append(t.actions, ResourceAction{Resource: resource, Name: name, Action: action})
+	if action == ActionCreated || action == ActionClaimed {
+		t.created = append(t.created, createdResource{resource: resource, name: name, remove: remove})
+	}
+}
+
+// trackPreemption records a sandbox paused to make room for this one, which is resumed on rollback
+func (t *createTransaction) trackPreemption(name string, resume func(ctx context.Context) error) {
+	t.created = append(t.created, createdResource{
+		resource:  "preempted-sandbox",
+		name:      name,
+		remove:    func(ctx context.Context, _ string) error { return resume(ctx) },
+		preempted: true,
+	})
+}
+
+// result returns the reconcile outcome of every tracked resource
+func (t *createTransaction) result() *SandboxCreateResult {
+	return &SandboxCreateResult{Resources: t.actions}
+}
+
+// rollback removes the recorded resources in reverse creation order and
+// returns a SandboxCreateError describing the failure and the cleanup
+func (t *createTransaction) rollback(step string, err error) error {
+	// The caller's context may already be cancelled, so cleanup gets its own
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	actions := make([]RollbackAction, 0, len(t.created))
+	for i := len(t.created) - 1; i >= 0; i-- {
+		res := t.created[i]
+		action := RollbackAction{
+			Resource: res.resource,
+			Name:     res.name,
+		}
+		if removeErr := res.remove(ctx, res.name); removeErr != nil {
+			log.Printf("Rollback failed to undo %s %s: %v", res.resource, res.name, removeErr)
+			action.Error = removeErr.Error()
+		} else if res.preempted {
+			action.Resumed = true
+		} else {
+			action.Deleted = true
+		}
+		actions = append(actions, action)
+	}
+
+	log.Printf("Sandbox creation for user %s failed at step %s, rolled back %d resources: %v",
+		t.userID, step, len(actions), err)
+
+	return &SandboxCreateError{
+		UserID:   t.userID,
+		Step:     step,
+		Err:      err,
+		Rollback: actions,
+	}
+}
+
+// deletePVC deletes a persistent volume claim by name
+func (c *Client) deletePVC(ctx context.Context, name string) error {
+	return c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// deleteDeployment deletes a deployment and its pods by name
+func (c *Client) deleteDeployment(ctx context.Context, name string) error {
+	propagation := metav1.DeletePropagationBackground
+	return c.clientset.AppsV1().Deployments(c.namespace).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+}
+
+// deleteService deletes a service by name
+func (c *Client) deleteService(ctx context.Context, name string) error {
+	return c.clientset.CoreV1().Services(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
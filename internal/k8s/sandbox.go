@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -33,6 +35,14 @@ type SandboxInfo struct {
 	InitContainerStatuses []ContainerStatus `json:"initContainerStatuses,omitempty"`
 	Message          string            `json:"message,omitempty" example:""`
 	Reason           string            `json:"reason,omitempty" example:""`
+	ExpiresAt        string            `json:"expiresAt,omitempty" example:"2023-04-20T12:30:00Z"`
+	LastActivityAt   string            `json:"lastActivityAt,omitempty" example:"2023-04-20T12:10:00Z"`
+	Profile          string            `json:"profile,omitempty" example:"standard"`
+	Image            string            `json:"image,omitempty" example:"us-central1-docker.pkg.dev/driven-seer-460401-p9/iris-repo/iris_agent:latest"`
+	ImagePinned      bool              `json:"imagePinned,omitempty" example:"false"`
+	Owner            string            `json:"owner,omitempty" example:"user123"`
+	Name             string            `json:"name,omitempty" example:"default"`
+	Tier             string            `json:"tier,omitempty" example:"free"`
 }
 
 // CreateSandbox creates a new sandbox for a user
@@ -46,7 +56,7 @@ func (c *Client) CreateSandbox(userID string) error {
 	}
 
 	// Create PVC for user
-	if err := c.createPVC(ctx, userID); err != nil {
+	if _, err := c.createPVC(ctx, userID, SandboxOptions{}); err != nil {
 		return err
 	}
 
@@ -134,24 +144,7 @@ func (c *Client) ListSandboxes(ctx context.Context) ([]SandboxInfo, error) {
 			continue
 		}
 
-		// Check deployment status
-		status := "Unknown"
-		if deployment.Status.AvailableReplicas > 0 {
-			status = "Running"
-		} else if deployment.Status.UnavailableReplicas > 0 {
-			status = "Unavailable"
-		} else if deployment.Status.ReadyReplicas == 0 {
-			status = "Pending"
-		}
-
-		// Get creation timestamp
-		createdAt := deployment.CreationTimestamp.Format(metav1.RFC3339Micro)
-
-		sandboxes = append(sandboxes, SandboxInfo{
-			UserID:    userID,
-			Status:    status,
-			CreatedAt: createdAt,
-		})
+		sandboxes = append(sandboxes, *sandboxInfoFromDeployment(userID, &deployment, c.config.SandboxTimeoutDuration))
 	}
 
 	return sandboxes, nil
@@ -170,6 +163,27 @@ func (c *Client) GetSandboxStatus(ctx context.Context, userID string) (*SandboxI
 		return nil, fmt.Errorf("sandbox not found for user ID %s: %w", userID, err)
 	}
 
+	// Create base sandbox info
+	sandboxInfo := sandboxInfoFromDeployment(userID, deployment, c.config.SandboxTimeoutDuration)
+
+	// A paused sandbox has no pods worth inspecting
+	if sandboxInfo.Status == "Paused" {
+		return sandboxInfo, nil
+	}
+
+	// Get the pods associated with this deployment
+	pods, err := c.listSandboxPods(ctx, userID)
+	if err != nil {
+		// If we can't get pods, just return the basic info
+		return sandboxInfo, nil
+	}
+
+	applyPodStatus(sandboxInfo, pods)
+	return sandboxInfo, nil
+}
+
+// sandboxInfoFromDeployment builds the base sandbox info from the user's deployment
+func sandboxInfoFromDeployment(userID string, deployment *appsv1.Deployment, defaultTTL time.Duration) *SandboxInfo {
 	// Get creation timestamp
 	createdAt := deployment.CreationTimestamp.Format(metav1.RFC3339Micro)
 
@@ -177,42 +191,64 @@ func (c *Client) GetSandboxStatus(ctx context.Context, userID string) (*SandboxI
 	sandboxInfo := &SandboxInfo{
 		UserID:    userID,
 		CreatedAt: createdAt,
+		Status:    deploymentStatus(deployment),
+		ExpiresAt: sandboxExpiry(deployment, defaultTTL).UTC().Format(time.RFC3339),
+		Profile:   deployment.Annotations[annotationProfile],
+		Tier:      deploymentTier(deployment),
+	}
+	sandboxInfo.Owner, sandboxInfo.Name = deploymentOwnership(deployment)
+	if container := findContainer(deployment.Spec.Template.Spec.Containers, "sandbox"); container != nil {
+		sandboxInfo.Image = container.Image
+		sandboxInfo.ImagePinned = deployment.Annotations[annotationImage] == container.Image
+	}
+	if _, ok := deployment.Annotations[annotationLastActivity]; ok {
+		sandboxInfo.LastActivityAt = sandboxLastActivity(deployment).UTC().Format(time.RFC3339)
 	}
 
-	// Check deployment status
-	if deployment.Status.AvailableReplicas > 0 {
-		sandboxInfo.Status = "Running"
-	} else if deployment.Status.UnavailableReplicas > 0 {
-		sandboxInfo.Status = "Unavailable"
-	} else if deployment.Status.ReadyReplicas == 0 {
-		sandboxInfo.Status = "Pending"
-	} else {
-		sandboxInfo.Status = "Unknown"
+	if sandboxInfo.Status == "Paused" {
+		sandboxInfo.Message = "Sandbox is paused; resume it to start a new pod"
+		if sandboxPreempted(deployment) {
+			sandboxInfo.Reason = ReasonPreempted
+			sandboxInfo.Message = "Sandbox was paused to make room for a higher tier sandbox; resume it to start a new pod"
+		}
 	}
 
-	// Get the pods associated with this deployment
+	return sandboxInfo
+}
+
+// listSandboxPods lists the pods belonging to a user's sandbox
+func (c *Client) listSandboxPods(ctx context.Context, userID string) ([]corev1.Pod, error) {
 	labelSelector := fmt.Sprintf("app=user-sandbox,user=%s", userID)
 	pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: labelSelector,
 	})
 	if err != nil {
-		// If we can't get pods, just return the basic info
-		return sandboxInfo, nil
+		return nil, err
 	}
+	return pods.Items, nil
+}
 
-	// If no pods found, return basic info
-	if len(pods.Items) == 0 {
+// findNewestPod returns the most recently created pod, which is most likely to be the active one
+func findNewestPod(pods []corev1.Pod) *corev1.Pod {
+	var newestPod *corev1.Pod
+	for i := range pods {
+		if newestPod == nil || pods[i].CreationTimestamp.After(newestPod.CreationTimestamp.Time) {
+			newestPod = &pods[i]
+		}
+	}
+	return newestPod
+}
+
+// applyPodStatus refines the sandbox status with details from its newest pod
+func applyPodStatus(sandboxInfo *SandboxInfo, pods []corev1.Pod) {
+	// If no pods found, keep the basic info
+	if len(pods) == 0 {
 		sandboxInfo.Message = "No pods found for this deployment"
-		return sandboxInfo, nil
+		return
 	}
 
 	// Get the newest pod (most likely to be the active one)
-	var newestPod *corev1.Pod
-	for i := range pods.Items {
-		if newestPod == nil || pods.Items[i].CreationTimestamp.After(newestPod.CreationTimestamp.Time) {
-			newestPod = &pods.Items[i]
-		}
-	}
+	newestPod := findNewestPod(pods)
 
 	if newestPod != nil {
 		// Add pod details
@@ -303,6 +339,16 @@ func (c *Client) GetSandboxStatus(ctx context.Context, userID string) (*SandboxI
 
 		// Update overall status based on more detailed pod information
 		if newestPod.Status.Phase == "Pending" {
+			// A pod the scheduler cannot place says why, e.g. "0/3 nodes are available: 3 Insufficient cpu."
+			for _, condition := range newestPod.Status.Conditions {
+				if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+					condition.Reason == corev1.PodReasonUnschedulable {
+					sandboxInfo.Status = condition.Reason
+					sandboxInfo.Reason = condition.Reason
+					sandboxInfo.Message = condition.Message
+				}
+			}
+
 			// Check if we're waiting on image pull
 			for _, cs := range newestPod.Status.ContainerStatuses {
 				if cs.State.Waiting != nil && cs.State.Waiting.Reason == "ImagePullBackOff" {
@@ -345,6 +391,21 @@ func (c *Client) GetSandboxStatus(ctx context.Context, userID string) (*SandboxI
 			}
 		}
 	}
+}
 
-	return sandboxInfo, nil
-}
\ No newline at end of file
+// deploymentStatus derives the coarse sandbox status from a deployment
+func deploymentStatus(deployment *appsv1.Deployment) string {
+	// A deployment scaled to zero is a paused sandbox
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		return "Paused"
+	}
+
+	if deployment.Status.AvailableReplicas > 0 {
+		return "Running"
+	} else if deployment.Status.UnavailableReplicas > 0 {
+		return "Unavailable"
+	} else if deployment.Status.ReadyReplicas == 0 {
+		return "Pending"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,180 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationSecretsHash on the pod template changes whenever the sandbox's secret values
+// change, so the pod restarts and picks them up through envFrom
+const annotationSecretsHash = "sandbox.tryiris.dev/secrets-hash"
+
+// envNamePattern matches a portable environment variable name
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedEnvNames are set by the orchestrator and cannot be overridden by a request
+var reservedEnvNames = map[string]bool{
+	"USER_ID": true,
+}
+
+// sandboxSecretName returns the name of the Secret holding a user's secret values
+func sandboxSecretName(userID string) string {
+	return fmt.Sprintf("%s-secrets", userID)
+}
+
+// validateEnvOptions checks requested environment variables and secrets.
+// Plain variables must match the configured allow-list; secrets may use any valid name.
+func (c *Client) validateEnvOptions(opts SandboxOptions) error {
+	for name := range opts.Env {
+		if err := validateEnvName(name); err != nil {
+			return err
+		}
+		if !envNameAllowed(name, c.config.AllowedEnvKeys) {
+			return fmt.Errorf("%w: environment variable %s is not allowed", ErrInvalidSandboxOptions, name)
+		}
+	}
+	for name := range opts.Secrets {
+		if err := validateEnvName(name); err != nil {
+			return err
+		}
+		if _, ok := opts.Env[name]; ok {
+			return fmt.Errorf("%w: %s is set as both an environment variable and a secret", ErrInvalidSandboxOptions, name)
+		}
+	}
+	return nil
+}
+
+// validateEnvName rejects names that are not valid or are reserved
+func validateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: invalid environment variable name %q", ErrInvalidSandboxOptions, name)
+	}
+	if reservedEnvNames[name] {
+		return fmt.Errorf("%w: %s is set by the orchestrator", ErrInvalidSandboxOptions, name)
+	}
+	return nil
+}
+
+// envNameAllowed reports whether a name matches one of the allow-list patterns
+func envNameAllowed(name string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxEnv returns the sandbox container's environment: USER_ID followed by the
+// requested variables in name order, so the pod template is stable across requests
+func sandboxEnv(userID string, env map[string]string) []corev1.EnvVar {
+	vars := []corev1.EnvVar{{Name: "USER_ID", Value: userID}}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vars = append(vars, corev1.EnvVar{Name: name, Value: env[name]})
+	}
+	return vars
+}
+
+// secretsHash returns a short digest of secret values for the pod template annotation
+func secretsHash(secrets map[string]string) string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\x00", name, secrets[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ensureSandboxSecret creates the user's Secret, or replaces its values if they differ.
+// It runs before the deployment so the pod never starts without its secrets.
+func (c *Client) ensureSandboxSecret(ctx context.Context, userID string, values map[string]string) (string, error) {
+	data := make(map[string][]byte, len(values))
+	for name, value := range values {
+		data[name] = []byte(value)
+	}
+
+	secrets := c.clientset.CoreV1().Secrets(c.namespace)
+	existing, err := secrets.Get(ctx, sandboxSecretName(userID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: sandboxSecretName(userID),
+				Labels: map[string]string{
+					"app":  "user-sandbox",
+					"user": userID,
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}, metav1.CreateOptions{})
+		return createOutcome(err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if reflect.DeepEqual(existing.Data, data) {
+		return ActionUnchanged, nil
+	}
+	existing.Data = data
+	if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return ActionUpdated, nil
+}
+
+// ownSandboxSecret makes the user's deployment the owner of their Secret, so the
+// garbage collector deletes the Secret together with the deployment
+func (c *Client) ownSandboxSecret(ctx context.Context, userID string) error {
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx,
+		fmt.Sprintf("%s-deployment", userID), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	secrets := c.clientset.CoreV1().Secrets(c.namespace)
+	secret, err := secrets.Get(ctx, sandboxSecretName(userID), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for _, owner := range secret.OwnerReferences {
+		if owner.UID == deployment.UID {
+			return nil
+		}
+	}
+
+	secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       deployment.Name,
+		UID:        deployment.UID,
+	})
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// deleteSecret deletes a secret by name
+func (c *Client) deleteSecret(ctx context.Context, name string) error {
+	return c.clientset.CoreV1().Secrets(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
@@ -0,0 +1,415 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Snapshot restore limits
+const (
+	// restoreTimeout bounds how long a restore waits for the pod and old PVC to go away
+	restoreTimeout = 3 * time.Minute
+	// restorePollInterval is how often pod and PVC deletion is checked during a restore
+	restorePollInterval = 2 * time.Second
+)
+
+// ErrSnapshotNotReady is returned when restoring a snapshot that has not finished
+var ErrSnapshotNotReady = errors.New("snapshot is not ready to use")
+
+// SnapshotInfo describes a snapshot of a user's sandbox data
+type SnapshotInfo struct {
+	// Snapshot name
+	Name string `json:"name" example:"user123-20230420123000"`
+	// User the snapshot belongs to
+	UserID string `json:"userId" example:"user123"`
+	// PVC the snapshot was taken from
+	SourcePVC string `json:"sourcePvc" example:"user123-pvc"`
+	// Whether the snapshot can be restored
+	ReadyToUse bool `json:"readyToUse" example:"true"`
+	// Minimum size of a volume restored from the snapshot
+	RestoreSize string `json:"restoreSize,omitempty" example:"1Gi"`
+	// When the snapshot was requested
+	CreatedAt string `json:"createdAt" example:"2023-04-20T12:30:00Z"`
+	// Error reported by the snapshot controller
+	Error string `json:"error,omitempty" example:""`
+}
+
+// CreateSandboxSnapshot takes a VolumeSnapshot of the PVC holding the user's data.
+// An empty name is replaced with {userId}-{timestamp}. The snapshot is taken in the
+// background; it can be restored once ReadyToUse is true.
+func (c *ClientWithTraefik) CreateSandboxSnapshot(ctx context.Context, userID, name string) (*SnapshotInfo, error) {
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", userID, time.Now().UTC().Format("20060102150405"))
+	}
+	if valid, errMsg := IsValidKubernetesName(name); !valid {
+		return nil, fmt.Errorf("%w: invalid snapshot name: %s", ErrInvalidSandboxOptions, errMsg)
+	}
+
+	claimName, err := c.dataClaimName(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("sandbox data not found for user ID %s: %w", userID, err)
+		}
+		return nil, err
+	}
+
+	snapshot := &VolumeSnapshot{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "snapshot.storage.k8s.io/v1",
+			Kind:       "VolumeSnapshot",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			// The user label names the sandbox, which restores are limited to; the owner
+			// label names the user whose data purge removes the snapshot
+			Labels: map[string]string{
+				"app":   "user-sandbox",
+				"user":  userID,
+				"owner": claimQuotaOwner(claim),
+			},
+		},
+		Spec: VolumeSnapshotSpec{
+			Source: VolumeSnapshotSource{
+				PersistentVolumeClaimName: &claimName,
+			},
+		},
+	}
+	if c.config.SnapshotClass != "" {
+		snapshot.Spec.VolumeSnapshotClassName = &c.config.SnapshotClass
+	}
+
+	unstructuredObj, err := convertToUnstructured(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	created, err := c.dynamicClient.Resource(VolumeSnapshotGVR()).Namespace(c.namespace).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(created.Object, snapshot); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Snapshot %s requested for user: %s", name, userID)
+	return snapshotInfo(snapshot), nil
+}
+
+// ListSandboxSnapshots returns the user's snapshots, newest first
+func (c *ClientWithTraefik) ListSandboxSnapshots(ctx context.Context, userID string) ([]SnapshotInfo, error) {
+	list, err := c.dynamicClient.Resource(VolumeSnapshotGVR()).Namespace(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=user-sandbox,user=%s", userID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]SnapshotInfo, 0, len(list.Items))
+	for _, item := range list.Items {
+		var snapshot VolumeSnapshot
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &snapshot); err != nil {
+			log.Printf("Skipping unreadable snapshot %s: %v", item.GetName(), err)
+			continue
+		}
+		snapshots = append(snapshots, *snapshotInfo(&snapshot))
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt > snapshots[j].CreatedAt
+	})
+	return snapshots, nil
+}
+
+// RestoreSandboxSnapshot replaces the user's data with a snapshot. Every sandbox mounting the
+// PVC, including named sandboxes sharing it, is scaled to zero, the PVC is recreated from the
+// snapshot under the same name, and the stopped sandboxes are scaled back up. Everything
+// written since the snapshot is lost.
+// If the PVC must grow to hold the snapshot and that would exceed a storage quota, it fails
+// with a QuotaExceededError before anything is changed.
+func (c *ClientWithTraefik) RestoreSandboxSnapshot(ctx context.Context, userID, name string) error {
+	snapshot, err := c.getSandboxSnapshot(ctx, userID, name)
+	if err != nil {
+		return err
+	}
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotReady, name)
+	}
+
+	claimName, err := c.dataClaimName(ctx, userID)
+	if err != nil {
+		return err
+	}
+	claims := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace)
+	existing, err := claims.Get(ctx, claimName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return err
+	}
+	pvc, err := c.restoredPVC(userID, claimName, existing, snapshot)
+	if err != nil {
+		return err
+	}
+	if err := c.checkRestoreQuota(ctx, userID, existing, pvc); err != nil {
+		return err
+	}
+
+	// Stop every sandbox mounting the volume so it is released; a volume no sandbox mounts
+	// is restored as data only
+	stopped, err := c.stopClaimSandboxes(ctx, claimName)
+	if err != nil {
+		return c.abortRestore(stopped, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, restoreTimeout)
+	defer cancel()
+
+	for _, sandboxID := range append([]string{userID}, stopped...) {
+		if err := c.waitForSandboxPodsGone(waitCtx, sandboxID); err != nil {
+			return c.abortRestore(stopped, fmt.Errorf("pods of sandbox %s did not stop: %w", sandboxID, err))
+		}
+	}
+
+	if existing != nil {
+		if err := claims.Delete(ctx, claimName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return c.abortRestore(stopped, err)
+		}
+		if err := c.waitForPVCGone(waitCtx, claimName); err != nil {
+			return c.abortRestore(stopped, fmt.Errorf("PVC %s was not deleted: %w", claimName, err))
+		}
+	}
+
+	// From here on the old data is gone, so failures are reported without scaling back up
+	if _, err := claims.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to recreate PVC %s from snapshot %s: %w", claimName, name, err)
+	}
+
+	var resumeErr error
+	for _, sandboxID := range stopped {
+		if err := c.ResumeSandbox(ctx, sandboxID); err != nil {
+			log.Printf("Error resuming sandbox %s after restoring snapshot %s: %v", sandboxID, name, err)
+			if resumeErr == nil {
+				resumeErr = err
+			}
+		}
+	}
+	if resumeErr != nil {
+		return resumeErr
+	}
+
+	log.Printf("Snapshot %s restored for user: %s", name, userID)
+	return nil
+}
+
+// getSandboxSnapshot returns one of the user's snapshots by name
+func (c *ClientWithTraefik) getSandboxSnapshot(ctx context.Context, userID, name string) (*VolumeSnapshot, error) {
+	item, err := c.dynamicClient.Resource(VolumeSnapshotGVR()).Namespace(c.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("snapshot %s not found for user ID %s", name, userID)
+		}
+		return nil, err
+	}
+
+	var snapshot VolumeSnapshot
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &snapshot); err != nil {
+		return nil, err
+	}
+	// Snapshots share the namespace, so only the owner's label grants access
+	if snapshot.Labels["user"] != userID {
+		return nil, fmt.Errorf("snapshot %s not found for user ID %s", name, userID)
+	}
+	return &snapshot, nil
+}
+
+// restoredPVC builds a PVC with the old claim's class and size that is populated from a snapshot
+func (c *ClientWithTraefik) restoredPVC(userID, claimName string, existing *corev1.PersistentVolumeClaim, snapshot *VolumeSnapshot) (*corev1.PersistentVolumeClaim, error) {
+	var pvc *corev1.PersistentVolumeClaim
+	if existing != nil {
+		pvc = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   claimName,
+				Labels: existing.Labels,
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes:      existing.Spec.AccessModes,
+				StorageClassName: existing.Spec.StorageClassName,
+				Resources:        existing.Spec.Resources,
+			},
+		}
+	} else {
+		_, size, err := c.resolveProfile("")
+		if err != nil {
+			return nil, err
+		}
+		// Named sandbox IDs contain the separator; any other ID is a user's default volume
+		pvc = c.buildDataPVC(claimName, size, !strings.Contains(userID, sandboxIDSeparator))
+	}
+	if pvc.Labels == nil {
+		pvc.Labels = map[string]string{
+			"app":  "user-sandbox",
+			"user": userID,
+		}
+	}
+
+	// The volume must be at least as large as the snapshot
+	if restoreSize := snapshot.Status.RestoreSize; restoreSize != nil {
+		if requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; requested.Cmp(*restoreSize) < 0 {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{
+				corev1.ResourceStorage: *restoreSize,
+			}
+		}
+	}
+
+	apiGroup := VolumeSnapshotGVR().Group
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshot.Name,
+	}
+	return pvc, nil
+}
+
+// checkRestoreQuota checks that the restored claim fits the storage quotas, counting only what
+// it adds to the claim it replaces
+func (c *ClientWithTraefik) checkRestoreQuota(ctx context.Context, userID string, existing, restored *corev1.PersistentVolumeClaim) error {
+	owner := userID
+	var current resource.Quantity
+	if existing != nil {
+		if claimOwner := claimQuotaOwner(existing); claimOwner != "" {
+			owner = claimOwner
+		}
+		current = existing.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+
+	unlockQuota := c.lockQuota(owner)
+	defer unlockQuota()
+	return c.checkStorageQuota(ctx, owner, current, restored.Spec.Resources.Requests[corev1.ResourceStorage])
+}
+
+// stopClaimSandboxes scales every running sandbox that mounts a PVC to zero and returns the IDs
+// of those it stopped, also when it fails part way
+func (c *ClientWithTraefik) stopClaimSandboxes(ctx context.Context, claimName string) ([]string, error) {
+	deployments, err := c.clientset.AppsV1().Deployments(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=user-sandbox",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stopped []string
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if deploymentDataClaim(deployment) != claimName || deploymentReplicas(deployment) == 0 {
+			continue
+		}
+		sandboxID := deployment.Labels["user"]
+		if err := c.scaleSandbox(ctx, sandboxID, 0); err != nil {
+			return stopped, err
+		}
+		stopped = append(stopped, sandboxID)
+	}
+	return stopped, nil
+}
+
+// abortRestore scales the stopped sandboxes back up after a restore failed before any data was touched
+func (c *ClientWithTraefik) abortRestore(stopped []string, err error) error {
+	for _, sandboxID := range stopped {
+		// The request context may be what ended the restore, so scaling back gets its own
+		if scaleErr := c.scaleSandbox(context.Background(), sandboxID, 1); scaleErr != nil {
+			log.Printf("Error scaling sandbox %s back up after failed restore: %v", sandboxID, scaleErr)
+		}
+	}
+	return err
+}
+
+// waitForSandboxPodsGone waits until the user has no pods left
+func (c *Client) waitForSandboxPodsGone(ctx context.Context, userID string) error {
+	return pollUntil(ctx, restorePollInterval, func() (bool, error) {
+		pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("app=user-sandbox,user=%s", userID),
+		})
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	})
+}
+
+// waitForPVCGone waits until a PVC has been deleted
+func (c *Client) waitForPVCGone(ctx context.Context, name string) error {
+	return pollUntil(ctx, restorePollInterval, func() (bool, error) {
+		_, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// pollUntil calls done every interval until it reports true, fails, or ctx ends
+func pollUntil(ctx context.Context, interval time.Duration, done func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// deploymentReplicas returns a deployment's desired replica count
+func deploymentReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// snapshotInfo summarises a VolumeSnapshot
+func snapshotInfo(snapshot *VolumeSnapshot) *SnapshotInfo {
+	info := &SnapshotInfo{
+		Name:      snapshot.Name,
+		UserID:    snapshot.Labels["user"],
+		CreatedAt: snapshot.CreationTimestamp.UTC().Format(time.RFC3339),
+	}
+	if source := snapshot.Spec.Source.PersistentVolumeClaimName; source != nil {
+		info.SourcePVC = *source
+	}
+	if status := snapshot.Status; status != nil {
+		info.ReadyToUse = status.ReadyToUse != nil && *status.ReadyToUse
+		if status.RestoreSize != nil {
+			info.RestoreSize = status.RestoreSize.String()
+		}
+		if status.Error != nil && status.Error.Message != nil {
+			info.Error = *status.Error.Message
+		}
+	}
+	return info
+}
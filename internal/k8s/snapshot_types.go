@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// VolumeSnapshot defines the CSI VolumeSnapshot resource
+type VolumeSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              VolumeSnapshotSpec    `json:"spec"`
+	Status            *VolumeSnapshotStatus `json:"status,omitempty"`
+}
+
+// VolumeSnapshotSpec defines the desired state of a VolumeSnapshot
+type VolumeSnapshotSpec struct {
+	Source                  VolumeSnapshotSource `json:"source"`
+	VolumeSnapshotClassName *string              `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// VolumeSnapshotSource names the PVC a VolumeSnapshot is taken from
+type VolumeSnapshotSource struct {
+	PersistentVolumeClaimName *string `json:"persistentVolumeClaimName,omitempty"`
+}
+
+// VolumeSnapshotStatus is the observed state of a VolumeSnapshot
+type VolumeSnapshotStatus struct {
+	CreationTime *metav1.Time         `json:"creationTime,omitempty"`
+	ReadyToUse   *bool                `json:"readyToUse,omitempty"`
+	RestoreSize  *resource.Quantity   `json:"restoreSize,omitempty"`
+	Error        *VolumeSnapshotError `json:"error,omitempty"`
+}
+
+// VolumeSnapshotError describes a failure taking a VolumeSnapshot
+type VolumeSnapshotError struct {
+	Time    *metav1.Time `json:"time,omitempty"`
+	Message *string      `json:"message,omitempty"`
+}
+
+// VolumeSnapshotGVR returns the GroupVersionResource for VolumeSnapshot
+func VolumeSnapshotGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "snapshot.storage.k8s.io",
+		Version:  "v1",
+		Resource: "volumesnapshots",
+	}
+}
@@ -3,28 +3,49 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// createPVC creates a persistent volume claim for the user
-func (c *Client) createPVC(ctx context.Context, userID string) error {
+// createPVC creates a persistent volume claim for the user, sized by the sandbox's profile.
+// It reports whether a new claim was created, since an existing claim is reused as-is.
+func (c *Client) createPVC(ctx context.Context, userID string, opts SandboxOptions) (bool, error) {
 	pvcName := fmt.Sprintf("%s-pvc", userID)
 
 	// Check if PVC already exists
 	_, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, pvcName, metav1.GetOptions{})
 	if err == nil {
 		// PVC exists
-		return nil
+		return false, nil
+	}
+
+	_, size, err := c.resolveProfile(opts.Profile)
+	if err != nil {
+		return false, err
 	}
 
-	storageClassName := "standard-rwo" // Use the default storage class
-	// Create PVC
-	pvc := &corev1.PersistentVolumeClaim{
+	// Create PVC, labelled so the user's data can be found for snapshots and purges
+	pvc := c.buildDataPVC(pvcName, size, opts.Owner == "")
+	pvc.Labels = opts.ownerLabels(userID)
+	pvc.Labels["app"] = "user-sandbox"
+	pvc.Labels["user"] = userID
+	_, err = c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// buildPVC returns a persistent volume claim sized by a profile
+func buildPVC(name string, size *sandboxSize) *corev1.PersistentVolumeClaim {
+	storageClassName := size.storageClass
+	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: pvcName,
+			Name: name,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{
@@ -33,23 +54,144 @@ func (c *Client) createPVC(ctx context.Context, userID string) error {
 			StorageClassName: &storageClassName,
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
-					corev1.ResourceStorage: resource.MustParse("1Gi"),
+					corev1.ResourceStorage: size.storageSize,
 				},
 			},
 		},
 	}
+}
 
-	_, err = c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Create(ctx, pvc, metav1.CreateOptions{})
-	return err
+// buildDataPVC returns the claim for a sandbox's data volume. A user's default volume, which
+// named sandboxes may share, is a ReadWriteMany volume of the shared storage class if one is set.
+func (c *Client) buildDataPVC(name string, size *sandboxSize, defaultVolume bool) *corev1.PersistentVolumeClaim {
+	pvc := buildPVC(name, size)
+	if defaultVolume && c.config.SharedStorageClass != "" {
+		storageClassName := c.config.SharedStorageClass
+		pvc.Spec.StorageClassName = &storageClassName
+		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+	return pvc
+}
+
+// checkSharedStorage makes sure a new sandbox with shared storage can mount its owner's default
+// volume alongside the owner's sandbox, which takes a ReadWriteMany volume: a ReadWriteOnce volume
+// only attaches to one node. A missing volume is created with the shared storage class.
+func (c *Client) checkSharedStorage(ctx context.Context, sandboxID string, opts SandboxOptions) error {
+	if !opts.SharedStorage {
+		return nil
+	}
+	if _, exists, err := c.deploymentClaimName(ctx, sandboxID); err != nil || exists {
+		return err
+	}
+	if c.config.SharedStorageClass == "" {
+		return fmt.Errorf("%w: shared storage is not enabled", ErrInvalidSandboxOptions)
+	}
+
+	claimName, err := c.dataClaimName(ctx, opts.Owner)
+	if err != nil {
+		return err
+	}
+	pvc, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(pvc.Spec.AccessModes, corev1.ReadWriteMany) {
+		return fmt.Errorf("%w: the data volume of user %s is not ReadWriteMany, so it cannot be shared",
+			ErrInvalidSandboxOptions, opts.Owner)
+	}
+	return nil
+}
+
+// sharesClaim reports whether a sandbox mounts a PVC that belongs to another sandbox
+func (c *Client) sharesClaim(ctx context.Context, sandboxID, claimName string) (bool, error) {
+	pvc, err := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	owner := claimUserID(pvc)
+	return owner != "" && owner != sandboxID, nil
+}
+
+// dataClaimName returns the name of the PVC holding a sandbox's data. An existing sandbox
+// keeps the claim its deployment mounts, which may be its owner's shared volume. Otherwise
+// this is {userId}-pvc, unless the sandbox was started from the warm pool and kept the pool's claim.
+func (c *Client) dataClaimName(ctx context.Context, userID string) (string, error) {
+	pvcName := fmt.Sprintf("%s-pvc", userID)
+
+	if claimName, ok, err := c.deploymentClaimName(ctx, userID); err != nil || ok {
+		return claimName, err
+	}
+
+	claims := c.clientset.CoreV1().PersistentVolumeClaims(c.namespace)
+	_, err := claims.Get(ctx, pvcName, metav1.GetOptions{})
+	if err == nil {
+		return pvcName, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	adopted, err := claims.List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=user-sandbox,user=%s", userID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(adopted.Items) > 0 {
+		return adopted.Items[0].Name, nil
+	}
+	return pvcName, nil
 }
 
-// getUserDataVolume returns a volume for user data linked to the user's PVC
-func (c *Client) getUserDataVolume(userID string) corev1.Volume {
+// sandboxClaimName returns the PVC a sandbox's deployment should mount. The claim of an
+// existing sandbox never changes, so shared storage can only be chosen at creation.
+func (c *Client) sandboxClaimName(ctx context.Context, userID string, opts SandboxOptions) (string, error) {
+	if claimName, ok, err := c.deploymentClaimName(ctx, userID); err != nil || ok {
+		return claimName, err
+	}
+	if opts.SharedStorage {
+		return c.dataClaimName(ctx, opts.Owner)
+	}
+	return c.dataClaimName(ctx, userID)
+}
+
+// deploymentClaimName returns the PVC mounted by an existing sandbox deployment
+func (c *Client) deploymentClaimName(ctx context.Context, userID string) (string, bool, error) {
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, fmt.Sprintf("%s-deployment", userID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	claimName := deploymentDataClaim(deployment)
+	return claimName, claimName != "", nil
+}
+
+// deploymentDataClaim returns the PVC a sandbox deployment mounts as its data volume, or ""
+func deploymentDataClaim(deployment *appsv1.Deployment) string {
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name == "user-data" && volume.PersistentVolumeClaim != nil {
+			return volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	return ""
+}
+
+// getUserDataVolume returns a volume for user data linked to the given PVC
+func (c *Client) getUserDataVolume(claimName string) corev1.Volume {
 	return corev1.Volume{
 		Name: "user-data",
 		VolumeSource: corev1.VolumeSource{
 			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-				ClaimName: fmt.Sprintf("%s-pvc", userID),
+				ClaimName: claimName,
 			},
 		},
 	}
@@ -65,6 +207,21 @@ func (c *Client) getUserDataVolumeMounts() []corev1.VolumeMount {
 	}
 }
 
+// sandboxDataVolumeMounts returns the data volume mounts of a sandbox. A sandbox sharing another
+// sandbox's volume gets its own browser directory on it, so the two browsers never use the same
+// profile or remove each other's profile lock.
+func (c *Client) sandboxDataVolumeMounts(sandboxID string, shared bool) []corev1.VolumeMount {
+	mounts := c.getUserDataVolumeMounts()
+	if shared {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "user-data",
+			MountPath: "/config/browser",
+			SubPath:   fmt.Sprintf("sandboxes/%s/browser", sandboxID),
+		})
+	}
+	return mounts
+}
+
 // This function has been removed as we no longer support .env file volume mounts
 
 // These functions have been removed as we no longer support .env file volume mounts
@@ -0,0 +1,66 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/shanurcsenitap/irisk8s/internal/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSharedSandboxBrowserDirectory(t *testing.T) {
+	c := &Client{config: &config.Configuration{
+		SandboxProfiles: map[string]config.SandboxProfile{
+			config.DefaultSandboxProfile: {
+				CPURequest:    "1",
+				CPULimit:      "2",
+				MemoryRequest: "2Gi",
+				MemoryLimit:   "4Gi",
+				ShmSize:       "1Gi",
+				StorageSize:   "10Gi",
+			},
+		},
+		DefaultSandboxProfile: config.DefaultSandboxProfile,
+	}}
+	image := "iris_agent:latest"
+
+	owner, err := c.sandboxDeployment("user1", SandboxOptions{}, "user1-pvc", false, image)
+	if err != nil {
+		t.Fatalf("Failed to build the owner's sandbox: %v", err)
+	}
+	shared, err := c.sandboxDeployment("user1--work", SandboxOptions{Owner: "user1", Name: "work", SharedStorage: true}, "user1-pvc", true, image)
+	if err != nil {
+		t.Fatalf("Failed to build the shared sandbox: %v", err)
+	}
+
+	// The shared sandbox's init container and browser both use its own browser directory
+	for _, container := range []corev1.Container{shared.Spec.Template.Spec.InitContainers[0], *findContainer(shared.Spec.Template.Spec.Containers, "sandbox")} {
+		mounts := dataVolumeMounts(&container)
+		if len(mounts) != 2 || mounts[1].MountPath != "/config/browser" || mounts[1].SubPath != "sandboxes/user1--work/browser" {
+			t.Errorf("Expected %s to mount its own browser directory, got %+v", container.Name, mounts)
+		}
+	}
+	if mounts := dataVolumeMounts(findContainer(owner.Spec.Template.Spec.Containers, "sandbox")); len(mounts) != 1 {
+		t.Errorf("Expected the owner's sandbox to keep the volume's browser directory, got %+v", mounts)
+	}
+
+	// A shared sandbox created before it had its own browser directory is repaired
+	if !podTemplateDrifted(&owner.Spec.Template, &shared.Spec.Template) {
+		t.Error("Expected a missing browser directory mount to count as drift")
+	}
+}
+
+func TestBuildDataPVC(t *testing.T) {
+	c := &Client{config: &config.Configuration{SharedStorageClass: "filestore"}}
+	size := &sandboxSize{storageClass: "standard-rwo"}
+
+	pvc := c.buildDataPVC("user1-pvc", size, true)
+	if *pvc.Spec.StorageClassName != "filestore" || pvc.Spec.AccessModes[0] != corev1.ReadWriteMany {
+		t.Errorf("Expected the default volume to be ReadWriteMany on the shared class, got %s %v",
+			*pvc.Spec.StorageClassName, pvc.Spec.AccessModes)
+	}
+	pvc = c.buildDataPVC("user1--work-pvc", size, false)
+	if *pvc.Spec.StorageClassName != "standard-rwo" || pvc.Spec.AccessModes[0] != corev1.ReadWriteOnce {
+		t.Errorf("Expected a named sandbox's own volume to use its profile's class, got %s %v",
+			*pvc.Spec.StorageClassName, pvc.Spec.AccessModes)
+	}
+}
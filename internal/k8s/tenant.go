@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Tenant settings
+const (
+	// tenantLabel on a namespace names the tenant whose sandboxes it holds
+	tenantLabel = "sandbox.tryiris.dev/tenant"
+	// tenantNamespacePrefix is prepended to a tenant ID to name the tenant's namespace
+	tenantNamespacePrefix = "sandboxes-"
+	// maxTenantIDLength keeps the tenant's namespace within the 63 character limit
+	maxTenantIDLength = 63 - len(tenantNamespacePrefix)
+)
+
+// ValidateTenantID checks that a tenant ID can name a namespace and a subdomain
+func ValidateTenantID(tenant string) error {
+	if len(tenant) > maxTenantIDLength {
+		return fmt.Errorf("tenant ID must be %d characters or less", maxTenantIDLength)
+	}
+	if valid, errMsg := IsValidKubernetesName(tenant); !valid {
+		return fmt.Errorf("invalid tenant ID: %s", errMsg)
+	}
+	return nil
+}
+
+// TenantNamespace returns the namespace holding a tenant's sandboxes
+func TenantNamespace(tenant string) string {
+	return tenantNamespacePrefix + tenant
+}
+
+// ForTenant returns a client that manages the sandboxes of one tenant. The tenant's sandboxes
+// live in their own namespace, created with the first sandbox, and are served under the
+// tenant's subdomain ({userId}-vnc.{tenant}.tryiris.dev). The tenant must pass ValidateTenantID.
+// Tenant clients have no warm pool; they share the event watcher, which covers every namespace.
+func (c *ClientWithTraefik) ForTenant(tenant string) *ClientWithTraefik {
+	tenantClient := *c
+	tenantClient.tenant = tenant
+	tenantClient.namespace = TenantNamespace(tenant)
+	tenantClient.domain = fmt.Sprintf("%s.%s", tenant, c.domain)
+	tenantClient.pool = nil
+	return &tenantClient
+}
+
+// Tenant returns the tenant the client manages, or "" for the default namespace
+func (c *Client) Tenant() string {
+	return c.tenant
+}
+
+// ListTenants returns the tenants that have a namespace
+func (c *Client) ListTenants(ctx context.Context) ([]string, error) {
+	namespaces, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: tenantLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := []string{}
+	for _, ns := range namespaces.Items {
+		tenant := ns.Labels[tenantLabel]
+		if tenant != "" && ns.Name == TenantNamespace(tenant) {
+			tenants = append(tenants, tenant)
+		}
+	}
+	return tenants, nil
+}
+
+// forEachTenant runs fn with the client of every tenant. Errors are logged so that one
+// tenant cannot stop the others from being processed.
+func (c *ClientWithTraefik) forEachTenant(ctx context.Context, task string, fn func(*ClientWithTraefik) error) {
+	if c.tenant != "" {
+		return
+	}
+
+	tenants, err := c.ListTenants(ctx)
+	if err != nil {
+		log.Printf("Error listing tenants for %s: %v", task, err)
+		return
+	}
+	for _, tenant := range tenants {
+		if err := fn(c.ForTenant(tenant)); err != nil {
+			log.Printf("Error %s for tenant %s: %v", task, tenant, err)
+		}
+	}
+}
+
+// namespaceLabels returns the labels of the client's namespace
+func (c *Client) namespaceLabels() map[string]string {
+	if c.tenant == "" {
+		return nil
+	}
+	return map[string]string{tenantLabel: c.tenant}
+}
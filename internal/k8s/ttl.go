@@ -0,0 +1,104 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Deployment annotations holding a sandbox's lifetime
+const (
+	annotationTTL       = "sandbox.tryiris.dev/ttl"
+	annotationExpiresAt = "sandbox.tryiris.dev/expires-at"
+)
+
+// ttlAnnotations returns the lifetime annotations for a sandbox that expires ttl from now
+func ttlAnnotations(ttl time.Duration, now time.Time) map[string]string {
+	return map[string]string{
+		annotationTTL:       ttl.String(),
+		annotationExpiresAt: now.Add(ttl).UTC().Format(time.RFC3339),
+	}
+}
+
+// sandboxTTL returns the TTL a sandbox was created with, or defaultTTL if it has none
+func sandboxTTL(deployment *appsv1.Deployment, defaultTTL time.Duration) time.Duration {
+	if ttl, err := time.ParseDuration(deployment.Annotations[annotationTTL]); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultTTL
+}
+
+// sandboxExpiry returns when a sandbox expires. Sandboxes created before per-sandbox TTLs
+// have no expiry annotation and expire defaultTTL after creation, as they always did.
+func sandboxExpiry(deployment *appsv1.Deployment, defaultTTL time.Duration) time.Time {
+	if expiresAt, err := time.Parse(time.RFC3339, deployment.Annotations[annotationExpiresAt]); err == nil {
+		return expiresAt
+	}
+	return deployment.CreationTimestamp.Add(defaultTTL)
+}
+
+// ExtendSandbox pushes a sandbox's expiry back by the given duration, counting from
+// now if it has already expired. The expiry is capped at SandboxMaxTTL from now.
+func (c *Client) ExtendSandbox(ctx context.Context, userID string, by time.Duration) (time.Time, error) {
+	if by <= 0 {
+		return time.Time{}, fmt.Errorf("%w: extension must be positive", ErrInvalidSandboxOptions)
+	}
+
+	return c.updateSandboxExpiry(ctx, userID, false, func(deployment *appsv1.Deployment, now time.Time) time.Time {
+		expiresAt := sandboxExpiry(deployment, c.config.SandboxTimeoutDuration)
+		if expiresAt.Before(now) {
+			expiresAt = now
+		}
+		return expiresAt.Add(by)
+	})
+}
+
+// KeepaliveSandbox restarts a sandbox's TTL from now and records it as active for idle cleanup.
+// An expiry that is already further away, e.g. after an explicit extension, is kept.
+func (c *Client) KeepaliveSandbox(ctx context.Context, userID string) (time.Time, error) {
+	return c.updateSandboxExpiry(ctx, userID, true, func(deployment *appsv1.Deployment, now time.Time) time.Time {
+		expiresAt := sandboxExpiry(deployment, c.config.SandboxTimeoutDuration)
+		if renewed := now.Add(sandboxTTL(deployment, c.config.SandboxTimeoutDuration)); renewed.After(expiresAt) {
+			return renewed
+		}
+		return expiresAt
+	})
+}
+
+// updateSandboxExpiry computes a new expiry for the user's deployment and stores it,
+// optionally recording that the sandbox is in use
+func (c *Client) updateSandboxExpiry(ctx context.Context, userID string, recordActivity bool,
+	next func(deployment *appsv1.Deployment, now time.Time) time.Time) (time.Time, error) {
+	deploymentName := fmt.Sprintf("%s-deployment", userID)
+	deployments := c.clientset.AppsV1().Deployments(c.namespace)
+
+	deployment, err := deployments.Get(ctx, deploymentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return time.Time{}, fmt.Errorf("sandbox not found for user ID %s", userID)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := next(deployment, now)
+	if limit := now.Add(c.config.SandboxMaxTTL); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	expiresAt = expiresAt.UTC().Truncate(time.Second)
+
+	annotations := map[string]string{
+		annotationExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	if recordActivity {
+		annotations[annotationLastActivity] = now.UTC().Format(time.RFC3339)
+	}
+	if err := c.patchDeploymentAnnotations(ctx, userID, annotations); err != nil {
+		return time.Time{}, err
+	}
+	return expiresAt, nil
+}
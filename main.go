@@ -40,6 +40,20 @@ func main() {
 	// Start the auto cleanup service to delete sandboxes after 15 minutes
 	k8sClient.StartAutoCleanupService(context.Background())
 
+	// Watch sandbox Deployments and Pods to stream status changes to clients
+	if err := k8sClient.StartSandboxWatcher(context.Background()); err != nil {
+		log.Printf("Failed to start sandbox watcher, event streams will be unavailable: %v", err)
+	}
+
+	// Purge the data of users without a sandbox for SANDBOX_DATA_RETENTION_DAYS (disabled when unset)
+	k8sClient.StartDataRetentionSweeper(context.Background())
+
+	// Keep pre-provisioned sandboxes ready for fast starts (disabled when SANDBOX_POOL_SIZE is 0)
+	k8sClient.StartWarmPool(context.Background())
+
+	// Start queued creates as capacity frees up (disabled when SANDBOX_QUEUE_SIZE is 0)
+	k8sClient.StartSandboxQueue(context.Background())
+
 	// Initialize router
 	router := gin.Default()
 